@@ -0,0 +1,256 @@
+package eventedconnection
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// DefaultMaxDeliveryAttempts is the default number of times a write is sent without
+// being acknowledged before it is given up on, see Config.AckExtractor
+const DefaultMaxDeliveryAttempts = 5
+
+// AckExtractor is called with every message read when Config.AckExtractor is set and
+// reports whether it acknowledges a write, and the sequence number of that write.
+// Acknowledgements are consumed rather than delivered on the Read channel.
+type AckExtractor func(data []byte) (seq uint64, ok bool)
+
+// SequenceHook returns data numbered with seq for at-least-once delivery, in whatever
+// form the endpoint expects. Without one the sequence number is prepended to data as
+// a big-endian uint64. It must not modify data in place since it belongs to the caller.
+// Returning an error aborts the write (Write returns the error). It may call Unacked
+// but must not write.
+type SequenceHook func(seq uint64, data []byte) ([]byte, error)
+
+// OnDeliveryFailedHook is called with every write given up on by at-least-once delivery,
+// with its sequence number and the data passed to Write. data belongs to the hook.
+type OnDeliveryFailedHook func(seq uint64, data []byte)
+
+// pendingWrite is a write waiting to be acknowledged
+type pendingWrite struct {
+	seq      uint64
+	data     []byte // as passed to Write
+	payload  []byte // data numbered with seq
+	attempts int    // times payload was written; zero while it is in the write queue
+}
+
+// ackTracker numbers writes and keeps them until they are acknowledged, see
+// Config.AckExtractor
+type ackTracker struct {
+	mutex   sync.Mutex
+	last    uint64         // last sequence number handed out
+	pending []pendingWrite // in sequence order
+	empty   chan struct{}  // closed while nothing is pending
+}
+
+func newAckTracker() *ackTracker {
+	t := &ackTracker{empty: make(chan struct{})}
+	close(t.empty)
+	return t
+}
+
+// restore tracks the numbered writes loaded from the outbox file, which are in the
+// write queue, and makes the sequence numbers handed out continue after theirs.
+// Their original data isn't kept, so the numbered payload stands in for it.
+func (t *ackTracker) restore(writes []queuedWrite) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, w := range writes {
+		if w.seq == 0 {
+			continue
+		}
+		t.last = max(t.last, w.seq)
+		if len(t.pending) == 0 {
+			t.empty = make(chan struct{})
+		}
+		t.pending = append(t.pending, pendingWrite{seq: w.seq, data: w.data, payload: w.data})
+	}
+}
+
+// add numbers a copy of data with the next sequence number and keeps it as pending.
+// The SequenceHook is called without holding t.mutex; calls to add are serialized by
+// conn.writeMutex.
+func (t *ackTracker) add(data []byte, number SequenceHook) (uint64, []byte, error) {
+	t.mutex.Lock()
+	seq := t.last + 1
+	t.mutex.Unlock()
+
+	data = slices.Clone(data)
+	var payload []byte
+	if number != nil {
+		var err error
+		if payload, err = number(seq, data); err != nil {
+			return 0, nil, err
+		}
+	} else {
+		payload = binary.BigEndian.AppendUint64(make([]byte, 0, 8+len(data)), seq)
+		payload = append(payload, data...)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.last = seq
+	if len(t.pending) == 0 {
+		t.empty = make(chan struct{})
+	}
+	t.pending = append(t.pending, pendingWrite{seq: seq, data: data, payload: payload})
+	return seq, payload, nil
+}
+
+func (t *ackTracker) find(seq uint64) (int, bool) {
+	return slices.BinarySearchFunc(t.pending, seq, func(p pendingWrite, seq uint64) int {
+		switch {
+		case p.seq < seq:
+			return -1
+		case p.seq > seq:
+			return 1
+		}
+		return 0
+	})
+}
+
+// sent records that the write numbered seq was written to the connection
+func (t *ackTracker) sent(seq uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if i, ok := t.find(seq); ok {
+		t.pending[i].attempts++
+	}
+}
+
+// remove stops tracking the write numbered seq, reporting whether it was pending
+func (t *ackTracker) remove(seq uint64) (pendingWrite, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	i, ok := t.find(seq)
+	if !ok {
+		return pendingWrite{}, false
+	}
+	p := t.pending[i]
+	t.pending = slices.Delete(t.pending, i, i+1)
+	if len(t.pending) == 0 {
+		close(t.empty)
+	}
+	return p, true
+}
+
+// unacked returns the pending writes that were written at least once
+func (t *ackTracker) unacked() []pendingWrite {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var writes []pendingWrite
+	for _, p := range t.pending {
+		if p.attempts > 0 {
+			writes = append(writes, p)
+		}
+	}
+	return writes
+}
+
+// drain stops tracking all pending writes and returns them
+func (t *ackTracker) drain() []pendingWrite {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	writes := t.pending
+	t.pending = nil
+	if len(writes) > 0 {
+		close(t.empty)
+	}
+	return writes
+}
+
+// emptied returns a channel that is closed once nothing is pending
+func (t *ackTracker) emptied() <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.empty
+}
+
+func (t *ackTracker) len() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return len(t.pending)
+}
+
+// Unacked returns the number of writes waiting to be acknowledged, including those
+// still in the write queue. It is always zero unless Config.AckExtractor is set.
+func (conn *Client) Unacked() int {
+	if conn.acks == nil {
+		return 0
+	}
+	return conn.acks.len()
+}
+
+// acknowledge passes data to the AckExtractor and reports whether it is an
+// acknowledgement, which is not delivered
+func (conn *Client) acknowledge(data []byte) bool {
+	if conn.acks == nil {
+		return false
+	}
+
+	seq, ok := conn.ackExtractor(data)
+	if !ok {
+		return false
+	}
+	if _, pending := conn.acks.remove(seq); pending {
+		conn.stats.recordAck()
+	}
+	return true
+}
+
+// retransmit writes the writes that were sent on an earlier connection but not
+// acknowledged, in order, giving up on those sent conn.maxDeliveryAttempts times
+// already. It stops at the first write that fails.
+func (conn *Client) retransmit() {
+	if conn.acks == nil {
+		return
+	}
+
+	conn.writeMutex.Lock()
+	defer conn.unlockWrites()
+
+	for _, p := range conn.acks.unacked() {
+		if p.attempts >= conn.maxDeliveryAttempts {
+			if _, pending := conn.acks.remove(p.seq); pending {
+				conn.deliveryFailed(p)
+			}
+			continue
+		}
+
+		timeout := conn.GetWriteTimeout()
+		if err := conn.limitRate(len(p.payload), timeout, true); err != nil {
+			conn.handleError(err)
+			return
+		}
+		if err := conn.writeLocked(p.payload, timeout); err != nil {
+			return // reported by writeLocked
+		}
+		conn.acks.sent(p.seq)
+		conn.stats.recordRetransmit()
+	}
+}
+
+// failPending gives up on every write still waiting to be acknowledged
+func (conn *Client) failPending() {
+	if conn.acks == nil {
+		return
+	}
+	for _, p := range conn.acks.drain() {
+		conn.deliveryFailed(p)
+	}
+}
+
+func (conn *Client) deliveryFailed(p pendingWrite) {
+	conn.stats.recordDeliveryFailure()
+	conn.logger.Warn("giving up on delivery", slog.Uint64("seq", p.seq), slog.Int("attempts", p.attempts))
+	if conn.onDeliveryFailedHook != nil {
+		conn.onDeliveryFailedHook(p.seq, p.data)
+	}
+}
@@ -0,0 +1,118 @@
+package eventedconnection_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+// extractSeq treats every message of at least 8 bytes as an acknowledgement of the
+// write numbered with its big-endian prefix
+func extractSeq(data []byte) (uint64, bool) {
+	if len(data) < 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+func TestClient_Acks(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	// the echoed write acknowledges itself
+	conf := Config{Endpoint: l.Addr().String(), AckExtractor: extractSeq}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for con.Unacked() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the acknowledgement")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assertEqual(t, con.GetStats().MessagesAcked, uint64(1))
+	assertEqual(t, len(con.Read), 0) // acknowledgements aren't delivered
+}
+
+func TestClient_AckRetransmit(t *testing.T) {
+	// the server never acknowledges
+	done := make(chan bool)
+	l, recorder, err := testutils.RecordingServer(done, testutils.RecordingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	type failure struct {
+		seq  uint64
+		data string
+	}
+	failed := make(chan failure, 1)
+	conf := Config{
+		Endpoint:            l.Addr().String(),
+		AckExtractor:        extractSeq,
+		MaxDeliveryAttempts: 2,
+		OnDeliveryFailedHook: func(seq uint64, data []byte) {
+			failed <- failure{seq, string(data)}
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("one"); err != nil {
+		t.Fatal(err)
+	}
+	want := append(binary.BigEndian.AppendUint64(nil, 1), "one"...)
+	if _, err = recorder.WaitForReceived(len(want), 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// retransmitted on the next connection
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = recorder.WaitForReceived(2*len(want), 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range recorder.Connections() {
+		assertEqual(t, string(c.Received), string(want))
+	}
+
+	// and given up on after that
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case f := <-failed:
+		assertEqual(t, f, failure{1, "one"})
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the delivery to fail")
+	}
+	assertEqual(t, con.Unacked(), 0)
+	assertEqual(t, con.GetStats().Retransmits, uint64(1))
+	assertEqual(t, con.GetStats().DeliveryFailures, uint64(1))
+}
@@ -0,0 +1,32 @@
+package eventedconnection
+
+import (
+	"log/slog"
+	"time"
+)
+
+// connectionAge returns how long the next connection may stay open: MaxConnectionAge
+// less up to 10% of jitter, so clients connected at the same time don't all reconnect
+// at once
+func (conn *Client) connectionAge() time.Duration {
+	return conn.maxConnectionAge - conn.random.duration(conn.maxConnectionAge/10+1)
+}
+
+// recycleAfter replaces the connection with a new one after age, unless stop is
+// closed or the client is shut down first
+func (conn *Client) recycleAfter(age time.Duration, stop <-chan struct{}) {
+	timer := conn.clock.NewTimer(age)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+	case <-stop:
+		return
+	case <-conn.done:
+		return
+	}
+
+	conn.logger.Info("recycling connection", slog.Duration("age", age))
+	// errors are reported by connect
+	_ = conn.reconnect(true)
+}
@@ -0,0 +1,123 @@
+package eventedconnection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Authenticator implements an authentication mechanism, such as PLAIN, which the client
+// runs with the endpoint on every new connection, after TLS (if any) and before the
+// connection is reported as established. See Config.Authenticator for the exchange.
+type Authenticator interface {
+	// Start is called at the beginning of the exchange and returns the client's initial
+	// message.
+	Start() ([]byte, error)
+
+	// Challenge is called with every challenge sent by the endpoint and returns the
+	// response to send back.
+	Challenge(challenge []byte) ([]byte, error)
+
+	// Complete is called with the data accompanying the endpoint's success message,
+	// e.g. to verify a server signature. Returning an error fails the authentication.
+	Complete(outcome []byte) error
+}
+
+// Status bytes opening every frame the endpoint sends during authentication
+const (
+	AuthChallenge byte = 0 // the rest of the frame is a challenge to respond to
+	AuthSuccess   byte = 1 // authentication succeeded; the rest is passed to Complete
+	AuthFailure   byte = 2 // authentication failed; the rest is the reason
+)
+
+// PlainAuthenticator is the PLAIN mechanism (RFC 4616): a single message holding the
+// optional authorization Identity, the Username and the Password, separated by NUL
+// bytes. It sends the password in the clear, so use it over TLS or with
+// Config.EncryptionKey.
+type PlainAuthenticator struct {
+	Identity string // identity to act as, if not Username's
+	Username string
+	Password string
+}
+
+// Start returns the PLAIN message
+func (a *PlainAuthenticator) Start() ([]byte, error) {
+	if len(a.Username) == 0 {
+		return nil, errors.New("PLAIN authentication requires a username")
+	}
+	message := make([]byte, 0, len(a.Identity)+len(a.Username)+len(a.Password)+2)
+	message = append(message, a.Identity...)
+	message = append(message, 0)
+	message = append(message, a.Username...)
+	message = append(message, 0)
+	return append(message, a.Password...), nil
+}
+
+// Challenge fails since PLAIN has no challenges
+func (a *PlainAuthenticator) Challenge([]byte) ([]byte, error) {
+	return nil, errors.New("unexpected challenge for PLAIN authentication")
+}
+
+// Complete accepts any success message
+func (a *PlainAuthenticator) Complete([]byte) error {
+	return nil
+}
+
+// authenticate runs the Authenticator's exchange over a newly established connection,
+// within the connection timeout
+func (conn *Client) authenticate(ctx context.Context, connection net.Conn) error {
+	_, span := conn.startSpan(ctx, "eventedconnection.Authenticate")
+	defer span.End()
+
+	err := conn.exchangeAuth(connection)
+	if err != nil {
+		err = opError("authenticate", connection, err)
+		recordSpanError(span, err)
+	}
+	return err
+}
+
+// exchangeAuth sends the Authenticator's messages to connection and passes it the
+// endpoint's replies until the endpoint reports success or failure. A panic in the
+// Authenticator fails the exchange with a HookPanicError.
+func (conn *Client) exchangeAuth(connection net.Conn) (err error) {
+	defer recoverHook("Authenticator", &err)
+
+	if err := connection.SetDeadline(conn.clock.Now().Add(conn.GetConnectionTimeout())); err != nil {
+		return err
+	}
+	defer connection.SetDeadline(time.Time{})
+
+	message, err := conn.authenticator.Start()
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err := connection.Write(appendFrame(nil, message)); err != nil {
+			return err
+		}
+
+		frame, err := readFrame(connection)
+		if err != nil {
+			return err
+		}
+
+		switch status, data := frame[0], frame[1:]; status {
+		case AuthChallenge:
+			if message, err = conn.authenticator.Challenge(data); err != nil {
+				return err
+			}
+		case AuthSuccess:
+			return conn.authenticator.Complete(data)
+		case AuthFailure:
+			if len(data) == 0 {
+				return ErrAuthenticationFailed
+			}
+			return fmt.Errorf("%w: %s", ErrAuthenticationFailed, data)
+		default:
+			return fmt.Errorf("unknown authentication status %d", status)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package eventedconnection_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+// authFrame builds a length-prefixed frame as sent during authentication
+func authFrame(payload ...byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(payload))), payload...)
+}
+
+func TestPlainAuthenticator(t *testing.T) {
+	auth := &PlainAuthenticator{Identity: "admin", Username: "user", Password: "secret"}
+	message, err := auth.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(message), "admin\x00user\x00secret")
+
+	auth = &PlainAuthenticator{Username: "user", Password: "secret"}
+	message, _ = auth.Start()
+	assertEqual(t, string(message), "\x00user\x00secret")
+
+	if _, err = (&PlainAuthenticator{Password: "secret"}).Start(); err == nil {
+		t.Fatal("Expected an error without a username")
+	}
+}
+
+func TestClient_Authenticator(t *testing.T) {
+	plain := []byte("\x00user\x00secret")
+	done := make(chan bool)
+	l, err := testutils.ScriptedServer(done,
+		testutils.Accept(), testutils.Receive(int64(len(authFrame(plain...)))),
+		testutils.Send(authFrame(AuthSuccess)), testutils.Echo(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:      l.Addr().String(),
+		Authenticator: &PlainAuthenticator{Username: "user", Password: "secret"},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "hello")
+}
+
+func TestClient_AuthenticatorRejected(t *testing.T) {
+	plain := []byte("\x00user\x00wrong")
+	done := make(chan bool)
+	l, err := testutils.ScriptedServer(done,
+		testutils.Accept(), testutils.Receive(int64(len(authFrame(plain...)))),
+		testutils.Send(authFrame(append([]byte{AuthFailure}, "bad password"...)...)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:      l.Addr().String(),
+		Authenticator: &PlainAuthenticator{Username: "user", Password: "wrong"},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	err = con.Connect()
+	if !errors.Is(err, ErrAuthenticationFailed) {
+		t.Fatalf("Expected ErrAuthenticationFailed, got %v", err)
+	}
+	assertEqual(t, err.Error(), "authentication failed: bad password")
+	assertEqual(t, ClassifyError(err), ErrorFatal)
+	assertEqual(t, con.IsActive(), false)
+}
+
+// challengeAuthenticator answers every challenge with it reversed and records the outcome
+type challengeAuthenticator struct {
+	challenges []string
+	outcome    string
+}
+
+func (a *challengeAuthenticator) Start() ([]byte, error) {
+	return []byte("hi"), nil
+}
+
+func (a *challengeAuthenticator) Challenge(challenge []byte) ([]byte, error) {
+	a.challenges = append(a.challenges, string(challenge))
+	response := make([]byte, len(challenge))
+	for i, b := range challenge {
+		response[len(challenge)-1-i] = b
+	}
+	return response, nil
+}
+
+func (a *challengeAuthenticator) Complete(outcome []byte) error {
+	a.outcome = string(outcome)
+	return nil
+}
+
+func TestClient_AuthenticatorChallenge(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.ScriptedServer(done,
+		testutils.Accept(), testutils.Receive(int64(len(authFrame([]byte("hi")...)))),
+		testutils.Send(authFrame(AuthChallenge, 'a', 'b', 'c')),
+		testutils.Receive(int64(len(authFrame([]byte("cba")...)))),
+		testutils.Send(authFrame(AuthSuccess, 'o', 'k')),
+		testutils.Send([]byte("welcome")), testutils.Echo(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	auth := &challengeAuthenticator{}
+	conf := Config{Endpoint: l.Addr().String(), Authenticator: auth}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(auth.challenges), 1)
+	assertEqual(t, auth.challenges[0], "abc")
+	assertEqual(t, auth.outcome, "ok")
+
+	// data sent right after the exchange is delivered
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "welcome")
+}
@@ -0,0 +1,150 @@
+package eventedconnection
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultCircuitBreakerCooldown is the default time an open circuit breaker rejects dials for
+const DefaultCircuitBreakerCooldown = 30 * time.Second
+
+// CircuitState is the state of the circuit breaker guarding dial attempts, see
+// Config.CircuitBreakerThreshold
+type CircuitState int
+
+const (
+	// CircuitClosed lets every dial through
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects dials with ErrCircuitOpen until the cooldown has passed
+	CircuitOpen
+	// CircuitHalfOpen lets a single probing dial through, whose outcome closes or
+	// reopens the circuit
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// circuitBreaker opens after threshold consecutive dial failures and stays open for
+// cooldown, after which a single dial probes whether the endpoint is back
+type circuitBreaker struct {
+	mutex     sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     CircuitState
+	failures  int // consecutive failed dials
+	openedAt  time.Time
+	probing   bool // a half-open probe is in flight
+}
+
+// allow reports whether a dial may be attempted at now, moving an open breaker to
+// half-open once its cooldown has passed. A half-open breaker lets a single probe
+// through and rejects other dials until record is called with its outcome. It returns
+// the state before and after.
+func (b *circuitBreaker) allow(now time.Time) (bool, CircuitState, CircuitState) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	from := b.state
+	switch b.state {
+	case CircuitOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false, from, from
+		}
+		b.state = CircuitHalfOpen
+	case CircuitHalfOpen:
+		if b.probing {
+			return false, from, from
+		}
+	}
+	b.probing = b.state == CircuitHalfOpen
+	return true, from, b.state
+}
+
+// record records the outcome of a dial at now and returns the state before and after
+func (b *circuitBreaker) record(now time.Time, err error) (CircuitState, CircuitState) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	from := b.state
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		b.state = CircuitClosed
+		return from, b.state
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = now
+	}
+	return from, b.state
+}
+
+// retryIn returns how long an open breaker keeps rejecting dials
+func (b *circuitBreaker) retryIn(now time.Time) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state != CircuitOpen {
+		return 0
+	}
+	return max(b.cooldown-now.Sub(b.openedAt), 0)
+}
+
+// CircuitState returns the state of the circuit breaker guarding dial attempts.
+// It is always CircuitClosed unless Config.CircuitBreakerThreshold is set.
+func (conn *Client) CircuitState() CircuitState {
+	if conn.breaker == nil {
+		return CircuitClosed
+	}
+
+	conn.breaker.mutex.Lock()
+	defer conn.breaker.mutex.Unlock()
+	return conn.breaker.state
+}
+
+// dialThroughBreaker dials unless the circuit breaker is open, in which case it
+// fails with ErrCircuitOpen, and records the outcome with the breaker
+func (conn *Client) dialThroughBreaker(ctx context.Context) (net.Conn, string, error) {
+	if conn.breaker == nil {
+		return conn.dial(ctx)
+	}
+
+	allowed, from, to := conn.breaker.allow(conn.clock.Now())
+	conn.circuitChanged(from, to)
+	if !allowed {
+		return nil, "", ErrCircuitOpen
+	}
+
+	connection, endpoint, err := conn.dial(ctx)
+	conn.circuitChanged(conn.breaker.record(conn.clock.Now(), err))
+	return connection, endpoint, err
+}
+
+// circuitChanged reports a transition of the circuit breaker
+func (conn *Client) circuitChanged(from, to CircuitState) {
+	if from == to {
+		return
+	}
+
+	if to == CircuitOpen {
+		conn.stats.recordCircuitOpen()
+	}
+	conn.logger.Info("circuit breaker "+to.String(), slog.String("from", from.String()))
+	conn.emit(CircuitBreakerEvent{Origin: conn.origin(), From: from, To: to})
+}
@@ -0,0 +1,124 @@
+package eventedconnection_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	errDown := errors.New("endpoint down")
+	down := true
+	dials := 0
+	conf := Config{
+		Endpoint: l.Addr().String(),
+		BeforeConnectHook: func(params *DialParams) error {
+			dials++
+			if down {
+				return errDown
+			}
+			return nil
+		},
+		CircuitBreakerThreshold: 2,
+		CircuitBreakerCooldown:  100 * time.Millisecond,
+		EventsBufferSize:        64,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	assertEqual(t, con.Connect(), errDown)
+	assertEqual(t, con.CircuitState(), CircuitClosed)
+	assertEqual(t, con.Reconnect(), errDown)
+	assertEqual(t, con.CircuitState(), CircuitOpen)
+	assertEqual(t, con.GetStats().CircuitOpens, uint64(1))
+
+	// the open circuit doesn't dial at all
+	assertEqual(t, con.Reconnect(), ErrCircuitOpen)
+	assertEqual(t, dials, 2)
+
+	// after the cooldown a probe is let through and closes the circuit
+	time.Sleep(120 * time.Millisecond)
+	down = false
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.CircuitState(), CircuitClosed)
+
+	var transitions []CircuitState
+	for len(con.Events) > 0 {
+		if event, ok := (<-con.Events).(CircuitBreakerEvent); ok {
+			transitions = append(transitions, event.To)
+		}
+	}
+	assertEqual(t, len(transitions), 3)
+	assertEqual(t, transitions[0], CircuitOpen)
+	assertEqual(t, transitions[1], CircuitHalfOpen)
+	assertEqual(t, transitions[2], CircuitClosed)
+}
+
+func TestCircuitBreaker_HalfOpen(t *testing.T) {
+	errDown := errors.New("endpoint down")
+	now := time.Now()
+	breaker := NewCircuitBreaker(1, time.Minute)
+
+	// allowConcurrently makes n dials at once and returns how many were let through
+	allowConcurrently := func(n int) int {
+		var wg sync.WaitGroup
+		allowed := make(chan bool, n)
+		for range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				allowed <- breaker.Allow(now)
+			}()
+		}
+		wg.Wait()
+		close(allowed)
+
+		probes := 0
+		for ok := range allowed {
+			if ok {
+				probes++
+			}
+		}
+		return probes
+	}
+
+	assertEqual(t, breaker.Allow(now), true)
+	breaker.Record(now, errDown)
+	assertEqual(t, breaker.State(), CircuitOpen)
+	assertEqual(t, allowConcurrently(8), 0)
+
+	// after the cooldown only one of the concurrent dials probes; the others are
+	// rejected while it is in flight
+	now = now.Add(time.Minute)
+	assertEqual(t, allowConcurrently(8), 1)
+	assertEqual(t, breaker.State(), CircuitHalfOpen)
+	assertEqual(t, allowConcurrently(8), 0)
+
+	// a failed probe reopens the circuit
+	breaker.Record(now, errDown)
+	assertEqual(t, breaker.State(), CircuitOpen)
+	assertEqual(t, allowConcurrently(8), 0)
+
+	// a successful probe closes it, letting every dial through again
+	now = now.Add(time.Minute)
+	assertEqual(t, allowConcurrently(8), 1)
+	breaker.Record(now, nil)
+	assertEqual(t, breaker.State(), CircuitClosed)
+	assertEqual(t, allowConcurrently(8), 8)
+}
@@ -0,0 +1,63 @@
+package eventedconnection
+
+import "sync"
+
+// broadcaster fans values out to any number of subscriber channels. Sends never
+// block: a subscriber whose channel is full misses the value.
+type broadcaster[T any] struct {
+	mutex       sync.Mutex
+	subscribers map[chan T]struct{}
+}
+
+// subscribe registers a new channel with the given buffer size. The returned
+// function unsubscribes and closes the channel; it is safe to call more than once.
+func (b *broadcaster[T]) subscribe(size int) (<-chan T, func()) {
+	ch := make(chan T, size)
+
+	b.mutex.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[chan T]struct{})
+	}
+	b.subscribers[ch] = struct{}{}
+	b.mutex.Unlock()
+
+	return ch, func() { b.unsubscribe(ch) }
+}
+
+// unsubscribe unsubscribes and closes ch, a channel returned by subscribe. It does
+// nothing if ch isn't subscribed (anymore).
+func (b *broadcaster[T]) unsubscribe(ch <-chan T) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for subscriber := range b.subscribers {
+		if subscriber == ch {
+			delete(b.subscribers, subscriber)
+			close(subscriber)
+			return
+		}
+	}
+}
+
+// any reports whether there are subscribers
+func (b *broadcaster[T]) any() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.subscribers) > 0
+}
+
+// publish sends v to every subscriber and returns the number of subscribers
+// that missed it because their channel was full.
+func (b *broadcaster[T]) publish(v T) (dropped int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- v:
+		default:
+			dropped++
+		}
+	}
+	return dropped
+}
@@ -0,0 +1,115 @@
+package eventedconnection
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// captureMagic starts every capture stream so that files can be identified
+const captureMagic = "EVCAP1\n"
+
+// CaptureRecord is a chunk of data read from or written to the connection
+// along with the time it was read or written.
+type CaptureRecord struct {
+	Direction Direction
+	Time      time.Time
+	Data      []byte
+}
+
+// CaptureWriter writes capture records to an io.Writer in a simple binary
+// format: a magic header followed by one record per read or write, each being a
+// direction byte, the timestamp in Unix nanoseconds (8 bytes, big endian), the data
+// length (4 bytes, big endian) and the data itself. Use ReadCapture to read it back.
+type CaptureWriter struct {
+	mutex  sync.Mutex
+	w      io.Writer
+	header bool
+}
+
+// NewCaptureWriter returns a CaptureWriter writing to w
+func NewCaptureWriter(w io.Writer) *CaptureWriter {
+	return &CaptureWriter{w: w}
+}
+
+// WriteRecord appends a record to the capture
+func (cw *CaptureWriter) WriteRecord(record CaptureRecord) error {
+	cw.mutex.Lock()
+	defer cw.mutex.Unlock()
+
+	if !cw.header {
+		if _, err := io.WriteString(cw.w, captureMagic); err != nil {
+			return err
+		}
+		cw.header = true
+	}
+
+	header := make([]byte, 13)
+	header[0] = byte(record.Direction)
+	binary.BigEndian.PutUint64(header[1:9], uint64(record.Time.UnixNano()))
+	binary.BigEndian.PutUint32(header[9:13], uint32(len(record.Data)))
+
+	if _, err := cw.w.Write(header); err != nil {
+		return err
+	}
+
+	_, err := cw.w.Write(record.Data)
+	return err
+}
+
+// ReadCapture reads all records written by a CaptureWriter
+func ReadCapture(r io.Reader) ([]CaptureRecord, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(captureMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil // empty capture
+		}
+		return nil, err
+	}
+
+	if string(magic) != captureMagic {
+		return nil, errors.New("not a capture: invalid header")
+	}
+
+	var records []CaptureRecord
+	header := make([]byte, 13)
+	for {
+		if _, err := io.ReadFull(br, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			return records, fmt.Errorf("truncated capture record: %w", err)
+		}
+
+		data := make([]byte, binary.BigEndian.Uint32(header[9:13]))
+		if _, err := io.ReadFull(br, data); err != nil {
+			return records, fmt.Errorf("truncated capture record: %w", err)
+		}
+
+		records = append(records, CaptureRecord{
+			Direction: Direction(header[0]),
+			Time:      time.Unix(0, int64(binary.BigEndian.Uint64(header[1:9]))),
+			Data:      data,
+		})
+	}
+}
+
+// capture records data read from or written to the connection if capturing is enabled
+func (conn *Client) capture(direction Direction, data []byte) {
+	if conn.captureWriter == nil || len(data) == 0 {
+		return
+	}
+
+	record := CaptureRecord{Direction: direction, Time: conn.clock.Now(), Data: data}
+	conn.callHook("Capture", func() {
+		if err := conn.captureWriter.WriteRecord(record); err != nil {
+			conn.handleError(fmt.Errorf("unable to write capture: %w", err))
+		}
+	})
+}
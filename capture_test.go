@@ -0,0 +1,146 @@
+package eventedconnection_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_CaptureAndReplay(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	capture := &bytes.Buffer{}
+	conf := Config{
+		Endpoint:    l.Addr().String(),
+		ReadTimeout: 1 * time.Second,
+		Capture:     capture,
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("Testing capture")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-con.Read:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+	con.Close()
+
+	records, err := ReadCapture(bytes.NewReader(capture.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if assertEqual(t, len(records), 2); len(records) != 2 {
+		return
+	}
+	assertEqual(t, records[0].Direction, DirectionWrite)
+	assertEqual(t, records[1].Direction, DirectionRead)
+	assertEqual(t, string(records[1].Data), string(payload))
+	assertEqual(t, records[1].Time.Before(records[0].Time), false)
+
+	// replaying the capture makes the endpoint answer the same way without an echo server
+	replayDone := make(chan bool)
+	rl, err := testutils.ReplayServer(replayDone, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(replayDone)
+
+	conf = Config{Endpoint: rl.Addr().String(), ReadTimeout: 1 * time.Second}
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), string(payload))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the replayed data")
+	}
+}
+
+func TestClient_ReadTee(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	for _, teeOnly := range []bool{false, true} {
+		tee := &syncBuffer{}
+		conf := Config{
+			Endpoint:      l.Addr().String(),
+			ReadTimeout:   1 * time.Second,
+			WriteTimeout:  1 * time.Second,
+			ReadTee:       tee,
+			ReadTeeOnly:   teeOnly,
+			AfterReadHook: func(data []byte) ([]byte, error) { return append(data, '!'), nil },
+			OnErrorHook:   func(err error) error { return err },
+		}
+
+		con, err := NewClient(&conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = con.Connect(); err != nil {
+			t.Fatal(err)
+		}
+
+		payload := []byte("Testing tee")
+		if err = con.Write(&payload); err != nil {
+			t.Fatal(err)
+		}
+
+		if teeOnly {
+			deadline := time.Now().Add(2 * time.Second)
+			for tee.String() != string(payload) && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			select {
+			case data := <-con.Read:
+				t.Errorf("Expected nothing on the Read channel, got %s", *data)
+			default:
+			}
+		} else {
+			select {
+			case data := <-con.Read:
+				assertEqual(t, string(*data), "Testing tee!")
+			case <-time.After(2 * time.Second):
+				t.Fatal("Test timed out while waiting to read from connection")
+			}
+		}
+		assertEqual(t, tee.String(), string(payload))
+		con.Close()
+	}
+}
@@ -0,0 +1,238 @@
+//go:build chaos
+
+package eventedconnection
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// ChaosPolicy describes the faults injected into a client's reads and writes by
+// EnableChaos. Each read or write suffers at most one fault, picked at random with
+// the given probabilities, which must not add up to more than 1.
+type ChaosPolicy struct {
+	// Seed seeds the random source picking the faults, so that a run can be repeated
+	Seed uint64
+
+	// Reads and Writes select the operations faults are injected into; both are
+	// affected if neither is set
+	Reads  bool
+	Writes bool
+
+	// DelayProbability is the chance of a read or write being delayed by up to MaxDelay
+	DelayProbability float64
+	MaxDelay         time.Duration
+	// DropProbability is the chance of the data read or written being discarded
+	DropProbability float64
+	// DuplicateProbability is the chance of the data read or written being repeated
+	DuplicateProbability float64
+	// TruncateProbability is the chance of only a part of the data read or written
+	// getting through, while the rest is discarded
+	TruncateProbability float64
+}
+
+// chaosFault is a fault picked by a chaosState
+type chaosFault int
+
+const (
+	chaosNone chaosFault = iota
+	chaosDelay
+	chaosDrop
+	chaosDuplicate
+	chaosTruncate
+)
+
+var chaosFaultNames = [...]string{
+	chaosNone:      "none",
+	chaosDelay:     "delay",
+	chaosDrop:      "drop",
+	chaosDuplicate: "duplicate",
+	chaosTruncate:  "truncate",
+}
+
+// chaosState injects the faults of the policy set with EnableChaos, if any
+type chaosState struct {
+	mutex   sync.Mutex
+	policy  *ChaosPolicy
+	random  *rand.Rand
+	pending []byte   // a read duplicated by the last fault, returned by the next read
+	from    net.Conn // the connection pending was read from
+}
+
+// EnableChaos injects random faults into the client's reads and writes, as described
+// by policy, to test how the application copes with a misbehaving network. It is only
+// available when built with the chaos build tag (go test -tags chaos). Faults are
+// injected until DisableChaos is called, across reconnects.
+func (conn *Client) EnableChaos(policy ChaosPolicy) error {
+	probabilities := []float64{policy.DelayProbability, policy.DropProbability,
+		policy.DuplicateProbability, policy.TruncateProbability}
+	var total float64
+	for _, p := range probabilities {
+		if p < 0 {
+			return errors.New("chaos probabilities must not be negative")
+		}
+		total += p
+	}
+	if total > 1 {
+		return errors.New("chaos probabilities add up to more than 1")
+	}
+	if policy.MaxDelay < 0 {
+		return errors.New("MaxDelay must not be negative")
+	}
+	if !policy.Reads && !policy.Writes {
+		policy.Reads, policy.Writes = true, true
+	}
+
+	conn.chaos.mutex.Lock()
+	defer conn.chaos.mutex.Unlock()
+	conn.chaos.policy = &policy
+	conn.chaos.random = rand.New(rand.NewPCG(policy.Seed, policy.Seed))
+	return nil
+}
+
+// DisableChaos stops injecting faults, see EnableChaos
+func (conn *Client) DisableChaos() {
+	conn.chaos.mutex.Lock()
+	defer conn.chaos.mutex.Unlock()
+	conn.chaos.policy = nil
+	conn.chaos.pending = nil
+}
+
+// pick returns the fault to inject into the next read (or write), along with a random
+// number in [0, n) for sizing it
+func (c *chaosState) pick(read bool, n int) (chaosFault, time.Duration, int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	p := c.policy
+	if p == nil || (read && !p.Reads) || (!read && !p.Writes) {
+		return chaosNone, 0, 0
+	}
+
+	var delay time.Duration
+	if p.MaxDelay > 0 {
+		delay = time.Duration(c.random.Int64N(int64(p.MaxDelay) + 1))
+	}
+	size := 0
+	if n > 0 {
+		size = c.random.IntN(n)
+	}
+
+	roll := c.random.Float64()
+	for _, f := range []struct {
+		fault       chaosFault
+		probability float64
+	}{
+		{chaosDelay, p.DelayProbability},
+		{chaosDrop, p.DropProbability},
+		{chaosDuplicate, p.DuplicateProbability},
+		{chaosTruncate, p.TruncateProbability},
+	} {
+		if roll < f.probability {
+			return f.fault, delay, size
+		}
+		roll -= f.probability
+	}
+	return chaosNone, 0, 0
+}
+
+// takePending returns the duplicated read waiting to be returned again for connection
+func (c *chaosState) takePending(connection net.Conn) []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	pending := c.pending
+	c.pending = nil
+	if c.from != connection {
+		return nil // left over from an earlier connection
+	}
+	return pending
+}
+
+func (c *chaosState) setPending(connection net.Conn, data []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.pending = append(c.pending[:0], data...)
+	c.from = connection
+}
+
+// chaosRead reads from connection into buffer, injecting faults as configured with
+// EnableChaos
+func (conn *Client) chaosRead(connection net.Conn, buffer []byte) (int, error) {
+	if pending := conn.chaos.takePending(connection); len(pending) > 0 {
+		return copy(buffer, pending), nil
+	}
+
+	for {
+		n, err := connection.Read(buffer)
+		if n == 0 {
+			return n, err
+		}
+
+		fault, delay, size := conn.chaos.pick(true, n)
+		conn.logFault(DirectionRead, fault)
+		switch fault {
+		case chaosDelay:
+			conn.chaosSleep(delay)
+		case chaosDrop:
+			if err != nil {
+				return 0, err
+			}
+			continue // read again
+		case chaosDuplicate:
+			conn.chaos.setPending(connection, buffer[:n])
+		case chaosTruncate:
+			n = max(size, 1)
+		}
+		return n, err
+	}
+}
+
+// chaosWrite writes payload to connection, injecting faults as configured with
+// EnableChaos. Dropped and truncated writes report success.
+func (conn *Client) chaosWrite(connection net.Conn, payload []byte) (int, error) {
+	fault, delay, size := conn.chaos.pick(false, len(payload))
+	conn.logFault(DirectionWrite, fault)
+	switch fault {
+	case chaosDelay:
+		conn.chaosSleep(delay)
+	case chaosDrop:
+		return len(payload), nil
+	case chaosDuplicate:
+		n, err := connection.Write(payload)
+		if err != nil {
+			return n, err
+		}
+		if _, err = connection.Write(payload); err != nil {
+			return n, err
+		}
+		return n, nil
+	case chaosTruncate:
+		if _, err := connection.Write(payload[:max(size, 1)]); err != nil {
+			return 0, err
+		}
+		return len(payload), nil
+	}
+	return connection.Write(payload)
+}
+
+func (conn *Client) chaosSleep(d time.Duration) {
+	timer := conn.clock.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+	case <-conn.done:
+	}
+}
+
+func (conn *Client) logFault(direction Direction, fault chaosFault) {
+	if fault == chaosNone {
+		return
+	}
+	conn.logger.Debug("injecting fault", slog.String("fault", chaosFaultNames[fault]),
+		slog.String("direction", direction.String()))
+}
@@ -0,0 +1,89 @@
+//go:build chaos
+
+package eventedconnection_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_Chaos(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String()}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNotNil(t, con.EnableChaos(ChaosPolicy{DropProbability: 0.6, TruncateProbability: 0.6}))
+
+	// dropped writes never reach the endpoint
+	if err = con.EnableChaos(ChaosPolicy{Seed: 1, Writes: true, DropProbability: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("lost"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		t.Fatalf("unexpected read of %q", *data)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// duplicated reads are delivered twice
+	if err = con.EnableChaos(ChaosPolicy{Seed: 1, Reads: true, DuplicateProbability: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("twice"); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		select {
+		case data := <-con.Read:
+			assertEqual(t, string(*data), "twice")
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting to read from connection")
+		}
+	}
+
+	// truncated writes only get partly through
+	if err = con.EnableChaos(ChaosPolicy{Seed: 1, Writes: true, TruncateProbability: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("truncated"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		if len(*data) >= len("truncated") || !strings.HasPrefix("truncated", string(*data)) {
+			t.Errorf("Expected a truncated write, read %q", *data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+
+	con.DisableChaos()
+	if err = con.WriteString("intact"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), "intact")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+}
@@ -0,0 +1,42 @@
+package eventedconnection
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// checksumSize is the size of the CRC-32 appended to every frame
+const checksumSize = 4
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// newChecksumLayer returns a framedLayer appending a CRC-32 (Castagnoli) of every message
+// to its frame and validating it on frames read. Frames that fail validation are passed
+// to the OnChecksumErrorHook, if any, and otherwise close the connection with
+// ErrChecksumMismatch.
+func (conn *Client) newChecksumLayer() *framedLayer {
+	return &framedLayer{
+		encode: func(data []byte) ([]byte, error) {
+			payload := make([]byte, 0, len(data)+checksumSize)
+			payload = append(payload, data...)
+			return binary.BigEndian.AppendUint32(payload, crc32.Checksum(data, checksumTable)), nil
+		},
+		decode: func(payload []byte) ([]byte, error) {
+			if len(payload) >= checksumSize {
+				message, sum := payload[:len(payload)-checksumSize], payload[len(payload)-checksumSize:]
+				if crc32.Checksum(message, checksumTable) == binary.BigEndian.Uint32(sum) {
+					return message, nil
+				}
+			}
+
+			conn.stats.recordChecksumError()
+			if conn.onChecksumErrorHook == nil {
+				return nil, ErrChecksumMismatch
+			}
+			if err := conn.onChecksumErrorHook(payload); err != nil {
+				return nil, err
+			}
+			return nil, errSkipFrame
+		},
+	}
+}
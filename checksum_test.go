@@ -0,0 +1,148 @@
+package eventedconnection_test
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func checksumFrame(message string, corrupt bool) []byte {
+	payload := binary.BigEndian.AppendUint32([]byte(message), crc32.Checksum([]byte(message), crc32.MakeTable(crc32.Castagnoli)))
+	if corrupt {
+		payload[0] ^= 0xff
+	}
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(payload))), payload...)
+}
+
+// sendingServer accepts a single connection, writes data to it and keeps it open
+func sendingServer(t *testing.T, data []byte) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.Write(data)
+		time.Sleep(time.Second)
+	}()
+
+	return l
+}
+
+func TestClient_Checksum(t *testing.T) {
+	var data []byte
+	data = append(data, checksumFrame("first", false)...)
+	data = append(data, checksumFrame("garbled", true)...)
+	data = append(data, checksumFrame("second", false)...)
+	l := sendingServer(t, data)
+	defer l.Close()
+
+	corrupted := make(chan []byte, 1)
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		Checksum:     true,
+		OnChecksumErrorHook: func(frame []byte) error {
+			corrupted <- frame
+			return nil
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case message := <-con.Read:
+			assertEqual(t, string(*message), expected)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %q", expected)
+		}
+	}
+	assertEqual(t, len(<-corrupted), len("garbled")+4)
+	assertEqual(t, con.GetStats().ChecksumErrors, uint64(1))
+	assertEqual(t, con.IsActive(), true)
+}
+
+func TestClient_ChecksumMismatch(t *testing.T) {
+	l := sendingServer(t, checksumFrame("garbled", true))
+	defer l.Close()
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		Checksum:     true,
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-con.Disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("expected a corrupted frame to close the connection")
+	}
+	assertEqual(t, con.Err(), ErrChecksumMismatch)
+}
+
+func TestClient_ChecksumWithEncryption(t *testing.T) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{
+		Endpoint:      l.Addr().String(),
+		ReadTimeout:   2 * time.Second,
+		WriteTimeout:  1 * time.Second,
+		Checksum:      true,
+		EncryptionKey: testEncryptionKey,
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("checked secret")
+	if err = con.Write(&data); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-con.Read:
+		assertEqual(t, string(*message), "checked secret")
+	case <-time.After(time.Second):
+		t.Fatal("expected a message")
+	}
+}
@@ -1,38 +1,181 @@
 package eventedconnection
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"net"
+	"slices"
 	"sync"
 	"time"
+	"unsafe"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // Client gives us a stable way to connect and maintain a connection to a TCP endpoint.
 // Client broadcasts 2 separate events via closing a channel: Connected and Disconnected.
 // This allows any number of downstream consumers to be informed when a state change happens.
 type Client struct {
-	Read         chan *[]byte
+	// Read receives the messages read from the connection unless Config.ValueReads is set.
+	//
+	// Deprecated: set Config.ValueReads and use Data instead. Read is only still needed
+	// with Config.PooledReads, whose buffers are handed back with Release.
+	Read chan *[]byte
+	// Data receives messages as values instead of Read when Config.ValueReads is set.
+	// Only one of the two channels is used; the other one is nil.
+	Data         chan []byte
 	Disconnected chan struct{}
 	Connected    chan struct{}
 
+	// Events carries every lifecycle event (see Event) in order. Unlike Connected and
+	// Disconnected it is not replaced by Reconnect. Events are dropped if it is full.
+	Events chan Event
+
 	c                 net.Conn
 	connectionTimeout time.Duration
 	readTimeout       time.Duration
 	writeTimeout      time.Duration
 	endpoint          string
 	readBufferSize    int
+	enableNagle       bool
+	settingsMutex     sync.RWMutex // guards the settings that can be changed at runtime
+	writeMutex        sync.Mutex   // serializes writes
+	writeFailure      error        // closes the connection once writeMutex is unlocked, see unlockWrites
+	connectMutex      sync.Mutex   // serializes Connect and Reconnect
+	readMutex         sync.Mutex   // serializes stream style reads of pending
+	pending           []byte       // rest of a message partially consumed by a stream style read
+
+	afterReadHook          AfterReadHook
+	keepReadingOnHookError bool
+	beforeWriteHook        BeforeWriteHook
+	beforeConnectHook      BeforeConnectHook
+	afterConnectHook       AfterConnectHook
+	beforeDisconnectHook   BeforeDisconnectHook
+	onErrorHook            OnErrorHook
+	onReadTimeoutHook      OnReadTimeoutHook
+	onIdleHook             OnIdleHook
+	idleTimeout            time.Duration
+	idleAction             IdleAction
+	idlePingPayload        []byte
+	watchdogTimeout        time.Duration
+	onWatchdogHook         OnWatchdogHook
+	onRatesHook            OnRatesHook
+	keepaliveInterval      time.Duration
+	keepalivePayload       []byte
+	keepaliveHook          KeepaliveHook
+	maxConnectionAge       time.Duration
+	dialRetries            int
+	dialRetryDelay         time.Duration
+	dialRetryBudget        time.Duration
+	breaker                *circuitBreaker // nil unless Config.CircuitBreakerThreshold is set
+	healthCheck            *healthChecker  // nil unless Config.HealthCheckInterval is set
+	writeQueue             *writeQueue     // nil unless Config.WriteQueueSize is set
+	onWriteExpiredHook     OnWriteExpiredHook
+	acks                   *ackTracker // nil unless Config.AckExtractor is set
+	ackExtractor           AckExtractor
+	sequenceHook           SequenceHook
+	maxDeliveryAttempts    int
+	onDeliveryFailedHook   OnDeliveryFailedHook
+	onMessageHook          OnMessageHook
+	onChecksumErrorHook    OnChecksumErrorHook
+	onSlowConsumerHook     OnSlowConsumerHook
+	readMiddleware         []Middleware
+	writeMiddleware        []Middleware
+	readPipeline           *pipeline      // nil without read middleware, built by NewClient
+	writePipeline          *pipeline      // nil without write middleware, built by NewClient
+	writeTarget            writeTarget    // where the write passing through writePipeline goes
+	layers                 []*framedLayer // built-in framing layers, see addLayer
+	rateLimiter            *rateLimiter   // nil unless a write rate limit is configured
+	writeErrorPolicy       WriteErrorPolicy
+	consumerMonitor        *consumerMonitor // nil unless slow consumer detection is enabled
+	readBuffers            *readBufferPool  // nil unless Config.PooledReads is set
+	ring                   *RingBuffer      // nil unless Config.RingBufferSize is set
+	replay                 *replayBuffer    // nil unless Config.ReplayBufferSize is set
+
+	useTLS               bool
+	tlsConfig            *tls.Config
+	certReloader         *certReloader
+	caFile               string
+	getClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+	pins                 *certificatePins
+	nextProtos           []string
+	tlsMinVersion        uint16
+	tlsMaxVersion        uint16
+	tlsCipherSuites      []uint16
+	certExpiryHook       CertExpiryHook
+	certExpiryWarning    time.Duration
+	resolver             *net.Resolver
+	transport            Transport
+
+	id     string
+	labels map[string]string
+	clock  Clock
+	random *randomSource
+	chaos  chaosState // faults injected in chaos builds, see EnableChaos
+
+	srvService string
+	srvProto   string
+	srvName    string
+
+	remoteEndpoint    string    // host:port of the current connection
+	connectedAt       time.Time // when the current connection was established
+	generation        uint64    // incremented for every established and every closed connection
+	reconnectAttempts int       // calls to Reconnect since the last successful one
+	reconnecting      bool      // set while the automatic reconnect loop runs
+	draining          bool      // set by CloseGracefully until the next connection
+	autoReconnect     bool
+	reconnectDelay    time.Duration
+	maxReconnectDelay time.Duration
+	classifyError     ErrorClassifier
+	disconnectErr     error // cause of the last disconnect, see Err
+	startTLSHook      StartTLSHook
+	authenticator     Authenticator
+	pskIdentity       string
+	psk               []byte
+
+	maxReconnectAttempts     int
+	maxReconnectDuration     time.Duration
+	onReconnectAttemptHook   OnReconnectAttemptHook
+	onReconnectExhaustedHook OnReconnectExhaustedHook
+
+	stateMutex        sync.Mutex
+	state             State
+	stateChanges      broadcaster[StateChange]
+	eventSubscribers  broadcaster[Event]
+	subscribers       broadcaster[[]byte] // see Subscribe
+	onStateChangeHook OnStateChangeHook
+
+	routines       goroutineGroup // goroutines serving connections, see WaitClosed
+	clientRoutines goroutineGroup // goroutines running until Shutdown
 
-	afterReadHook        AfterReadHook
-	afterConnectHook     AfterConnectHook
-	beforeDisconnectHook BeforeDisconnectHook
-	onErrorHook          OnErrorHook
+	stats stats
 
-	useTLS    bool
-	tlsConfig *tls.Config
+	logger         *slog.Logger
+	hexDumpEnabled bool
+	hexDumpLimit   int
+	hexDumpHook    HexDumpHook
+	captureWriter  *CaptureWriter
+	readTee        io.Writer
+	readTeeOnly    bool
+	tracer         trace.Tracer
+	connectionSpan trace.Span // spans the lifetime of the current connection
 
-	closer  sync.Once
+	done     chan struct{} // closed by Shutdown
+	shutdown sync.Once
+
+	config Config // the Config the client was created from, see EffectiveConfig
+
+	closing bool // set once Close starts, until reset
 	starter sync.Once
+	pause   *readerPause // set while the read loop is asked to stand still (e.g. during UpgradeTLS)
 
 	mutex *sync.RWMutex // allows for using this connection in multiple goroutines
 }
@@ -61,70 +204,376 @@ func (conn *Client) setDefaults() {
 	if conn.onErrorHook == nil {
 		conn.onErrorHook = defaultOnErrorHook
 	}
+
+	if conn.hexDumpLimit == 0 {
+		conn.hexDumpLimit = DefaultHexDumpLimit
+	}
+
+	if conn.certExpiryWarning == 0 {
+		conn.certExpiryWarning = DefaultCertExpiryWarning
+	}
+
+	if conn.reconnectDelay == 0 {
+		conn.reconnectDelay = DefaultReconnectDelay
+	}
+
+	if conn.maxReconnectDelay == 0 {
+		conn.maxReconnectDelay = DefaultMaxReconnectDelay
+	}
+
+	if conn.dialRetryDelay == 0 {
+		conn.dialRetryDelay = DefaultDialRetryDelay
+	}
+
+	if conn.classifyError == nil {
+		conn.classifyError = ClassifyError
+	}
+
+	if conn.maxDeliveryAttempts == 0 {
+		conn.maxDeliveryAttempts = DefaultMaxDeliveryAttempts
+	}
+
+	if conn.clock == nil {
+		conn.clock = systemClock{}
+	}
 }
 
 // NewClient is the Connection constructor.
 func NewClient(conf *Config) (*Client, error) {
-	if len(conf.Endpoint) == 0 {
-		return nil, errors.New("invalid endpoint (empty string)")
+	if err := conf.Validate(); err != nil {
+		return nil, err
 	}
 
 	conn := Client{
-		endpoint:             conf.Endpoint,
-		connectionTimeout:    conf.ConnectionTimeout,
-		readTimeout:          conf.ReadTimeout,
-		writeTimeout:         conf.WriteTimeout,
-		readBufferSize:       conf.ReadBufferSize,
-		afterReadHook:        conf.AfterReadHook,
-		afterConnectHook:     conf.AfterConnectHook,
-		beforeDisconnectHook: conf.BeforeDisconnectHook,
-		onErrorHook:          conf.OnErrorHook,
-		Disconnected:         make(chan struct{}),
-		Connected:            make(chan struct{}),
-		Read:                 make(chan *[]byte, 4), // 4 packets (up to 4 * conn.ReadBufferSize); reduces blocking when reading from connection
-		mutex:                &sync.RWMutex{},
-	}
-
-	if conf.UseTLS {
-		conn.tlsConfig = conf.TLSConfig
-		conn.useTLS = conf.UseTLS
+		endpoint:                 conf.Endpoint,
+		connectionTimeout:        conf.ConnectionTimeout,
+		readTimeout:              conf.ReadTimeout,
+		writeTimeout:             conf.WriteTimeout,
+		readBufferSize:           conf.ReadBufferSize,
+		enableNagle:              conf.EnableNagle,
+		afterReadHook:            conf.AfterReadHook,
+		keepReadingOnHookError:   conf.KeepReadingOnHookError,
+		beforeWriteHook:          conf.BeforeWriteHook,
+		beforeConnectHook:        conf.BeforeConnectHook,
+		afterConnectHook:         conf.AfterConnectHook,
+		beforeDisconnectHook:     conf.BeforeDisconnectHook,
+		onErrorHook:              conf.OnErrorHook,
+		onReadTimeoutHook:        conf.OnReadTimeoutHook,
+		onIdleHook:               conf.OnIdleHook,
+		idleTimeout:              conf.IdleTimeout,
+		idleAction:               conf.IdleAction,
+		idlePingPayload:          slices.Clone(conf.IdlePingPayload),
+		watchdogTimeout:          conf.WatchdogTimeout,
+		onWatchdogHook:           conf.OnWatchdogHook,
+		onRatesHook:              conf.OnRatesHook,
+		keepaliveInterval:        conf.KeepaliveInterval,
+		keepalivePayload:         slices.Clone(conf.KeepalivePayload),
+		keepaliveHook:            conf.KeepaliveHook,
+		maxConnectionAge:         conf.MaxConnectionAge,
+		onWriteExpiredHook:       conf.OnWriteExpiredHook,
+		ackExtractor:             conf.AckExtractor,
+		sequenceHook:             conf.SequenceHook,
+		maxDeliveryAttempts:      conf.MaxDeliveryAttempts,
+		onDeliveryFailedHook:     conf.OnDeliveryFailedHook,
+		dialRetries:              conf.DialRetries,
+		dialRetryDelay:           conf.DialRetryDelay,
+		dialRetryBudget:          conf.DialRetryBudget,
+		onMessageHook:            conf.OnMessageHook,
+		onChecksumErrorHook:      conf.OnChecksumErrorHook,
+		onSlowConsumerHook:       conf.OnSlowConsumerHook,
+		readMiddleware:           slices.Clone(conf.ReadMiddleware),
+		writeMiddleware:          slices.Clone(conf.WriteMiddleware),
+		onStateChangeHook:        conf.OnStateChangeHook,
+		id:                       conf.ID,
+		labels:                   maps.Clone(conf.Labels),
+		resolver:                 conf.Resolver,
+		transport:                conf.Transport,
+		srvService:               conf.SRVService,
+		srvProto:                 conf.SRVProto,
+		srvName:                  conf.SRVName,
+		startTLSHook:             conf.StartTLSHook,
+		authenticator:            conf.Authenticator,
+		certExpiryHook:           conf.CertExpiryHook,
+		certExpiryWarning:        conf.CertExpiryWarning,
+		pskIdentity:              conf.PSKIdentity,
+		psk:                      slices.Clone(conf.PSK),
+		hexDumpEnabled:           conf.HexDump,
+		hexDumpLimit:             conf.HexDumpLimit,
+		hexDumpHook:              conf.HexDumpHook,
+		readTee:                  conf.ReadTee,
+		readTeeOnly:              conf.ReadTee != nil && conf.ReadTeeOnly,
+		Disconnected:             make(chan struct{}),
+		Connected:                make(chan struct{}),
+		mutex:                    &sync.RWMutex{},
+		done:                     make(chan struct{}),
+		rateLimiter:              newRateLimiter(conf),
+		writeErrorPolicy:         conf.WriteErrorPolicy,
+		autoReconnect:            conf.AutoReconnect,
+		reconnectDelay:           conf.ReconnectDelay,
+		maxReconnectDelay:        conf.MaxReconnectDelay,
+		classifyError:            conf.ErrorClassifier,
+		onReconnectAttemptHook:   conf.OnReconnectAttemptHook,
+		maxReconnectAttempts:     conf.MaxReconnectAttempts,
+		maxReconnectDuration:     conf.MaxReconnectDuration,
+		onReconnectExhaustedHook: conf.OnReconnectExhaustedHook,
+		clock:                    conf.Clock,
+		random:                   newRandomSource(conf.RandSource),
+	}
+
+	readChannelSize := conf.ReadChannelSize
+	if readChannelSize == 0 {
+		readChannelSize = DefaultReadChannelSize
+	}
+	if conf.ValueReads {
+		conn.Data = make(chan []byte, readChannelSize)
+	} else {
+		conn.Read = make(chan *[]byte, readChannelSize) // reduces blocking when reading from connection
+	}
+	if conf.RingBufferSize > 0 {
+		conn.ring = newRingBuffer(&conn, conf.RingBufferSize)
+	}
+	if conf.PooledReads {
+		conn.readBuffers = &readBufferPool{}
+	}
+	if conf.ReplayBufferSize > 0 {
+		conn.replay = newReplayBuffer(conf.ReplayBufferSize)
+	}
+	if conf.WriteQueueSize > 0 {
+		var err error
+		if conn.writeQueue, err = newWriteQueue(conf); err != nil {
+			return nil, err
+		}
+	}
+	if conf.AckExtractor != nil {
+		conn.acks = newAckTracker()
+		if conn.writeQueue != nil {
+			// writes loaded from the outbox file keep their numbers, which mustn't be reused
+			conn.acks.restore(conn.writeQueue.numbered())
+		}
+	}
+	if conf.CircuitBreakerThreshold > 0 {
+		conn.breaker = &circuitBreaker{threshold: conf.CircuitBreakerThreshold, cooldown: conf.CircuitBreakerCooldown}
+		if conn.breaker.cooldown == 0 {
+			conn.breaker.cooldown = DefaultCircuitBreakerCooldown
+		}
+	}
+	if conf.HealthCheckInterval > 0 {
+		conn.healthCheck = newHealthChecker(conf)
+	}
+	if conf.SlowConsumerThreshold > 0 {
+		conn.consumerMonitor = &consumerMonitor{threshold: conf.SlowConsumerThreshold}
+	}
+
+	eventsBufferSize := conf.EventsBufferSize
+	if eventsBufferSize == 0 {
+		eventsBufferSize = DefaultEventsBufferSize
+	}
+	conn.Events = make(chan Event, eventsBufferSize)
+
+	conn.config = *conf
+	conn.config.Labels = maps.Clone(conf.Labels)
+	conn.logger = newLogger(conf)
+
+	if conf.Capture != nil {
+		conn.captureWriter = NewCaptureWriter(conf.Capture)
+	}
+
+	tracerProvider := conf.TracerProvider
+	if tracerProvider == nil {
+		tracerProvider = noop.NewTracerProvider()
+	}
+	conn.tracer = tracerProvider.Tracer(tracerName)
+	conn.connectionSpan = noop.Span{}
+
+	conn.setTLSSettings(conf)
+
+	if len(conf.EncryptionKey) > 0 {
+		layer, err := newEncryptionLayer(conf.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		conn.addLayer(layer)
+	}
+	if conf.Checksum {
+		conn.addLayer(conn.newChecksumLayer())
+	}
+
+	if err := conn.setContextHooks(conf); err != nil {
+		return nil, err
+	}
+	conn.recoverHooks()
+
+	if len(conn.readMiddleware) > 0 {
+		conn.readPipeline = newPipeline(conn.readMiddleware, conn.deliver)
+	}
+	if len(conn.writeMiddleware) > 0 {
+		conn.writePipeline = newPipeline(conn.writeMiddleware, func(payload []byte) error {
+			return conn.send(conn.writeTarget.connection, payload, conn.writeTarget.timeout)
+		})
 	}
 
 	conn.setDefaults()
 
+	if len(conf.ExpvarPrefix) > 0 {
+		if err := conn.publishExpvar(conf.ExpvarPrefix); err != nil {
+			return nil, err
+		}
+	}
+
+	if conn.onMessageHook != nil {
+		conn.startDispatcher(conf.OnMessageConcurrency)
+	}
+	if conf.RateWindow > 0 {
+		sampler := newRateSampler(conf)
+		conn.clientRoutines.start(func() { conn.sampleRates(sampler) })
+	}
+
 	return &conn, nil
 }
 
-// Connect attempts to establish a TCP connection to conn.Endpoint.
+// Connect attempts to establish a TCP connection to conn.Endpoint. If the client was
+// configured with an SRV name then the SRV records are looked up on every call and
+// the targets are tried in priority/weight order until one succeeds.
 func (conn *Client) Connect() error {
-	var err error
+	return conn.connect(context.Background())
+}
+
+func (conn *Client) connect(ctx context.Context) error {
+	conn.connectMutex.Lock()
+	connected, err := conn.connectLocked(ctx)
+	conn.connectMutex.Unlock()
+
+	if connected {
+		conn.afterConnect() // without connectMutex, so the hook can call Reconnect
+	}
+	return err
+}
+
+// connectLocked does the work of Connect and reports whether it established a new
+// connection, for which the caller must call afterConnect once it has released
+// conn.connectMutex. conn.connectMutex must be held.
+func (conn *Client) connectLocked(ctx context.Context) (connected bool, err error) {
+	if conn.isShutdown() {
+		return false, ErrShutdown
+	}
+
 	var connection net.Conn
 
 	conn.starter.Do(func() {
-		if conn.useTLS {
-			connection, err = tls.Dial("tcp", conn.endpoint, conn.tlsConfig)
-		} else {
-			connection, err = net.DialTimeout("tcp", conn.endpoint, conn.connectionTimeout)
-		}
+		var span trace.Span
+		ctx, span = conn.startSpan(ctx, "eventedconnection.Connect",
+			trace.WithAttributes(attribute.String("server.address", conn.GetEndpoint())))
+		defer span.End()
 
+		conn.setStateUnlessReconnecting(StateConnecting, nil)
+		var endpoint string
+		connection, endpoint, err = conn.dialWithRetries(ctx)
 		if err != nil {
-			conn.onErrorHook(err)
+			recordSpanError(span, err)
+			conn.handleError(err)
+			conn.setState(StateClosed, err)
 			return // return early so we don't execute other hooks, send Connected event, etc.
 		}
 
-		conn.setConnection(connection)
-		defer conn.afterConnect()
+		var generation uint64
+		generation, err = conn.attach(connection, endpoint)
+		if err != nil {
+			connection.Close()
+			recordSpanError(span, err)
+			conn.setState(StateClosed, err)
+			return
+		}
+		conn.setState(StateConnected, nil)
+		conn.logger.Info("connected", slog.String("remote", endpoint))
+		conn.emit(ConnectedEvent{Origin: conn.origin(), Addr: connection.RemoteAddr()})
+		conn.startConnectionSpan(ctx, endpoint)
+		conn.stats.recordConnect(conn.clock.Now())
+		conn.checkCertExpiry()
 
-		go conn.readFromConn()
+		conn.routines.start(func() { conn.readFromConn(generation) })
+		if conn.keepaliveInterval > 0 {
+			stop := conn.disconnected()
+			conn.routines.start(func() { conn.keepalive(stop) })
+		}
+		if conn.watchdogTimeout > 0 {
+			stop := conn.disconnected()
+			conn.routines.start(func() { conn.watchdog(stop) })
+		}
+		if conn.healthCheck != nil {
+			conn.resetHealth()
+			stop := conn.disconnected()
+			conn.routines.start(func() { conn.checkHealth(stop) })
+		}
+		if conn.maxConnectionAge > 0 {
+			age, stop := conn.connectionAge(), conn.disconnected()
+			conn.routines.start(func() { conn.recycleAfter(age, stop) })
+		}
+		conn.retransmit()
+		conn.flushWriteQueue()
 		close(conn.Connected) // broadcast that TCP connection to interface was established
+		connected = true
 	})
-	return err
+	return connected, err
 }
 
 func (conn *Client) Reconnect() error {
+	return conn.reconnect(false)
+}
+
+// reconnect does the work of Reconnect. With drain it lets a write in progress finish
+// before closing the connection.
+func (conn *Client) reconnect(drain bool) error {
+	if conn.isShutdown() {
+		return ErrShutdown
+	}
+
+	ctx, span := conn.startSpan(context.Background(), "eventedconnection.Reconnect")
+	defer span.End()
+
+	// wait for a Connect (or Reconnect) in progress, e.g. when the connection it
+	// established is lost before it returns and reconnecting starts automatically
+	conn.connectMutex.Lock()
+
+	conn.logger.Info("reconnecting")
+	conn.setState(StateReconnecting, nil)
+	conn.emit(ReconnectingEvent{Origin: conn.origin(), Attempt: conn.nextReconnectAttempt()})
+
+	if drain {
+		// wait for a write in progress; not held while closing since the
+		// BeforeDisconnectHook may write
+		conn.writeMutex.Lock()
+		conn.writeMutex.Unlock()
+	}
 	conn.Close()
 	conn.reset()
-	return conn.Connect()
+
+	connected, err := conn.connectLocked(ctx)
+	if err == nil {
+		conn.stats.recordReconnect()
+		conn.resetReconnectAttempts()
+	}
+	conn.connectMutex.Unlock()
+
+	if connected {
+		conn.afterConnect() // without connectMutex, so the hook can call Reconnect
+	}
+	recordSpanError(span, err)
+	return err
+}
+
+// nextReconnectAttempt counts a reconnect attempt and returns its number
+func (conn *Client) nextReconnectAttempt() int {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.reconnectAttempts++
+	return conn.reconnectAttempts
+}
+
+func (conn *Client) resetReconnectAttempts() {
+	conn.mutex.Lock()
+	conn.reconnectAttempts = 0
+	conn.mutex.Unlock()
 }
 
 func (conn *Client) reset() {
@@ -134,7 +583,8 @@ func (conn *Client) reset() {
 	conn.Disconnected = make(chan struct{})
 	conn.Connected = make(chan struct{})
 	conn.starter = sync.Once{}
-	conn.closer = sync.Once{}
+	conn.closing = false
+	conn.draining = false
 }
 
 func (conn *Client) setConnection(c net.Conn) {
@@ -143,11 +593,47 @@ func (conn *Client) setConnection(c net.Conn) {
 	conn.mutex.Unlock()
 }
 
+// attach sets a freshly dialed connection as the current connection and returns
+// its generation, which identifies it across later swaps (e.g. UpgradeTLS). It fails
+// with ErrShutdown if the client was shut down while the connection was dialed.
+func (conn *Client) attach(c net.Conn, endpoint string) (uint64, error) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	if conn.isShutdown() {
+		return 0, ErrShutdown // shut down while dialing
+	}
+
+	conn.c = c
+	conn.remoteEndpoint = endpoint
+	conn.connectedAt = conn.clock.Now()
+	conn.generation++
+	for _, layer := range conn.layers {
+		layer.frames.reset()
+	}
+	return conn.generation, nil
+}
+
+// currentConnection returns the underlying connection if generation is still
+// the current connection generation.
+func (conn *Client) currentConnection(generation uint64) (net.Conn, bool) {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+
+	return conn.c, conn.generation == generation
+}
+
+// closeGeneration closes the connection because of cause unless it has already
+// been closed or replaced by a newer one (e.g. by Reconnect).
+func (conn *Client) closeGeneration(generation uint64, cause error) {
+	conn.closeConnection(cause, false, generation)
+}
+
 func (conn *Client) afterConnect() {
 	if conn.afterConnectHook != nil {
 		err := conn.afterConnectHook()
 		if err != nil {
-			conn.onErrorHook(err)
+			conn.handleError(err)
 		}
 	}
 }
@@ -162,27 +648,173 @@ func (conn *Client) IsActive() bool {
 
 // Write provides a thread-safe way to send messages to the endpoint. If the connection is
 // nil (e.g. closed) then this is a noop.
+//
+// Deprecated: use WriteBytes, which takes the data by value.
 func (conn *Client) Write(data *[]byte) error {
+	return conn.write(*data, conn.GetWriteTimeout())
+}
+
+// WriteBytes is like Write but takes the data by value
+func (conn *Client) WriteBytes(data []byte) error {
+	return conn.write(data, conn.GetWriteTimeout())
+}
+
+// WriteString is like Write but takes a string, e.g. a command of a line protocol.
+// Unless a BeforeWriteHook or WriteMiddleware might hold on to the data, the string's
+// bytes are written directly, without converting it to a []byte.
+func (conn *Client) WriteString(s string) error {
+	if conn.beforeWriteHook != nil || len(conn.writeMiddleware) > 0 {
+		return conn.write([]byte(s), conn.GetWriteTimeout())
+	}
+	// the connection only reads the data, so the string can't be modified through it
+	return conn.write(unsafe.Slice(unsafe.StringData(s), len(s)), conn.GetWriteTimeout())
+}
+
+// Writev writes parts as a single message, e.g. a header and a body, without the
+// caller having to concatenate them. No other write can come between the parts.
+func (conn *Client) Writev(parts ...[]byte) error {
+	return conn.write(bytes.Join(parts, nil), conn.GetWriteTimeout())
+}
+
+// WriteWithTimeout is like Write but uses timeout as the write deadline instead of the
+// configured WriteTimeout, e.g. to give a large payload more time on a slow link.
+func (conn *Client) WriteWithTimeout(data *[]byte, timeout time.Duration) error {
+	return conn.write(*data, timeout)
+}
+
+// TryWrite is like Write but returns ErrWriteBusy immediately, without writing,
+// if another write is in progress, so latency sensitive callers can shed load
+// instead of waiting.
+func (conn *Client) TryWrite(data *[]byte) error {
+	if !conn.writeMutex.TryLock() {
+		return ErrWriteBusy
+	}
+	defer conn.unlockWrites()
+
+	return conn.writeOrQueue(*data, conn.GetWriteTimeout(), conn.acks != nil, false)
+}
+
+// write writes a message of the caller's, numbered for at-least-once delivery if
+// Config.AckExtractor is set
+func (conn *Client) write(data []byte, timeout time.Duration) error {
+	conn.writeMutex.Lock()
+	defer conn.unlockWrites()
+
+	return conn.writeOrQueue(data, timeout, conn.acks != nil, true)
+}
+
+// writeControl writes a message of the client's own, such as a keepalive, which is
+// never numbered for at-least-once delivery
+func (conn *Client) writeControl(data []byte, timeout time.Duration) error {
+	conn.writeMutex.Lock()
+	defer conn.unlockWrites()
+
+	return conn.writeOrQueue(data, timeout, false, true)
+}
+
+// writeOrQueue queues data or writes it to the connection, numbering it first if
+// number is set. block is passed on to limitRate. conn.writeMutex must be held.
+func (conn *Client) writeOrQueue(data []byte, timeout time.Duration, number, block bool) (err error) {
+	if conn.isDraining() {
+		return ErrClosed
+	}
+
+	var seq uint64
+	if number {
+		if seq, data, err = conn.acks.add(data, conn.sequenceHook); err != nil {
+			conn.stats.recordWriteError()
+			conn.handleError(err)
+			return err
+		}
+		// tracked before it is written since the acknowledgement may arrive right away
+		defer func() {
+			if err != nil {
+				conn.acks.remove(seq)
+			}
+		}()
+	}
+
+	if queued, err := conn.queueWrite(data, seq); queued {
+		return err
+	}
+	if err = conn.limitRate(len(data), timeout, block); err != nil {
+		return err
+	}
+	if err = conn.writeLocked(data, timeout); err == nil && number {
+		conn.acks.sent(seq)
+	}
+	return err
+}
+
+// writeLocked does the work of Write. conn.writeMutex must be held.
+func (conn *Client) writeLocked(data []byte, timeout time.Duration) error {
 	var err error
 
 	connection := conn.rawConnection()
 	if connection == nil {
-		err = errors.New("called Write with nil connection")
-		conn.onErrorHook(err)
+		err = conn.notConnected()
+		conn.stats.recordWriteError()
+		conn.handleError(err)
 		return err
 	}
 
-	err = connection.SetWriteDeadline(time.Now().Add(conn.GetWriteTimeout()))
+	payload := data
+	if conn.beforeWriteHook != nil {
+		payload, err = conn.beforeWriteHook(payload)
+		if err != nil {
+			conn.stats.recordWriteError()
+			conn.handleError(err)
+			return err
+		}
+	}
+
+	if conn.writePipeline == nil {
+		return conn.send(connection, payload, timeout)
+	}
+
+	conn.writeTarget = writeTarget{connection: connection, timeout: timeout}
+	defer func() { conn.writeTarget = writeTarget{} }()
+	return conn.writePipeline.run(payload, func(err error) {
+		conn.stats.recordWriteError()
+		conn.handleError(err)
+	})
+}
+
+// writeTarget is where the write passing through conn.writePipeline is sent.
+// It is guarded by conn.writeMutex.
+type writeTarget struct {
+	connection net.Conn
+	timeout    time.Duration
+}
+
+// send writes payload to connection. A write error that closes the connection is left
+// in conn.writeFailure for unlockWrites. conn.writeMutex must be held.
+func (conn *Client) send(connection net.Conn, payload []byte, timeout time.Duration) error {
+	err := connection.SetWriteDeadline(conn.clock.Now().Add(timeout))
 	if err != nil {
-		conn.onErrorHook(err)
-		defer conn.Close()
+		err = wrapTimeout(ErrWriteTimeout, err)
+		conn.stats.recordWriteError()
+		conn.handleError(err)
+		if conn.closesOnWriteError(err) {
+			conn.writeFailure = err
+		}
 		return err
 	}
 
-	_, err = connection.Write(*data)
+	n, err := conn.chaosWrite(connection, payload)
+	conn.stats.recordWrite(n, conn.clock.Now())
+	conn.hexDump(DirectionWrite, payload[:n])
+	conn.capture(DirectionWrite, payload[:n])
+	conn.traceEvent("write", n, err)
 	if err != nil {
-		conn.onErrorHook(err)
-		defer conn.Close()
+		err = wrapTimeout(ErrWriteTimeout, err)
+		conn.stats.recordWriteError()
+		conn.handleError(err)
+		if conn.closesOnWriteError(err) {
+			conn.writeFailure = err
+		}
+	} else {
+		conn.stats.recordMessageWritten()
 	}
 
 	return err
@@ -192,26 +824,246 @@ func (conn *Client) Write(data *[]byte) error {
 // Safe to call more than once, however will only close an open TCP connection on the first call.
 // Closes the conn.Disconnected chan prior to closing the TCP connection to allow
 // short-circuiting of downstream `select` blocks and avoid attempts to write to it
-// by the caller.
+// by the caller. If the connection is already being closed, by another goroutine or
+// by the close whose BeforeDisconnectHook is calling Close, Close returns at once; use
+// WaitClosed to wait until the connection is closed.
 func (conn *Client) Close() {
+	conn.closeWithError(nil)
+}
+
+// CloseWithError is like Close but records reason as the cause of the disconnect, so
+// a deliberate close can be told apart from a failure: reason is available to the
+// BeforeDisconnectContextHook as HookContext.Cause, sent in the DisconnectedEvent and
+// state changes, and returned by Err. Unlike a failure it doesn't trigger
+// Config.AutoReconnect.
+func (conn *Client) CloseWithError(reason error) {
+	conn.closeConnection(reason, true, 0)
+}
+
+// CloseGracefully closes the connection once it is done with the writes made so far.
+// New writes fail with ErrClosed right away, the write queue is flushed if connected
+// and, with Config.AckExtractor set, CloseGracefully waits for every write to be
+// acknowledged, or for the connection to be lost, before closing it like Close. If
+// ctx is done first the connection is closed regardless and ctx.Err() is returned.
+func (conn *Client) CloseGracefully(ctx context.Context) error {
 	conn.mutex.Lock()
-	defer conn.mutex.Unlock()
+	conn.draining = true
+	conn.mutex.Unlock()
 
-	conn.closer.Do(func() {
-		if conn.beforeDisconnectHook != nil {
-			if err := conn.beforeDisconnectHook(); err != nil {
-				conn.onErrorHook(err)
-			}
+	err := conn.lockWrites(ctx) // waits for a write in progress
+	if err == nil {
+		if conn.rawConnection() != nil {
+			conn.flushWriteQueueLocked()
 		}
+		conn.unlockWrites()
+		err = conn.waitAcknowledged(ctx)
+	}
+
+	conn.Close()
+	return err
+}
+
+func (conn *Client) isDraining() bool {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+	return conn.draining
+}
+
+// CloseWithTimeout is like CloseGracefully but bounds the whole graceful close,
+// including the BeforeDisconnectHook, by d: if it hasn't finished by then the socket
+// is closed at once, without waiting for writes or hooks, and context.DeadlineExceeded
+// is returned. The rest of the close completes in the background. Meant for service
+// shutdown paths that must not hang on a stuck peer or hook.
+func (conn *Client) CloseWithTimeout(d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	connection := conn.rawConnection()
+	closed := make(chan error, 1)
+	conn.routines.start(func() { closed <- conn.CloseGracefully(ctx) })
+
+	select {
+	case err := <-closed:
+		return err
+	case <-ctx.Done():
+	}
+
+	if connection != nil {
+		// closing a tls.Conn waits for a write in progress to send close_notify
+		if tlsConn, ok := connection.(*tls.Conn); ok {
+			connection = tlsConn.NetConn()
+		}
+		connection.Close()
+	}
+	return ctx.Err()
+}
+
+// unlockWrites unlocks conn.writeMutex and then closes the connection if a write failed
+// with an error that closes it, so that the BeforeDisconnectHook and OnStateChangeHook
+// can still write
+func (conn *Client) unlockWrites() {
+	cause := conn.writeFailure
+	conn.writeFailure = nil
+	conn.writeMutex.Unlock()
+
+	if cause != nil {
+		conn.closeWithError(cause)
+	}
+}
+
+// lockWrites locks conn.writeMutex unless ctx is done first
+func (conn *Client) lockWrites(ctx context.Context) error {
+	locked := make(chan struct{})
+	conn.routines.start(func() {
+		conn.writeMutex.Lock()
+		close(locked)
+	})
+
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		conn.routines.start(func() {
+			<-locked
+			conn.unlockWrites()
+		})
+		return ctx.Err()
+	}
+}
+
+// waitAcknowledged waits until no write is waiting to be acknowledged, the connection
+// is lost or ctx is done
+func (conn *Client) waitAcknowledged(ctx context.Context) error {
+	if conn.acks == nil {
+		return nil
+	}
+
+	select {
+	case <-conn.acks.emptied():
+		return nil
+	case <-conn.disconnected():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// closeWithError closes the connection because of cause (nil for a deliberate close)
+func (conn *Client) closeWithError(cause error) {
+	conn.closeConnection(cause, false, 0)
+}
+
+// closeConnection does the work of Close. deliberate is set when cause is a reason
+// given to CloseWithError rather than a failure. A non-zero generation only closes
+// the connection of that generation, if it is still the current one. It returns at
+// once if the connection is already being closed.
+//
+// No lock is held while the BeforeDisconnectHook runs and the state changes are
+// reported, so they can use the client: the hook may still write to the connection,
+// and even call Close or Reconnect.
+func (conn *Client) closeConnection(cause error, deliberate bool, generation uint64) {
+	conn.mutex.Lock()
+	if generation != 0 && generation != conn.generation {
+		conn.mutex.Unlock()
+		return
+	}
+	if conn.closing {
+		conn.mutex.Unlock()
+		return
+	}
+	conn.closing = true
+	conn.disconnectErr = cause // for the BeforeDisconnectContextHook
+	generation = conn.generation
+	disconnected, c := conn.Disconnected, conn.c
+	conn.mutex.Unlock()
+
+	conn.setStateUnlessReconnecting(StateClosing, cause)
+	if conn.beforeDisconnectHook != nil {
+		if err := conn.beforeDisconnectHook(); err != nil {
+			conn.handleError(err)
+		}
+	}
+
+	conn.mutex.Lock()
+	close(disconnected) // broadcast that TCP connection to interface was closed
+	// the hook may have reconnected, in which case only its old connection is closed
+	current := conn.generation == generation
+	if current {
+		c = conn.c
+		conn.c = nil      // set C to nil so it's clear the connection cannot be used
+		conn.generation++ // retire the read loop of the closed connection
+		conn.disconnectErr = cause
+		conn.endConnectionSpan()
+		if !deliberate {
+			conn.maybeAutoReconnect(cause)
+		}
+	}
+	if c != nil {
+		c.Close()
+	}
+	conn.mutex.Unlock()
+
+	if !current {
+		return
+	}
+	if cause != nil {
+		conn.logger.Info("disconnected", slog.Any("error", cause))
+	} else {
+		conn.logger.Info("disconnected")
+	}
+	conn.emit(DisconnectedEvent{Origin: conn.origin(), Err: cause})
+	conn.setStateUnlessReconnecting(StateClosed, cause)
+}
+
+// Shutdown closes the connection and the client for good: Done is closed, Err
+// returns ErrShutdown and later calls to Connect and Reconnect fail with
+// ErrShutdown. Use Close instead to disconnect while keeping the option to Reconnect.
+// Safe to call more than once.
+func (conn *Client) Shutdown() {
+	conn.shutdown.Do(func() {
+		conn.mutex.Lock()
+		close(conn.done) // connections dialed from now on are dropped, see attach
+		conn.mutex.Unlock()
 
-		close(conn.Disconnected) // broadcast that TCP connection to interface was closed
-		if conn.c != nil {
-			conn.c.Close()
-			conn.c = nil // set C to nil so it's clear the connection cannot be used
+		conn.closeConnection(nil, true, 0)
+		conn.failPending()
+		if conn.writeQueue != nil {
+			conn.writeQueue.close()
 		}
 	})
 }
 
+// Done returns a channel that is closed when the client is shut down. Unlike
+// Disconnected it is never replaced, so it is suitable for tying the lifetime of
+// other goroutines to the client's, much like context.Context.Done.
+func (conn *Client) Done() <-chan struct{} {
+	return conn.done
+}
+
+func (conn *Client) isShutdown() bool {
+	select {
+	case <-conn.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns the error that caused the most recent disconnect, or nil if the
+// client has not been disconnected or was closed deliberately with Close (the reason
+// given to CloseWithError if closed with that). Once
+// Done is closed it returns ErrShutdown, mirroring context.Context.Err.
+// It is most useful after receiving from the Disconnected or Done channels.
+func (conn *Client) Err() error {
+	if conn.isShutdown() {
+		return ErrShutdown
+	}
+
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+	return conn.disconnectErr
+}
+
 // Disconnect is an alias for conn.Close()
 func (conn *Client) Disconnect() {
 	conn.Close()
@@ -219,58 +1071,233 @@ func (conn *Client) Disconnect() {
 
 // processResponse handles data coming from the TCP connection
 // and sends it through the conn.Read chan
-func (conn *Client) processResponse(data []byte) (err error) {
-	var processed []byte
+func (conn *Client) processResponse(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if conn.readPipeline != nil {
+		return conn.readPipeline.run(data, conn.handleError)
+	}
+	return conn.deliver(data)
+}
 
-	if len(data) > 0 {
-		processed, err = conn.afterReadHook(data)
-		if err != nil {
-			conn.onErrorHook(err)
+// deliver passes data through the AfterReadHook and sends it through the conn.Read
+// (or conn.Data) chan
+func (conn *Client) deliver(data []byte) error {
+	processed, err := conn.afterReadHook(data)
+	if err != nil {
+		conn.handleError(err)
+		if conn.keepReadingOnHookError {
+			return nil // drop the message but keep the connection
 		}
-		conn.Read <- &processed
+	} else if conn.acknowledge(processed) || conn.healthResponse(processed) {
+		return nil
+	}
+	if conn.replay != nil {
+		conn.replay.add(processed)
 	}
+	if !conn.publish(processed) || conn.onMessageHook != nil {
+		conn.enqueue(processed) // the OnMessageHook dispatcher reads from the Read channel
+	}
+	conn.stats.recordDelivery()
+	conn.traceEvent("message.delivered", len(processed), err)
 
 	return err
 }
 
+// publish sends a copy of message to the subscribers, see Subscribe, and reports
+// whether there were any
+func (conn *Client) publish(message []byte) bool {
+	if !conn.subscribers.any() {
+		return false
+	}
+	for range conn.subscribers.publish(slices.Clone(message)) {
+		conn.stats.recordSubscriberDrop()
+	}
+	return true
+}
+
+// enqueue sends message on the Data channel, or the Read channel unless Config.ValueReads
+// is set, and records whether the read loop had to wait for a consumer
+func (conn *Client) enqueue(message []byte) {
+	if conn.Data != nil {
+		select {
+		case conn.Data <- message:
+			conn.checkConsumer(0)
+			return
+		default:
+		}
+
+		start := conn.clock.Now()
+		select {
+		case conn.Data <- message:
+		case <-conn.done:
+			return // shut down with nobody left reading
+		}
+		conn.recordBlockedDelivery(conn.since(start))
+		return
+	}
+
+	pointer := &message // the consumer owns the pointer once it is sent
+	select {
+	case conn.Read <- pointer:
+		conn.checkConsumer(0)
+		return
+	default:
+	}
+
+	start := conn.clock.Now()
+	select {
+	case conn.Read <- pointer:
+	case <-conn.done:
+		return // shut down with nobody left reading
+	}
+	conn.recordBlockedDelivery(conn.since(start))
+}
+
+func (conn *Client) recordBlockedDelivery(blocked time.Duration) {
+	conn.stats.recordBlockedDelivery(blocked)
+	conn.checkConsumer(blocked)
+}
+
 // readFromConn reads data from the connection into a buffer and then
 // passes onto processResponse. In the event of an error the connection
 // is closed.
-func (conn *Client) readFromConn() error {
-	defer conn.Close()
+func (conn *Client) readFromConn(generation uint64) (err error) {
+	defer func() { conn.closeGeneration(generation, err) }()
 
+	stop := conn.disconnected()
 	buffer := make([]byte, conn.GetReadBufferSize())
+	lastRead := conn.clock.Now() // when data was last read, or the read timeout was last extended
 	for {
-		var err error
-		connection := conn.rawConnection()
+		if size := conn.GetReadBufferSize(); size != len(buffer) && conn.ring == nil {
+			buffer = make([]byte, size) // changed by SetReadBufferSize
+		}
+
+		conn.waitIfPaused()
+		connection, current := conn.currentConnection(generation)
+		if !current {
+			return nil // closed or replaced by a newer connection which has its own read loop
+		}
 
 		if connection == nil {
-			err = errors.New("unable to read from nil connection")
-			conn.onErrorHook(err)
+			err = conn.notConnected()
+			conn.handleError(err)
 			return err
 		}
 
-		err = connection.SetReadDeadline(time.Now().Add(conn.GetReadTimeout()))
+		if conn.ring != nil {
+			// read straight into the free space of the ring
+			if buffer = conn.ring.reserve(stop); buffer == nil {
+				return nil // closed while waiting for the consumer
+			}
+		}
+
+		deadline, idle := conn.readDeadline(lastRead)
+		err = connection.SetReadDeadline(deadline)
 		if err != nil {
-			conn.onErrorHook(err)
+			conn.handleError(err)
 			return err
 		}
+		if conn.pausePending() {
+			continue // pauseReader's forced deadline may have been overwritten above
+		}
+
+		var pooled *[]byte
+		if conn.readBuffers != nil {
+			// read straight into a buffer that is handed over instead of copied
+			pooled = conn.readBuffers.get(len(buffer))
+			buffer = *pooled
+		}
 
-		numBytesRead, err := connection.Read(buffer)
+		var numBytesRead int
+		numBytesRead, err = conn.chaosRead(connection, buffer)
 		if numBytesRead > 0 {
-			res := make([]byte, numBytesRead)
-			// Copy the buffer so it's safe to pass along
-			copy(res, buffer[:numBytesRead])
-			err = conn.processResponse(res)
+			lastRead = conn.clock.Now()
+			conn.stats.recordRead(numBytesRead, conn.clock.Now())
+			conn.hexDump(DirectionRead, buffer[:numBytesRead])
+			res := buffer[:numBytesRead]
+			if conn.ring == nil && pooled == nil {
+				// Copy the buffer so it's safe to pass along
+				res = make([]byte, numBytesRead)
+				copy(res, buffer[:numBytesRead])
+			}
+			conn.capture(DirectionRead, res)
+			conn.tee(res)
+			if conn.ring != nil {
+				conn.ring.commit(numBytesRead) // consumed through the ring instead of Read
+			} else if !conn.readTeeOnly {
+				err = conn.processResponse(res)
+				pooled = nil // delivered; the consumer releases it
+			}
+		}
+		if pooled != nil {
+			conn.readBuffers.put(pooled)
 		}
 
 		if err != nil {
-			conn.onErrorHook(err)
+			if conn.interruptedByPause(err) {
+				continue
+			}
+			if _, current := conn.currentConnection(generation); !current {
+				return nil
+			}
+			if isTimeout(err) && idle {
+				var keepReading bool
+				if keepReading, err = conn.onIdle(conn.since(lastRead)); keepReading {
+					continue
+				}
+				if err == nil {
+					return nil // the IdleAction replaced the connection
+				}
+				conn.handleError(err)
+				return err
+			}
+			if isTimeout(err) {
+				conn.emit(ReadTimeoutEvent{Origin: conn.origin()})
+				if conn.onReadTimeoutHook != nil {
+					if err = conn.onReadTimeoutHook(); err == nil {
+						lastRead = conn.clock.Now()
+						continue // keep the idle connection open
+					}
+				}
+				err = wrapTimeout(ErrReadTimeout, err)
+			}
+			conn.handleError(err)
 			return err
 		}
 	}
 }
 
+// tee copies data read from the connection to the ReadTee, if any
+func (conn *Client) tee(data []byte) {
+	if conn.readTee == nil {
+		return
+	}
+
+	conn.callHook("ReadTee", func() {
+		if _, err := conn.readTee.Write(data); err != nil {
+			conn.handleError(fmt.Errorf("unable to write to ReadTee: %w", err))
+		}
+	})
+}
+
+// notConnected returns the error for using the connection while there is none:
+// ErrClosed once it has been closed and ErrNotConnected before it is established.
+func (conn *Client) notConnected() error {
+	switch conn.State() {
+	case StateClosing, StateClosed:
+		return ErrClosed
+	}
+	return ErrNotConnected
+}
+
+// isTimeout reports whether err is a deadline expiry
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
 // rawConnection is used for getting the underlying TCP connection
 // in a thread safe way
 func (conn *Client) rawConnection() net.Conn {
@@ -281,25 +1308,73 @@ func (conn *Client) rawConnection() net.Conn {
 
 // GetEndpoint returns the value of conn.endpoint
 func (conn *Client) GetEndpoint() string {
+	conn.settingsMutex.RLock()
+	defer conn.settingsMutex.RUnlock()
 	return conn.endpoint
 }
 
 // GetReadBufferSize returns the value of conn.readBufferSize
 func (conn *Client) GetReadBufferSize() int {
+	conn.settingsMutex.RLock()
+	defer conn.settingsMutex.RUnlock()
 	return conn.readBufferSize
 }
 
 // GetWriteTimeout returns the value of conn.writeTimeout
 func (conn *Client) GetWriteTimeout() time.Duration {
+	conn.settingsMutex.RLock()
+	defer conn.settingsMutex.RUnlock()
 	return conn.writeTimeout
 }
 
 // GetReadTimeout returns the value of conn.readTimeout
 func (conn *Client) GetReadTimeout() time.Duration {
+	conn.settingsMutex.RLock()
+	defer conn.settingsMutex.RUnlock()
 	return conn.readTimeout
 }
 
+// SetReadBufferSize changes the size of the buffer used for reading from the
+// connection. The read loop switches to a buffer of the new size before its next read.
+func (conn *Client) SetReadBufferSize(size int) error {
+	if size <= 0 {
+		return fmt.Errorf("invalid read buffer size %d", size)
+	}
+
+	conn.settingsMutex.Lock()
+	defer conn.settingsMutex.Unlock()
+	conn.readBufferSize = size
+	return nil
+}
+
+// SetWriteTimeout changes the write timeout. It applies from the next call to Write.
+func (conn *Client) SetWriteTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("invalid write timeout %s", timeout)
+	}
+
+	conn.settingsMutex.Lock()
+	defer conn.settingsMutex.Unlock()
+	conn.writeTimeout = timeout
+	return nil
+}
+
+// SetReadTimeout changes the read timeout. A read that is already waiting keeps its
+// deadline; the new timeout applies from the next read.
+func (conn *Client) SetReadTimeout(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("invalid read timeout %s", timeout)
+	}
+
+	conn.settingsMutex.Lock()
+	defer conn.settingsMutex.Unlock()
+	conn.readTimeout = timeout
+	return nil
+}
+
 // GetConnectionTimeout returns the value of conn.connectionTimeout
 func (conn *Client) GetConnectionTimeout() time.Duration {
+	conn.settingsMutex.RLock()
+	defer conn.settingsMutex.RUnlock()
 	return conn.connectionTimeout
 }
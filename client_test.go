@@ -1,8 +1,13 @@
 package eventedconnection_test
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"math/rand"
+	"net"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -33,6 +38,7 @@ func TestNewClient_Config(t *testing.T) {
 	assertEqual(t, con.GetReadTimeout(), 1*time.Hour)
 	assertEqual(t, con.GetWriteTimeout(), 5*time.Second)
 	assertEqual(t, con.GetReadBufferSize(), 16*1024)
+	assertEqual(t, cap(con.Read), DefaultReadChannelSize)
 
 	conf = Config{
 		Endpoint:          "localhost:5555",
@@ -40,6 +46,7 @@ func TestNewClient_Config(t *testing.T) {
 		WriteTimeout:      4 * time.Second,
 		ConnectionTimeout: 8 * time.Second,
 		ReadBufferSize:    2 * 1024,
+		ReadChannelSize:   64,
 	}
 
 	con, err = NewClient(&conf)
@@ -52,6 +59,7 @@ func TestNewClient_Config(t *testing.T) {
 	assertEqual(t, con.GetReadTimeout(), conf.ReadTimeout)
 	assertEqual(t, con.GetWriteTimeout(), conf.WriteTimeout)
 	assertEqual(t, con.GetReadBufferSize(), 2*1024)
+	assertEqual(t, cap(con.Read), 64)
 }
 
 func TestNewClient_ConfigTLS(t *testing.T) {
@@ -211,13 +219,17 @@ func TestClient_Close(t *testing.T) {
 		t.Error("Expected err to be nil")
 	}
 
+	payload := []byte("test")
+	if err = con.Write(&payload); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("expected ErrNotConnected before connecting, got %v", err)
+	}
+
 	err = con.Connect()
 	if err != nil {
 		t.Error("Received error when connecting.")
 	}
 
 	assertEqual(t, con.IsActive(), true)
-	payload := []byte("test")
 	err = con.Write(&payload)
 	assertEqual(t, err, nil)
 	con.Close()
@@ -225,7 +237,9 @@ func TestClient_Close(t *testing.T) {
 	assertEqual(t, calledDisconnectHook, true)
 
 	err = con.Write(&payload)
-	assertNotNil(t, err)
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after closing, got %v", err)
+	}
 	con.Close() // call again to test if it panics
 
 	close(done)
@@ -281,6 +295,219 @@ func TestClient_ReadWrite(t *testing.T) {
 	close(done)
 }
 
+func TestClient_BeforeWriteHook(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		BeforeWriteHook: func(data []byte) ([]byte, error) {
+			if len(data) == 0 {
+				return nil, errors.New("empty message")
+			}
+			return append([]byte("> "), data...), nil
+		},
+		OnErrorHook: func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal("Expected err to be nil")
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	empty := []byte{}
+	assertNotNil(t, con.Write(&empty))
+	assertEqual(t, con.IsActive(), true)
+
+	payload := []byte("Testing write hook")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(payload), "Testing write hook")
+
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), "> Testing write hook")
+	case <-time.After(2 * time.Second):
+		t.Error("Test timed out while waiting to read from connection")
+	}
+}
+
+func TestClient_RuntimeSettings(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Hour,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal("Expected err to be nil")
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	payload := []byte("12345678")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), "12345678")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+
+	assertNotNil(t, con.SetReadBufferSize(0))
+	assertNotNil(t, con.SetReadTimeout(-time.Second))
+	assertEqual(t, con.SetReadBufferSize(4), nil)
+	assertEqual(t, con.SetWriteTimeout(2*time.Second), nil)
+	assertEqual(t, con.GetReadBufferSize(), 4)
+	assertEqual(t, con.GetWriteTimeout(), 2*time.Second)
+
+	// a read already in progress may still use the old buffer
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+	for received := 0; received < len(payload); {
+		select {
+		case data := <-con.Read:
+			received += len(*data)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting to read from connection")
+		}
+	}
+
+	assertEqual(t, con.SetReadTimeout(50*time.Millisecond), nil)
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"1234", "5678"} {
+		select {
+		case data := <-con.Read:
+			assertEqual(t, string(*data), expected)
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting to read from connection")
+		}
+	}
+
+	// the new read timeout applies to the next read
+	select {
+	case <-con.Disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the read timeout")
+	}
+}
+
+func TestClient_WriteWithTimeout(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal("Expected err to be nil")
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	payload := []byte("Testing write timeout")
+	if err = con.WriteWithTimeout(&payload, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), string(payload))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+	assertEqual(t, con.GetWriteTimeout(), 1*time.Second)
+
+	// a deadline in the past fails the write
+	if err = con.WriteWithTimeout(&payload, -time.Second); !errors.Is(err, ErrWriteTimeout) {
+		t.Errorf("expected ErrWriteTimeout, got %v", err)
+	}
+}
+
+func TestClient_TryWrite(t *testing.T) {
+	// a server that never reads, so a large enough write blocks
+	done := make(chan bool)
+	l, err := testutils.BlackHoleServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 500 * time.Millisecond,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal("Expected err to be nil")
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	small := []byte("small")
+	assertEqual(t, con.TryWrite(&small), nil)
+
+	large := make([]byte, 64*1024*1024)
+	writing := make(chan error)
+	go func() { writing <- con.Write(&large) }()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if err = con.TryWrite(&small); err == ErrWriteBusy || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(t, err, ErrWriteBusy)
+	assertNotNil(t, <-writing)
+}
+
 func TestClient_Timeouts(t *testing.T) {
 	done := make(chan bool)
 	l, err := testutils.FlakyServer(done, 100*time.Millisecond, 100*time.Millisecond)
@@ -426,6 +653,172 @@ LOOP:
 	assertEqual(t, numConnections, 2)
 }
 
+func TestClient_Connect_CustomResolver(t *testing.T) {
+	var lookups int32
+	conf := Config{
+		Endpoint: "evented-connection.test:5555",
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				atomic.AddInt32(&lookups, 1)
+				return nil, errors.New("resolver unavailable")
+			},
+		},
+		OnErrorHook: func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal("Expected err to be nil")
+	}
+
+	err = con.Connect()
+	defer con.Close()
+	if err == nil {
+		t.Error("Expected error when the resolver fails")
+	}
+
+	if atomic.LoadInt32(&lookups) == 0 {
+		t.Error("Expected the custom resolver to be used when dialing")
+	}
+	assertEqual(t, con.IsActive(), false)
+}
+
+func TestClient_Connect_SRV(t *testing.T) {
+	var lookups int32
+	conf := Config{
+		SRVService: "evented",
+		SRVProto:   "tcp",
+		SRVName:    "evented-connection.test",
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				atomic.AddInt32(&lookups, 1)
+				return nil, errors.New("resolver unavailable")
+			},
+		},
+		OnErrorHook: func(err error) error { return err },
+	}
+
+	// no Endpoint is required when an SRV name is given
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal("Expected err to be nil")
+	}
+
+	err = con.Connect()
+	defer con.Close()
+	if err == nil {
+		t.Error("Expected error when the SRV lookup fails")
+	}
+
+	if atomic.LoadInt32(&lookups) == 0 {
+		t.Error("Expected the SRV records to be looked up through the resolver")
+	}
+}
+
+func TestClient_Connect_SRVRecords(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	// nothing listens on the preferred target
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	port := func(l net.Listener) uint16 { return uint16(l.Addr().(*net.TCPAddr).Port) }
+	endpoint := func(l net.Listener) string { return fmt.Sprintf("localhost:%d", port(l)) }
+	records := []*net.SRV{
+		{Target: "localhost.", Port: 1, Priority: 30, Weight: 1},
+		{Target: "localhost.", Port: port(l), Priority: 20, Weight: 1},
+		{Target: "localhost.", Port: port(closed), Priority: 10, Weight: 1},
+	}
+
+	var tried []string
+	conf := Config{
+		SRVService: "evented",
+		SRVProto:   "tcp",
+		SRVName:    "evented-connection.test",
+		Resolver:   testutils.SRVResolver(records),
+		BeforeConnectHook: func(params *DialParams) error {
+			tried = append(tried, params.Endpoint)
+			return nil
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the targets are tried by priority until one accepts the connection
+	assertEqual(t, len(tried), 2)
+	assertEqual(t, tried[0], endpoint(closed))
+	assertEqual(t, tried[1], endpoint(l))
+	info, ok := con.GetConnectionInfo()
+	assertEqual(t, ok, true)
+	assertEqual(t, info.Endpoint, endpoint(l))
+
+	if err = con.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "hello")
+}
+
+func TestClient_BeforeConnectHook(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	var attempts int32
+	conf := Config{
+		Endpoint: "evented-connection.test:5555",
+		BeforeConnectHook: func(params *DialParams) error {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				return errors.New("not yet")
+			}
+			params.Endpoint = l.Addr().String()
+			params.Dialer.Timeout = time.Second
+			return nil
+		},
+		OnErrorHook: func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal("Expected err to be nil")
+	}
+
+	if err = con.Connect(); err == nil || err.Error() != "not yet" {
+		t.Errorf("Expected the hook's error, got %v", err)
+	}
+	assertEqual(t, con.IsActive(), false)
+
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	assertEqual(t, con.IsActive(), true)
+	assertEqual(t, atomic.LoadInt32(&attempts), int32(2))
+}
+
 func BenchmarkThroughput(b *testing.B) {
 	done := make(chan bool)
 	l, err := testutils.EchoServer(done)
@@ -478,3 +871,81 @@ func assertEqual(t *testing.T, a interface{}, b interface{}) {
 		t.Errorf("%s != %s", a, b)
 	}
 }
+
+func TestClient_ValueReads(t *testing.T) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: time.Second,
+		ValueReads:   true,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if con.Read != nil {
+		t.Error("Expected Read to be nil")
+	}
+
+	if err = con.WriteBytes([]byte("by value")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Data:
+		assertEqual(t, string(data), "by value")
+	case <-time.After(time.Second):
+		t.Fatal("expected a message on Data")
+	}
+
+	// the read helpers take messages from Data too
+	if err = con.WriteBytes([]byte("helper")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadWithTimeout(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "helper")
+}
+
+func TestClient_Writev(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	if err := con.Writev([]byte{0, 5}, []byte("hello"), nil, []byte("!")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := con.ReadN(8, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "\x00\x05hello!")
+}
+
+func TestClient_WriteString(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	if err := con.WriteString("PING\r\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := con.ReadN(6, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "PING\r\n")
+}
@@ -0,0 +1,43 @@
+package eventedconnection
+
+import "time"
+
+// Clock is the client's source of time, see Config.Clock
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// NewTimer returns a Timer that fires once d has passed
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single-shot timer made by a Clock. It behaves like a *time.Timer, with
+// its channel returned by C.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// systemClock is the Clock backed by the time package, used unless Config.Clock is set
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// since returns the time elapsed since t on the client's clock
+func (conn *Client) since(t time.Time) time.Duration {
+	return conn.clock.Now().Sub(t)
+}
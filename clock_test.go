@@ -0,0 +1,63 @@
+package eventedconnection_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_Clock(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	start := time.Now()
+	clock := testutils.NewFakeClock(start)
+	conf := Config{
+		Endpoint:          l.Addr().String(),
+		KeepaliveInterval: time.Minute,
+		KeepalivePayload:  []byte("ping"),
+		Clock:             clock,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.GetStats().ConnectedAt, start)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for clock.Timers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the keepalive timer")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// nothing is sent until the clock moves on
+	clock.Advance(59 * time.Second)
+	select {
+	case data := <-con.Read:
+		t.Fatalf("unexpected read of %q", *data)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), "ping")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for a keepalive")
+	}
+	assertEqual(t, con.GetStats().LastWriteAt, start.Add(time.Minute))
+	assertEqual(t, con.GetStats().KeepalivesSent, uint64(1))
+}
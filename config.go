@@ -2,11 +2,17 @@ package eventedconnection
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net"
 	"os"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DefaultReadTimeout is the default duration to wait for a packet from the endpoint before considering the connection dead
@@ -18,30 +24,143 @@ const DefaultWriteTimeout = 5 * time.Second
 // DefaultConnectionTimeout is the default timeout duration for establishing the connection
 const DefaultConnectionTimeout = 30 * time.Second
 
+// DefaultCertExpiryWarning is the default window before a peer certificate's expiry in which the CertExpiryHook is called
+const DefaultCertExpiryWarning = 30 * 24 * time.Hour
+
 // DefaultReadBufferSize is the default buffer length, in bytes, to read data from the connection before passing through the Read channel
 const DefaultReadBufferSize = 16 * 1024
 
+// DefaultReadChannelSize is the default capacity of the Read channel, in messages
+const DefaultReadChannelSize = 4
+
 // AfterReadHook is a function that gets called after reading from the TCP connection.
 // Use this function to modify data read from the endpoint, write to a log, etc.
 // Returning an error from this function is a signal to close the connection.
 // If instead the caller would like to know about the error but not close the connection,
-// then, for example, AfterReadHook could send the error on a channel.
+// set Config.KeepReadingOnHookError.
 type AfterReadHook func([]byte) ([]byte, error)
 
-// AfterConnectHook is called just after a connection is established.
+// BeforeWriteHook is called by Write with the outgoing data before it is written to
+// the connection and returns the bytes to write instead, e.g. with a header or checksum
+// added. It must not modify data in place since it belongs to the caller. Returning an
+// error aborts the write (Write returns the error) but leaves the connection open.
+type BeforeWriteHook func(data []byte) ([]byte, error)
+
+// DialParams are the parameters of a single dial attempt, passed to the BeforeConnectHook.
+// TLSConfig is nil unless the client uses TLS or STARTTLS; it is a copy, so the hook may
+// modify it (e.g. to override ServerName) without affecting other attempts.
+type DialParams struct {
+	Endpoint  string
+	TLSConfig *tls.Config
+	Dialer    *net.Dialer
+}
+
+// BeforeConnectHook is called before each dial attempt (once per endpoint tried when
+// Config.SRVName is set) and may modify params, e.g. to pick a different endpoint or
+// refresh credentials. Returning an error skips the attempt; Connect fails with that
+// error if no other endpoint succeeds.
+type BeforeConnectHook func(params *DialParams) error
+
+// AfterConnectHook is called just after a connection is established. Connection details
+// such as Client.GetTLSConnectionState are already available when it runs.
 type AfterConnectHook func() error
 
 // BeforeDisconnectHook is called just before a connection is terminated.
 // This hook is only called before a termination originating on this end of
 // the connection (ie. if Client.Endpoint closes the connection
 // or a timeout occurs then this hook is not called). Use the OnError callback
-// to handle those cases.
+// to handle those cases. It runs without the client's locks held, so it can still
+// write to the connection, e.g. a goodbye message, and call Close, which returns at
+// once. It must not call Reconnect, which may be what is closing the connection and
+// would wait for itself.
 type BeforeDisconnectHook func() error
 
+// StartTLSHook is called with the plaintext connection just after it is dialed and before
+// any data is read from it. Use it to negotiate STARTTLS (e.g. send the upgrade command and
+// wait for the endpoint's go-ahead); once it returns nil the connection is upgraded to TLS
+// using Config.TLSConfig. Returning an error aborts the connection attempt.
+type StartTLSHook func(rw io.ReadWriter) error
+
+// CertExpiryHook is called after a TLS connection is established for every certificate
+// presented by the endpoint that expires within Config.CertExpiryWarning. expiresIn is
+// negative if the certificate has already expired. Returning an error passes it to the
+// OnErrorHook; the connection is left open.
+type CertExpiryHook func(cert *x509.Certificate, expiresIn time.Duration) error
+
+// OnReadTimeoutHook is called instead of the OnErrorHook when nothing was read from
+// the connection within Config.ReadTimeout, so an idle peer can be told apart from a
+// failed connection. Returning nil keeps the connection open and waits another
+// ReadTimeout; returning an error closes the connection with that error.
+type OnReadTimeoutHook func() error
+
+// OnIdleHook is called when nothing was read from the connection within
+// Config.IdleTimeout, with how long nothing has been read for. Returning nil goes on to
+// take the Config.IdleAction; returning an error closes the connection with that error.
+type OnIdleHook func(idle time.Duration) error
+
+// OnRatesHook is called with the rolling rates every time they are sampled, see
+// Config.RateWindow. It should return quickly.
+type OnRatesHook func(rates Rates)
+
+// OnWatchdogHook is called every time the watchdog trips because nothing was read from
+// the connection within Config.WatchdogTimeout, with how long nothing has been read
+// for, just before the client reconnects. It should return quickly.
+type OnWatchdogHook func(silence time.Duration)
+
+// HealthCheckMatcher reports whether message, read while a health check probe is
+// outstanding, is the endpoint's response to it. See Config.HealthCheckInterval.
+type HealthCheckMatcher func(message []byte) bool
+
+// KeepaliveHook returns the message to write when nothing was written to the connection
+// within Config.KeepaliveInterval, e.g. one carrying a sequence number or timestamp.
+// Returning an error passes it to the OnErrorHook and skips that keepalive.
+type KeepaliveHook func() ([]byte, error)
+
+// OnMessageHook is called by the client's dispatcher for every message delivered on
+// the Read channel, as an alternative to reading the channel. Errors are passed to the
+// OnErrorHook; the connection stays open.
+type OnMessageHook func(data []byte) error
+
+// OnChecksumErrorHook is called with the contents of every frame failing checksum
+// validation (see Config.Checksum). Returning nil drops the frame and keeps the
+// connection open; returning an error closes the connection with that error.
+type OnChecksumErrorHook func(frame []byte) error
+
+// OnSlowConsumerHook is called from the read loop when a message is delivered while
+// the consumers of the Read channel are behind by more than Config.SlowConsumerThreshold.
+// It should return quickly since the read loop waits for it.
+type OnSlowConsumerHook func(info SlowConsumerInfo)
+
+// ReconnectAttempt describes an automatic reconnect attempt, see OnReconnectAttemptHook
+type ReconnectAttempt struct {
+	Attempt int           // attempts since the connection was lost, starting at 1
+	Delay   time.Duration // how long the client waited before this attempt, jitter included
+	LastErr error         // the error that closed the connection or failed the previous attempt
+}
+
+// OnReconnectAttemptHook is called by the automatic reconnect loop (see
+// Config.AutoReconnect) just before every attempt, e.g. to log or alert on reconnect
+// storms. Returning an error stops reconnecting; the error is passed to the
+// OnErrorHook and the client stays disconnected.
+type OnReconnectAttemptHook func(attempt ReconnectAttempt) error
+
+// OnReconnectExhaustedHook is called when automatic reconnecting gives up because
+// Config.MaxReconnectAttempts or Config.MaxReconnectDuration was reached, with the
+// number of attempts made and the error that failed the last one, so the application
+// can escalate. The client stays disconnected until Connect or Reconnect is called.
+type OnReconnectExhaustedHook func(attempts int, lastErr error)
+
 // OnErrorHook will be called whenever an error occurs within the scope of an Client
 // method. Useful for logging or event notifications for example.
 type OnErrorHook func(error) error
 
+// OnStateChangeHook is called for every transition of the Client's State, with the
+// error that caused it if any (e.g. the read error behind a disconnect). It is called
+// synchronously and in order, so it should return quickly. It may call Close and Write,
+// but not Reconnect: transitions are reported while a Connect or Reconnect is in
+// progress, which Reconnect would wait for.
+type OnStateChangeHook func(from, to State, err error)
+
 func defaultAfterReadHook(data []byte) ([]byte, error) { return data, nil }
 func defaultOnErrorHook(err error) error               { return err }
 
@@ -50,72 +169,685 @@ type Config struct {
 	Endpoint       string `json:"endpoint"`
 	ReadBufferSize int    `json:"readBufferSize"`
 
+	// ReadChannelSize is the capacity of the Read channel (DefaultReadChannelSize if zero).
+	// The read loop stops reading from the connection while the channel is full, so bursty
+	// protocols may need a deeper channel to keep the reader from blocking.
+	ReadChannelSize int `json:"readChannelSize"`
+
+	// ValueReads delivers messages as []byte values on Client.Data instead of as *[]byte
+	// on Client.Read, which is left nil.
+	ValueReads bool `json:"valueReads"`
+
+	// SlowConsumerThreshold, if set, enables slow consumer detection: whenever the read
+	// loop waits at least this long for room in the Read channel, or a delivered message
+	// finds the oldest queued message waiting at least this long, Stats.SlowDeliveries is
+	// incremented, a SlowConsumerEvent is sent and the OnSlowConsumerHook is called. Stats
+	// always counts the deliveries that had to wait for room.
+	SlowConsumerThreshold time.Duration `json:"slowConsumerThreshold"`
+	OnSlowConsumerHook    OnSlowConsumerHook
+
+	// PooledReads makes the client read into buffers taken from a pool and deliver them on
+	// the Read channel without copying, to cut allocations on busy connections. Consumers
+	// hand each message's buffer back with Client.Release (or Client.CopyAndRelease) once
+	// done with it; messages that aren't released are simply garbage collected. Hooks and
+	// middleware must not keep references to the data they are passed.
+	PooledReads bool `json:"pooledReads"`
+
+	// RingBufferSize, if set, enables the zero-copy read path for high volume streams:
+	// data is read straight into a ring buffer of this many bytes and consumed in place
+	// through Client.RingBuffer, bypassing the AfterReadHook, ReadMiddleware and the Read
+	// channel. The read loop stops reading while the ring is full. It can't be combined
+	// with PooledReads or the framing layers (EncryptionKey and Checksum).
+	RingBufferSize int `json:"ringBufferSize"`
+
+	// ReplayBufferSize, if set, keeps a copy of the last that many messages delivered,
+	// for Client.Replay. It has no effect with RingBufferSize.
+	ReplayBufferSize int `json:"replayBufferSize"`
+
+	// ID and Labels identify the client in applications with many connections. They are
+	// attached to every Event, log line, expvar variable and span, and are available to
+	// hooks through Client.GetID and Client.GetLabels.
+	ID     string            `json:"id"`
+	Labels map[string]string `json:"labels"`
+
 	ConnectionTimeout time.Duration `json:"connectionTimeout"`
 	ReadTimeout       time.Duration `json:"readTimeout"`
 	WriteTimeout      time.Duration `json:"writeTimeout"`
 
+	// IdleTimeout, if set, makes a quiet connection idle rather than dead: when nothing was
+	// read for IdleTimeout an IdleEvent is sent, the OnIdleHook is called and IdleAction
+	// is taken (by default nothing is done and the client keeps waiting), then again after
+	// every further IdleTimeout without data. ReadTimeout still closes the connection once
+	// nothing was read for that long, so it should be the longer of the two. IdlePing
+	// writes IdlePingPayload, which must be set for that action.
+	IdleTimeout     time.Duration `json:"idleTimeout"`
+	IdleAction      IdleAction    `json:"idleAction"`
+	IdlePingPayload []byte        `json:"idlePingPayload"`
+	OnIdleHook      OnIdleHook
+
+	// WatchdogTimeout, if set, guards against an endpoint that wedges without closing the
+	// socket: once nothing was read from the connection for that long the watchdog trips,
+	// sending a WatchdogEvent and calling the OnWatchdogHook, and the client replaces the
+	// connection with Reconnect, retrying with backoff as with AutoReconnect (whether or
+	// not it is set) if that fails. It should be shorter than ReadTimeout, which closes
+	// the connection instead.
+	WatchdogTimeout time.Duration `json:"watchdogTimeout"`
+	OnWatchdogHook  OnWatchdogHook
+
+	// KeepaliveInterval, if set, writes KeepalivePayload (or the message returned by the
+	// KeepaliveHook) whenever nothing was written to the connection for that long, since
+	// many endpoints drop clients that stay silent. Every write, including the keepalive,
+	// restarts the interval. Keepalives go through the BeforeWriteHook and
+	// WriteMiddleware like any other write.
+	KeepaliveInterval time.Duration `json:"keepaliveInterval"`
+	KeepalivePayload  []byte        `json:"keepalivePayload"`
+	KeepaliveHook     KeepaliveHook
+
+	// HealthCheckInterval, if set, actively checks the connection: every interval
+	// HealthCheckPayload is written and every message read is passed to the
+	// HealthCheckMatcher until it reports the response, which is consumed instead of
+	// delivered. A check fails if the payload can't be written or no response arrives
+	// within HealthCheckTimeout (DefaultHealthCheckTimeout if zero). Client.Health
+	// reports the outcome of the last check of the current connection, e.g. for routing
+	// requests across several clients, changes are sent as HealthEvents and failures
+	// are passed to the OnErrorHook and counted in Stats.HealthCheckFailures. With
+	// HealthCheckReconnect a failed check also replaces the connection, retrying with
+	// backoff as with AutoReconnect if that fails. Health checks can't be combined with
+	// RingBufferSize, whose reads never reach the HealthCheckMatcher.
+	HealthCheckInterval  time.Duration `json:"healthCheckInterval"`
+	HealthCheckTimeout   time.Duration `json:"healthCheckTimeout"`
+	HealthCheckPayload   []byte        `json:"healthCheckPayload"`
+	HealthCheckReconnect bool          `json:"healthCheckReconnect"`
+	HealthCheckMatcher   HealthCheckMatcher
+
+	// MaxConnectionAge, if set, makes the client reconnect once a connection has been open
+	// for that long, less up to 10% of random jitter, e.g. to spread clients across the
+	// backends of a load balancer. A write in progress is allowed to finish first; writes
+	// made while the new connection is established fail.
+	MaxConnectionAge time.Duration `json:"maxConnectionAge"`
+
+	// CircuitBreakerThreshold, if set, stops dialing an endpoint that is down: after that
+	// many consecutive failed dials the circuit breaker opens, and Connect and Reconnect
+	// (including automatic reconnects) fail with ErrCircuitOpen without dialing for
+	// CircuitBreakerCooldown (DefaultCircuitBreakerCooldown if zero). The next dial after
+	// that is a probe: it closes the circuit again if it succeeds and reopens it if it
+	// fails, and other dials fail with ErrCircuitOpen while it is in flight. Transitions
+	// are sent as CircuitBreakerEvents and counted in Stats.CircuitOpens.
+	CircuitBreakerThreshold int           `json:"circuitBreakerThreshold"`
+	CircuitBreakerCooldown  time.Duration `json:"circuitBreakerCooldown"`
+
+	// WriteQueueSize, if set, queues up to that many writes made while the client is not
+	// connected (before Connect, while reconnecting or after the connection was lost)
+	// instead of failing them, and writes them in order as soon as a connection is
+	// established, before Connected is closed and the AfterConnectHook is called. Write
+	// returns nil for a queued write, or ErrQueueFull once the queue holds WriteQueueSize
+	// writes or, if WriteQueueMaxBytes is set, that many bytes. Writes that waited longer
+	// than WriteQueueTTL, if set, are dropped instead and passed to the OnWriteExpiredHook.
+	WriteQueueSize     int           `json:"writeQueueSize"`
+	WriteQueueMaxBytes int           `json:"writeQueueMaxBytes"`
+	WriteQueueTTL      time.Duration `json:"writeQueueTTL"`
+	OnWriteExpiredHook OnWriteExpiredHook
+
+	// WriteQueueFile, if set, persists the write queue in an append-only file at that
+	// path, so queued writes survive a restart of the process: NewClient loads the
+	// writes left in the file and they are written once connected. Writes are removed
+	// from the file after each flush by replacing it with a new file, so a crash leaves
+	// either the old or the new one intact. WriteQueueFileMaxSize, if set, caps the size
+	// of the file in bytes; once it is reached writes fail with ErrQueueFull, as do
+	// writes longer than 16 MiB. Requires WriteQueueSize.
+	WriteQueueFile        string `json:"writeQueueFile"`
+	WriteQueueFileMaxSize int64  `json:"writeQueueFileMaxSize"`
+
+	// AckExtractor, if set, enables at-least-once delivery. Every write is numbered
+	// with a sequence number by the SequenceHook (or prefixed with it as a big-endian
+	// uint64) and kept until the endpoint acknowledges it: every message read is passed
+	// to the AckExtractor, and those it reports as acknowledgements are consumed instead
+	// of delivered. Unacknowledged writes are retransmitted, in order, as soon as a new
+	// connection is established, before the write queue is flushed. A write sent
+	// MaxDeliveryAttempts times (DefaultMaxDeliveryAttempts if zero) without being
+	// acknowledged, or still unacknowledged when the client is shut down, is given up on
+	// and passed to the OnDeliveryFailedHook. Keepalives and idle pings aren't numbered.
+	// Writes loaded from the WriteQueueFile keep the numbers they were given before the
+	// restart and are tracked like the others, but only their numbered payload is kept,
+	// so that is what the OnDeliveryFailedHook gets for them. It can't be combined with
+	// RingBufferSize, whose reads never reach the AckExtractor.
+	AckExtractor         AckExtractor
+	SequenceHook         SequenceHook
+	MaxDeliveryAttempts  int `json:"maxDeliveryAttempts"`
+	OnDeliveryFailedHook OnDeliveryFailedHook
+
+	// WriteErrorPolicy decides whether a write error closes the connection. By default
+	// (WriteErrorClose) any write error does; WriteErrorKeepOnTimeout only reports write
+	// timeouts. The error is returned by Write and passed to the OnErrorHook either way.
+	WriteErrorPolicy WriteErrorPolicy `json:"writeErrorPolicy"`
+
+	// DialRetries makes Connect and Reconnect retry a dial that failed with a temporary
+	// error (see ErrorClassifier), such as a refused connection or a DNS lookup failure, up
+	// to that many times before giving up. The first retry waits DialRetryDelay
+	// (DefaultDialRetryDelay if zero), doubling with every further retry, and no retry is
+	// made that would exceed DialRetryBudget since the first attempt, if set. This is
+	// separate from AutoReconnect, which applies once a connection has been established.
+	DialRetries     int           `json:"dialRetries"`
+	DialRetryDelay  time.Duration `json:"dialRetryDelay"`
+	DialRetryBudget time.Duration `json:"dialRetryBudget"`
+
+	// AutoReconnect makes the client call Reconnect by itself when the connection is lost
+	// because of an error (not after Close). The first attempt is made after ReconnectDelay
+	// (DefaultReconnectDelay if zero), and the delay doubles after every failed attempt up
+	// to MaxReconnectDelay (DefaultMaxReconnectDelay if zero), with some random jitter.
+	// Reconnecting stops once it succeeds, on Shutdown or when the error that closed the
+	// connection, or failed an attempt, is classified as ErrorFatal by ErrorClassifier
+	// (ClassifyError if nil). The OnReconnectAttemptHook is called before every attempt.
+	//
+	// MaxReconnectAttempts and MaxReconnectDuration, if set, cap the attempts made after
+	// the connection was lost, and the time spent on them: no attempt is made after that
+	// many have failed or that would start after MaxReconnectDuration. Reconnecting then
+	// stops with a ReconnectExhaustedEvent and a call to the OnReconnectExhaustedHook.
+	AutoReconnect            bool          `json:"autoReconnect"`
+	ReconnectDelay           time.Duration `json:"reconnectDelay"`
+	MaxReconnectDelay        time.Duration `json:"maxReconnectDelay"`
+	MaxReconnectAttempts     int           `json:"maxReconnectAttempts"`
+	MaxReconnectDuration     time.Duration `json:"maxReconnectDuration"`
+	ErrorClassifier          ErrorClassifier
+	OnReconnectAttemptHook   OnReconnectAttemptHook
+	OnReconnectExhaustedHook OnReconnectExhaustedHook
+
 	AfterReadHook        AfterReadHook
+	BeforeWriteHook      BeforeWriteHook
+	BeforeConnectHook    BeforeConnectHook
 	AfterConnectHook     AfterConnectHook
 	BeforeDisconnectHook BeforeDisconnectHook
 	OnErrorHook          OnErrorHook
+	OnReadTimeoutHook    OnReadTimeoutHook
+	OnStateChangeHook    OnStateChangeHook
 
-	UseTLS    bool
-	TLSConfig *tls.Config
+	// KeepReadingOnHookError makes an error returned by the AfterReadHook drop the message
+	// and pass the error to the OnErrorHook instead of closing the connection, so a single
+	// malformed message doesn't end the session.
+	KeepReadingOnHookError bool `json:"keepReadingOnHookError"`
+
+	// OnMessageHook, if set, switches the client to callback mode: an internal dispatcher
+	// consumes the Read channel (so it must not be read from elsewhere, including Stream
+	// and the Read helpers) and calls the hook for every message until Shutdown. Up to
+	// OnMessageConcurrency messages (1 if zero) are handled at a time; messages are only
+	// handled in order when it is 1.
+	OnMessageHook        OnMessageHook
+	OnMessageConcurrency int `json:"onMessageConcurrency"`
+
+	// ReadMiddleware wraps the delivery of every message read from the connection (before
+	// the AfterReadHook), and WriteMiddleware wraps every write to it (after the
+	// BeforeWriteHook), so the hooks always see the application's view of the data. The
+	// first Middleware of each list is the outermost. An error returned by the read
+	// chain closes the connection like an AfterReadHook error; one returned by the write
+	// chain is returned by Write.
+	ReadMiddleware  []Middleware
+	WriteMiddleware []Middleware
+
+	// EncryptionKey, if set, encrypts every message with AES-GCM using this pre-shared key
+	// (16, 24 or 32 bytes for AES-128, AES-192 or AES-256), for peers that can't use TLS.
+	// Each Write is sent as one length-prefixed frame holding a random nonce and the
+	// ciphertext, and each frame read is decrypted and delivered as one message on the
+	// Read channel. A frame that fails to decrypt closes the connection with ErrDecryption.
+	// The layer sits beneath ReadMiddleware and WriteMiddleware.
+	EncryptionKey []byte
+
+	// PSKIdentity and PSK, if set, secure the connection with a key shared with the
+	// endpoint instead of TLS, for embedded endpoints that can't do certificate-based TLS.
+	// On every new connection the client presents PSKIdentity and both sides derive a fresh
+	// AES-256 key from the PSK (at least MinPSKSize bytes), which encrypts everything
+	// written and read afterwards in the frames of EncryptionKey. Each side proves that it
+	// knows the PSK, without sending it, by sealing part of the handshake with that key.
+	// Connect fails with ErrPSKMismatch if the endpoint's proof doesn't match; see
+	// PSKServer for the endpoint's side. It can't be combined with UseTLS or StartTLSHook.
+	PSKIdentity string `json:"pskIdentity"`
+	PSK         []byte
+
+	// Checksum appends a CRC-32 (Castagnoli) to every Write, sent as one length-prefixed
+	// frame, and validates it on every frame read, which is then delivered as one message
+	// on the Read channel. It guards against corruption on lossy links such as
+	// serial-to-TCP bridges. A frame failing validation is counted in Stats.ChecksumErrors
+	// and passed to the OnChecksumErrorHook, or closes the connection with
+	// ErrChecksumMismatch if there is none. With EncryptionKey the checksum covers the
+	// encrypted frame.
+	Checksum            bool `json:"checksum"`
+	OnChecksumErrorHook OnChecksumErrorHook
+
+	// MaxWritesPerSecond and MaxBytesPerSecond limit the rate of calls to Write and of bytes
+	// written (before the BeforeWriteHook), so a client can't overwhelm a rate limited
+	// peer. Each is enforced by a token bucket holding one second's worth of tokens, so
+	// short bursts are allowed; zero means unlimited. RateLimitPolicy decides whether a
+	// Write exceeding a limit waits (the default) or fails with ErrRateLimited. TryWrite
+	// never waits.
+	MaxWritesPerSecond float64         `json:"maxWritesPerSecond"`
+	MaxBytesPerSecond  int             `json:"maxBytesPerSecond"`
+	RateLimitPolicy    RateLimitPolicy `json:"rateLimitPolicy"`
+
+	// Context variants of the hooks above, for hook functions shared between clients.
+	// Each receives a HookContext identifying the client. Setting both a hook and its
+	// context variant is an error.
+	AfterReadContextHook        AfterReadContextHook
+	BeforeWriteContextHook      BeforeWriteContextHook
+	AfterConnectContextHook     AfterConnectContextHook
+	BeforeDisconnectContextHook BeforeDisconnectContextHook
+	OnErrorContextHook          OnErrorContextHook
+	OnReadTimeoutContextHook    OnReadTimeoutContextHook
+
+	// Authenticator, if set, authenticates every new connection before it is reported as
+	// established, after TLS if any. The client sends the message returned by Start as
+	// a length-prefixed frame (a big-endian uint32 length followed by the payload) and
+	// reads one frame back, which opens with a status byte: AuthChallenge, answered with
+	// another frame holding the response returned by Challenge, AuthSuccess, which ends
+	// the exchange by calling Complete, or AuthFailure, which fails Connect with
+	// ErrAuthenticationFailed. The exchange must be done within ConnectionTimeout. See
+	// PlainAuthenticator for username/password authentication.
+	Authenticator Authenticator
+
+	// UseTLS dials the endpoint with TLS using TLSConfig, which must then be set; an empty
+	// tls.Config verifies the endpoint against the system roots. Config files and
+	// ConfigFromEnv, which can't hold a tls.Config, set UseTLS with an empty one.
+	UseTLS       bool `json:"useTLS"`
+	TLSConfig    *tls.Config
+	StartTLSHook StartTLSHook
+
+	// CertFile and KeyFile are paths to a PEM encoded client certificate and key used for
+	// mutual TLS. CAFile is a path to PEM encoded certificates used to verify the endpoint
+	// instead of the system roots. The files are loaded and validated on every Connect and
+	// are added to a copy of TLSConfig (if any). The certificate is reloaded whenever the
+	// files change on disk, so certificates can be rotated without recreating the Client.
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	CAFile   string `json:"caFile"`
+
+	// GetClientCertificate, if set, is called during every TLS handshake to provide the
+	// client certificate (see tls.Config.GetClientCertificate). It takes precedence over
+	// CertFile/KeyFile and TLSConfig.Certificates, and is the hook to use when certificates
+	// are rotated by something other than files on disk.
+	GetClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+
+	// PinnedPublicKeys and PinnedCertificates are base64 encoded SHA-256 hashes of a server
+	// certificate's SubjectPublicKeyInfo and of the DER encoded certificate respectively
+	// (an optional "sha256/" prefix is ignored). When any pins are configured the TLS
+	// handshake fails with ErrCertificatePinMismatch unless a certificate presented by the
+	// endpoint matches one of them. Pinning is checked in addition to normal verification.
+	PinnedPublicKeys   []string `json:"pinnedPublicKeys"`
+	PinnedCertificates []string `json:"pinnedCertificates"`
+
+	// NextProtos is the list of application protocols offered via ALPN, in order of
+	// preference. It overrides TLSConfig.NextProtos. The negotiated protocol is available
+	// from Client.GetNegotiatedProtocol once connected.
+	NextProtos []string `json:"nextProtos"`
+
+	// TLSMinVersion, TLSMaxVersion and TLSCipherSuites override the corresponding TLSConfig
+	// fields when set. In JSON they are given by name, e.g. "1.2" and
+	// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256" (see ParseTLSVersion and ParseCipherSuites).
+	TLSMinVersion   uint16   `json:"tlsMinVersion"`
+	TLSMaxVersion   uint16   `json:"tlsMaxVersion"`
+	TLSCipherSuites []uint16 `json:"cipherSuites"`
+
+	// CertExpiryHook, if set, is called after every TLS handshake for each peer certificate
+	// expiring within CertExpiryWarning (DefaultCertExpiryWarning if zero).
+	CertExpiryHook    CertExpiryHook
+	CertExpiryWarning time.Duration `json:"certExpiryWarning"`
+
+	// EnableNagle turns Nagle's algorithm back on for the TCP connection, trading latency
+	// for fewer, fuller packets when many small writes are made. Go disables it by default.
+	EnableNagle bool `json:"enableNagle"`
+
+	// EventsBufferSize is the capacity of the Client.Events channel (DefaultEventsBufferSize
+	// if zero). Events are dropped rather than blocking the client when it is full.
+	EventsBufferSize int `json:"eventsBufferSize"`
+
+	// Logger receives the client's internal errors (at level Error, in addition to the
+	// OnErrorHook) and lifecycle events such as connects and disconnects (at level Info).
+	// Records carry the client's endpoint as an attribute. Nothing is logged when nil.
+	Logger *slog.Logger
+
+	// HexDump enables hex dumps of all bytes read and written, for protocol debugging. Each
+	// dump is truncated to HexDumpLimit bytes (DefaultHexDumpLimit if zero, unlimited if
+	// negative) and passed to HexDumpHook, or logged at debug level if the hook is nil.
+	HexDump      bool `json:"hexDump"`
+	HexDumpLimit int  `json:"hexDumpLimit"`
+	HexDumpHook  HexDumpHook
+
+	// Capture, if set, receives every chunk of data read from or written to the connection
+	// along with timestamps, in the format described by CaptureWriter (typically an *os.File).
+	// Captures can be read back with ReadCapture and replayed with testutils.ReplayServer.
+	Capture io.Writer
+
+	// ReadTee, if set, receives a copy of all bytes read from the connection, as read and
+	// before the AfterReadHook, e.g. to archive a stream to disk or feed a decoder. With
+	// ReadTeeOnly the data is only written to ReadTee and not delivered on the Read channel
+	// (the AfterReadHook is not called either). Errors writing to ReadTee are passed to the
+	// OnErrorHook but don't close the connection.
+	ReadTee     io.Writer
+	ReadTeeOnly bool
+
+	// TracerProvider enables OpenTelemetry tracing. Connect, Reconnect and TLS handshakes
+	// get their own spans, and each connection is traced by a span lasting until it is
+	// closed which records writes and message deliveries as events. Tracing is disabled
+	// when nil.
+	TracerProvider trace.TracerProvider
+
+	// ExpvarPrefix, if set, publishes the client's endpoint, ID, labels, IsActive and
+	// State and its GetStats counters via the expvar package (and so on /debug/vars) as
+	// <prefix>.endpoint, <prefix>.id, <prefix>.labels, <prefix>.active, <prefix>.state and
+	// <prefix>.stats. Use a distinct prefix per client, e.g. "eventedconnection.upstream";
+	// NewClient fails if the names are taken.
+	ExpvarPrefix string `json:"expvarPrefix"`
+
+	// RateWindow, if set, maintains rolling rates of the bytes and messages read and
+	// written per second and of the errors per minute over the last RateWindow,
+	// sampled every RateSampleInterval (DefaultRateSampleInterval, or RateWindow if
+	// shorter, when zero). The rates as of the last sample are reported in Stats.Rates
+	// and passed to the OnRatesHook. Sampling runs until Shutdown.
+	RateWindow         time.Duration `json:"rateWindow"`
+	RateSampleInterval time.Duration `json:"rateSampleInterval"`
+	OnRatesHook        OnRatesHook
+
+	// Resolver is used to look up the endpoint's host when dialing. Provide a custom
+	// *net.Resolver (e.g. with PreferGo and a Dial func) to route DNS through an internal
+	// resolver or to bound lookup times. Defaults to the net package's default resolver.
+	Resolver *net.Resolver
+
+	// Transport, if set, opens the client's connections instead of dialing Endpoint over
+	// TCP, e.g. through an SSH tunnel, over a serial bridge or to one end of an in-memory
+	// pipe. Endpoint is optional then and only names the connection, so it needn't be a
+	// host:port (if it is, it still provides the TLS ServerName); SRVName isn't supported
+	// and the BeforeConnectHook isn't called.
+	// TLS (UseTLS or StartTLSHook), dial retries and the circuit breaker apply as usual.
+	// See TCPTransport and TLSTransport for the built-in transports.
+	Transport Transport
+
+	// Clock is the source of time for the deadlines set on the connection, the timers
+	// behind keepalives, idle checks, connection age, dial retries, reconnect backoff and
+	// rate limiting, and the timestamps in Stats. Set a fake clock (e.g.
+	// testutils.FakeClock) to test timing without real sleeps; deadlines are still
+	// enforced by the connection though, against the wall clock for TCP. Defaults to the
+	// system clock.
+	Clock Clock
+
+	// RandSource, if set, is the source of the random jitter added to reconnect and dial
+	// retry delays and taken off MaxConnectionAge, and of the order in which SRV targets of
+	// equal priority are tried, e.g. rand.NewPCG(seed, seed) to make reconnect behavior
+	// reproducible in tests and simulations. It is only used by the
+	// client it is passed to. Defaults to the math/rand/v2 global source.
+	RandSource rand.Source
+
+	// SRVService, SRVProto and SRVName describe an SRV record (_service._proto.name) used to
+	// discover the endpoint. When SRVName is set the records are resolved on every Connect
+	// (and so on every Reconnect) and Endpoint is ignored. SRVService and SRVProto may be
+	// empty to look up SRVName directly.
+	SRVService string `json:"srvService"`
+	SRVProto   string `json:"srvProto"`
+	SRVName    string `json:"srvName"`
 }
 
-// jsonConfig is used as a temp struct to unmarshal JSON into in order to properly parse
-// the duration attributes
-type jsonConfig struct {
-	Endpoint          string `json:"endpoint"`
-	ConnectionTimeout string `json:"connectionTimeout"`
-	ReadTimeout       string `json:"readTimeout"`
-	WriteTimeout      string `json:"writeTimeout"`
+// fileConfig is used as a temp struct to unmarshal JSON or TOML into in order to properly
+// parse the duration attributes
+type fileConfig struct {
+	Endpoint          string `json:"endpoint" toml:"endpoint"`
+	ConnectionTimeout string `json:"connectionTimeout" toml:"connectionTimeout"`
+	ReadTimeout       string `json:"readTimeout" toml:"readTimeout"`
+	WriteTimeout      string `json:"writeTimeout" toml:"writeTimeout"`
+
+	IdleTimeout     string `json:"idleTimeout" toml:"idleTimeout"`
+	IdleAction      string `json:"idleAction" toml:"idleAction"`
+	IdlePingPayload string `json:"idlePingPayload" toml:"idlePingPayload"`
+	WatchdogTimeout string `json:"watchdogTimeout" toml:"watchdogTimeout"`
+
+	KeepaliveInterval string `json:"keepaliveInterval" toml:"keepaliveInterval"`
+	KeepalivePayload  string `json:"keepalivePayload" toml:"keepalivePayload"`
+	MaxConnectionAge  string `json:"maxConnectionAge" toml:"maxConnectionAge"`
+
+	HealthCheckInterval  string `json:"healthCheckInterval" toml:"healthCheckInterval"`
+	HealthCheckTimeout   string `json:"healthCheckTimeout" toml:"healthCheckTimeout"`
+	HealthCheckPayload   string `json:"healthCheckPayload" toml:"healthCheckPayload"`
+	HealthCheckReconnect bool   `json:"healthCheckReconnect" toml:"healthCheckReconnect"`
+
+	WriteQueueSize        int    `json:"writeQueueSize" toml:"writeQueueSize"`
+	WriteQueueMaxBytes    int    `json:"writeQueueMaxBytes" toml:"writeQueueMaxBytes"`
+	WriteQueueTTL         string `json:"writeQueueTTL" toml:"writeQueueTTL"`
+	WriteQueueFile        string `json:"writeQueueFile" toml:"writeQueueFile"`
+	WriteQueueFileMaxSize int64  `json:"writeQueueFileMaxSize" toml:"writeQueueFileMaxSize"`
+	MaxDeliveryAttempts   int    `json:"maxDeliveryAttempts" toml:"maxDeliveryAttempts"`
+
+	CircuitBreakerThreshold int    `json:"circuitBreakerThreshold" toml:"circuitBreakerThreshold"`
+	CircuitBreakerCooldown  string `json:"circuitBreakerCooldown" toml:"circuitBreakerCooldown"`
+
+	DialRetries     int    `json:"dialRetries" toml:"dialRetries"`
+	DialRetryDelay  string `json:"dialRetryDelay" toml:"dialRetryDelay"`
+	DialRetryBudget string `json:"dialRetryBudget" toml:"dialRetryBudget"`
+
+	AutoReconnect        bool   `json:"autoReconnect" toml:"autoReconnect"`
+	ReconnectDelay       string `json:"reconnectDelay" toml:"reconnectDelay"`
+	MaxReconnectDelay    string `json:"maxReconnectDelay" toml:"maxReconnectDelay"`
+	MaxReconnectAttempts int    `json:"maxReconnectAttempts" toml:"maxReconnectAttempts"`
+	MaxReconnectDuration string `json:"maxReconnectDuration" toml:"maxReconnectDuration"`
 
-	ReadBufferSize int `json:"readBufferSize"`
+	ReadBufferSize  int  `json:"readBufferSize" toml:"readBufferSize"`
+	ReadChannelSize int  `json:"readChannelSize" toml:"readChannelSize"`
+	ValueReads      bool `json:"valueReads" toml:"valueReads"`
+
+	SlowConsumerThreshold string `json:"slowConsumerThreshold" toml:"slowConsumerThreshold"`
+	PooledReads           bool   `json:"pooledReads" toml:"pooledReads"`
+	RingBufferSize        int    `json:"ringBufferSize" toml:"ringBufferSize"`
+	ReplayBufferSize      int    `json:"replayBufferSize" toml:"replayBufferSize"`
+
+	ID     string            `json:"id" toml:"id"`
+	Labels map[string]string `json:"labels" toml:"labels"`
+
+	// Hooks maps hook fields (e.g. "afterReadHook") to names passed to RegisterHook
+	Hooks map[string]string `json:"hooks" toml:"hooks"`
+
+	SRVService   string `json:"srvService" toml:"srvService"`
+	SRVProto     string `json:"srvProto" toml:"srvProto"`
+	SRVName      string `json:"srvName" toml:"srvName"`
+	ExpvarPrefix string `json:"expvarPrefix" toml:"expvarPrefix"`
+	HexDump      bool   `json:"hexDump" toml:"hexDump"`
+	HexDumpLimit int    `json:"hexDumpLimit" toml:"hexDumpLimit"`
+
+	RateWindow         string `json:"rateWindow" toml:"rateWindow"`
+	RateSampleInterval string `json:"rateSampleInterval" toml:"rateSampleInterval"`
+
+	EventsBufferSize     int  `json:"eventsBufferSize" toml:"eventsBufferSize"`
+	EnableNagle          bool `json:"enableNagle" toml:"enableNagle"`
+	OnMessageConcurrency int  `json:"onMessageConcurrency" toml:"onMessageConcurrency"`
+	Checksum             bool `json:"checksum" toml:"checksum"`
+
+	KeepReadingOnHookError bool `json:"keepReadingOnHookError" toml:"keepReadingOnHookError"`
+
+	MaxWritesPerSecond float64 `json:"maxWritesPerSecond" toml:"maxWritesPerSecond"`
+	MaxBytesPerSecond  int     `json:"maxBytesPerSecond" toml:"maxBytesPerSecond"`
+	RateLimitPolicy    string  `json:"rateLimitPolicy" toml:"rateLimitPolicy"`
+	WriteErrorPolicy   string  `json:"writeErrorPolicy" toml:"writeErrorPolicy"`
+
+	PSKIdentity string `json:"pskIdentity" toml:"pskIdentity"`
+
+	UseTLS   bool   `json:"useTLS" toml:"useTLS"`
+	CertFile string `json:"certFile" toml:"certFile"`
+	KeyFile  string `json:"keyFile" toml:"keyFile"`
+	CAFile   string `json:"caFile" toml:"caFile"`
+
+	PinnedPublicKeys   []string `json:"pinnedPublicKeys" toml:"pinnedPublicKeys"`
+	PinnedCertificates []string `json:"pinnedCertificates" toml:"pinnedCertificates"`
+	NextProtos         []string `json:"nextProtos" toml:"nextProtos"`
+
+	TLSMinVersion   string   `json:"tlsMinVersion" toml:"tlsMinVersion"`
+	TLSMaxVersion   string   `json:"tlsMaxVersion" toml:"tlsMaxVersion"`
+	TLSCipherSuites []string `json:"cipherSuites" toml:"cipherSuites"`
+
+	CertExpiryWarning string `json:"certExpiryWarning" toml:"certExpiryWarning"`
 }
 
 // Unmarshal sets config fields from the JSON data. The timeout fields
 // are expected to conform to strings parsable by time.ParseDuration
 func (conf *Config) Unmarshal(jsonBody io.Reader) error {
-	var jc jsonConfig
-	err := json.NewDecoder(jsonBody).Decode(&jc)
+	var fc fileConfig
+	err := json.NewDecoder(jsonBody).Decode(&fc)
 	if err != nil {
 		return err
 	}
 
-	conf.Endpoint = jc.Endpoint
-	conf.ReadBufferSize = jc.ReadBufferSize
+	return conf.apply(&fc)
+}
 
-	conf.ConnectionTimeout, err = time.ParseDuration(jc.ConnectionTimeout)
+// apply sets config fields from a decoded config file
+// setUseTLS sets UseTLS for a config loaded from a file or the environment, giving it
+// an empty TLSConfig if it has none since those can't hold one.
+func (conf *Config) setUseTLS(useTLS bool) {
+	conf.UseTLS = useTLS
+	if useTLS && conf.TLSConfig == nil {
+		conf.TLSConfig = &tls.Config{}
+	}
+}
+
+func (conf *Config) apply(fc *fileConfig) (err error) {
+	conf.Endpoint = fc.Endpoint
+	conf.ReadBufferSize = fc.ReadBufferSize
+	conf.ReadChannelSize = fc.ReadChannelSize
+	conf.ValueReads = fc.ValueReads
+	conf.PooledReads = fc.PooledReads
+	conf.RingBufferSize = fc.RingBufferSize
+	conf.ReplayBufferSize = fc.ReplayBufferSize
+	conf.ID = fc.ID
+	conf.Labels = fc.Labels
+	conf.SRVService = fc.SRVService
+	conf.SRVProto = fc.SRVProto
+	conf.SRVName = fc.SRVName
+	conf.ExpvarPrefix = fc.ExpvarPrefix
+	conf.HexDump = fc.HexDump
+	conf.HexDumpLimit = fc.HexDumpLimit
+	conf.EventsBufferSize = fc.EventsBufferSize
+	conf.EnableNagle = fc.EnableNagle
+	conf.OnMessageConcurrency = fc.OnMessageConcurrency
+	conf.Checksum = fc.Checksum
+	conf.KeepReadingOnHookError = fc.KeepReadingOnHookError
+	conf.MaxWritesPerSecond = fc.MaxWritesPerSecond
+	conf.MaxBytesPerSecond = fc.MaxBytesPerSecond
+	conf.PSKIdentity = fc.PSKIdentity
+	conf.setUseTLS(fc.UseTLS)
+	conf.CertFile = fc.CertFile
+	conf.KeyFile = fc.KeyFile
+	conf.CAFile = fc.CAFile
+	conf.PinnedPublicKeys = fc.PinnedPublicKeys
+	conf.PinnedCertificates = fc.PinnedCertificates
+	conf.NextProtos = fc.NextProtos
+	conf.AutoReconnect = fc.AutoReconnect
+	conf.MaxReconnectAttempts = fc.MaxReconnectAttempts
+	conf.DialRetries = fc.DialRetries
+	conf.CircuitBreakerThreshold = fc.CircuitBreakerThreshold
+	conf.WriteQueueSize = fc.WriteQueueSize
+	conf.WriteQueueMaxBytes = fc.WriteQueueMaxBytes
+	conf.WriteQueueFile = fc.WriteQueueFile
+	conf.WriteQueueFileMaxSize = fc.WriteQueueFileMaxSize
+	conf.MaxDeliveryAttempts = fc.MaxDeliveryAttempts
+	if len(fc.IdlePingPayload) > 0 {
+		conf.IdlePingPayload = []byte(fc.IdlePingPayload)
+	}
+	if len(fc.KeepalivePayload) > 0 {
+		conf.KeepalivePayload = []byte(fc.KeepalivePayload)
+	}
+	if len(fc.HealthCheckPayload) > 0 {
+		conf.HealthCheckPayload = []byte(fc.HealthCheckPayload)
+	}
+	conf.HealthCheckReconnect = fc.HealthCheckReconnect
+
+	if err = conf.setNamedHooks(fc.Hooks); err != nil {
+		return err
+	}
+
+	if len(fc.TLSMinVersion) > 0 {
+		if conf.TLSMinVersion, err = ParseTLSVersion(fc.TLSMinVersion); err != nil {
+			return err
+		}
+	}
+
+	if len(fc.TLSMaxVersion) > 0 {
+		if conf.TLSMaxVersion, err = ParseTLSVersion(fc.TLSMaxVersion); err != nil {
+			return err
+		}
+	}
+
+	if len(fc.TLSCipherSuites) > 0 {
+		if conf.TLSCipherSuites, err = ParseCipherSuites(fc.TLSCipherSuites); err != nil {
+			return err
+		}
+	}
+
+	if len(fc.RateLimitPolicy) > 0 {
+		if conf.RateLimitPolicy, err = ParseRateLimitPolicy(fc.RateLimitPolicy); err != nil {
+			return err
+		}
+	}
+
+	if len(fc.IdleAction) > 0 {
+		if conf.IdleAction, err = ParseIdleAction(fc.IdleAction); err != nil {
+			return err
+		}
+	}
+
+	if len(fc.WriteErrorPolicy) > 0 {
+		if conf.WriteErrorPolicy, err = ParseWriteErrorPolicy(fc.WriteErrorPolicy); err != nil {
+			return err
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value string
+		field *time.Duration
+	}{
+		{"idleTimeout", fc.IdleTimeout, &conf.IdleTimeout},
+		{"watchdogTimeout", fc.WatchdogTimeout, &conf.WatchdogTimeout},
+		{"rateWindow", fc.RateWindow, &conf.RateWindow},
+		{"rateSampleInterval", fc.RateSampleInterval, &conf.RateSampleInterval},
+		{"healthCheckInterval", fc.HealthCheckInterval, &conf.HealthCheckInterval},
+		{"healthCheckTimeout", fc.HealthCheckTimeout, &conf.HealthCheckTimeout},
+		{"keepaliveInterval", fc.KeepaliveInterval, &conf.KeepaliveInterval},
+		{"maxConnectionAge", fc.MaxConnectionAge, &conf.MaxConnectionAge},
+		{"writeQueueTTL", fc.WriteQueueTTL, &conf.WriteQueueTTL},
+		{"circuitBreakerCooldown", fc.CircuitBreakerCooldown, &conf.CircuitBreakerCooldown},
+		{"slowConsumerThreshold", fc.SlowConsumerThreshold, &conf.SlowConsumerThreshold},
+		{"dialRetryDelay", fc.DialRetryDelay, &conf.DialRetryDelay},
+		{"dialRetryBudget", fc.DialRetryBudget, &conf.DialRetryBudget},
+		{"reconnectDelay", fc.ReconnectDelay, &conf.ReconnectDelay},
+		{"maxReconnectDelay", fc.MaxReconnectDelay, &conf.MaxReconnectDelay},
+		{"maxReconnectDuration", fc.MaxReconnectDuration, &conf.MaxReconnectDuration},
+		{"certExpiryWarning", fc.CertExpiryWarning, &conf.CertExpiryWarning},
+	}
+	for _, d := range durations {
+		if len(d.value) > 0 {
+			if *d.field, err = time.ParseDuration(d.value); err != nil {
+				return fmt.Errorf("%s: %w", d.name, err)
+			}
+		}
+	}
+
+	conf.ConnectionTimeout, err = time.ParseDuration(fc.ConnectionTimeout)
 	if err != nil {
 		return err
 	}
 
-	conf.ReadTimeout, err = time.ParseDuration(jc.ReadTimeout)
+	conf.ReadTimeout, err = time.ParseDuration(fc.ReadTimeout)
 	if err != nil {
 		return err
 	}
 
-	conf.WriteTimeout, err = time.ParseDuration(jc.WriteTimeout)
+	conf.WriteTimeout, err = time.ParseDuration(fc.WriteTimeout)
 
 	return err
 }
 
 // NewConfig instantiates a config object with defaults
 func NewConfig() *Config {
-	l := log.New(os.Stderr, "", 0)
-
 	conf := Config{
 		ReadBufferSize:    DefaultReadBufferSize,
 		ConnectionTimeout: DefaultConnectionTimeout,
 		ReadTimeout:       DefaultReadTimeout,
 		WriteTimeout:      DefaultWriteTimeout,
 
-		// Write to stderr by default
-		OnErrorHook: func(err error) error {
-			l.Println(err)
-			return err
-		},
+		// Log to stderr by default
+		Logger: slog.New(slog.NewTextHandler(os.Stderr, nil)),
 	}
 
 	return &conf
@@ -0,0 +1,40 @@
+package eventedconnection
+
+import (
+	"context"
+	"time"
+)
+
+// Connection is the part of Client's API that applications typically build on:
+// connecting, writing, reading and following the connection's lifecycle. Depend on
+// it instead of *Client to substitute a fake in unit tests, such as
+// testutils.MockConnection, which needs no sockets.
+type Connection interface {
+	Connect() error
+	Reconnect() error
+	IsActive() bool
+	State() State
+	Err() error
+	Done() <-chan struct{}
+
+	Write(data *[]byte) error
+	WriteBytes(data []byte) error
+	WriteString(s string) error
+	WriteWithTimeout(data *[]byte, timeout time.Duration) error
+
+	ReadContext(ctx context.Context) ([]byte, error)
+	ReadWithTimeout(d time.Duration) ([]byte, error)
+	Subscribe() <-chan []byte
+	Unsubscribe(ch <-chan []byte)
+	SubscribeEvents() (<-chan Event, func())
+
+	Close()
+	CloseWithError(reason error)
+	CloseGracefully(ctx context.Context) error
+	Shutdown()
+	WaitClosed()
+
+	GetStats() Stats
+}
+
+var _ Connection = (*Client)(nil)
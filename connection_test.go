@@ -0,0 +1,60 @@
+package eventedconnection_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+// request writes query and returns the answer, the way an application would use a
+// Connection
+func request(con Connection, query string) (string, error) {
+	if err := con.WriteString(query); err != nil {
+		return "", err
+	}
+	answer, err := con.ReadWithTimeout(time.Second)
+	return string(answer), err
+}
+
+func TestMockConnection(t *testing.T) {
+	mock := testutils.NewMockConnection()
+	mock.OnWrite = func(data []byte) error {
+		return mock.Deliver(bytes.ToUpper(data))
+	}
+	events, cancel := mock.SubscribeEvents()
+	defer cancel()
+
+	_, err := request(mock, "too early")
+	assertEqual(t, err, ErrNotConnected)
+
+	if err = mock.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := (<-events).(ConnectedEvent); !ok {
+		t.Error("Expected a ConnectedEvent")
+	}
+
+	answer, err := request(mock, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, answer, "HELLO")
+	assertEqual(t, len(mock.Written()), 1)
+	assertEqual(t, string(mock.Written()[0]), "hello")
+	assertEqual(t, mock.GetStats().BytesWritten, uint64(5))
+
+	mock.Drop(io.EOF)
+	if event, ok := (<-events).(DisconnectedEvent); !ok || event.Err != io.EOF {
+		t.Errorf("Expected a DisconnectedEvent with io.EOF, got %v", event)
+	}
+	assertEqual(t, mock.Err(), io.EOF)
+	_, err = mock.ReadWithTimeout(time.Second)
+	assertEqual(t, err, ErrClosed)
+
+	mock.Shutdown()
+	assertEqual(t, mock.Connect(), ErrShutdown)
+}
@@ -0,0 +1,47 @@
+package eventedconnection
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// ConnectionInfo describes the current connection, see Client.GetConnectionInfo
+type ConnectionInfo struct {
+	Endpoint    string    // host:port that was dialed, e.g. the target picked by SRV lookup
+	LocalAddr   net.Addr  // local address of the connection
+	RemoteAddr  net.Addr  // remote address of the connection
+	ConnectedAt time.Time // when the connection was established
+
+	// TLS is the state of the TLS connection (negotiated version, cipher suite, peer
+	// certificates, etc), or nil if the connection doesn't use TLS.
+	TLS *tls.ConnectionState
+
+	// NegotiatedProtocol is the application protocol negotiated via ALPN, or an empty
+	// string if none was negotiated.
+	NegotiatedProtocol string
+}
+
+// GetConnectionInfo returns the addresses, connect time and TLS parameters of the
+// current connection. The second return value is false if the client is not connected.
+func (conn *Client) GetConnectionInfo() (ConnectionInfo, bool) {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+
+	if conn.c == nil {
+		return ConnectionInfo{}, false
+	}
+
+	info := ConnectionInfo{
+		Endpoint:    conn.remoteEndpoint,
+		LocalAddr:   conn.c.LocalAddr(),
+		RemoteAddr:  conn.c.RemoteAddr(),
+		ConnectedAt: conn.connectedAt,
+	}
+	if tlsConn, ok := conn.c.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		info.TLS = &state
+		info.NegotiatedProtocol = state.NegotiatedProtocol
+	}
+	return info, true
+}
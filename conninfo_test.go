@@ -0,0 +1,92 @@
+package eventedconnection_test
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_GetConnectionInfo(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String()}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	_, ok := con.GetConnectionInfo()
+	assertEqual(t, ok, false)
+
+	before := time.Now()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	info, ok := con.GetConnectionInfo()
+	assertEqual(t, ok, true)
+	assertEqual(t, info.Endpoint, l.Addr().String())
+	assertEqual(t, info.RemoteAddr.(*net.TCPAddr).Port, l.Addr().(*net.TCPAddr).Port)
+	assertNotNil(t, info.LocalAddr)
+	if info.ConnectedAt.Before(before) || info.ConnectedAt.After(time.Now()) {
+		t.Fatalf("Expected the connect time to be between %v and now, got %v", before, info.ConnectedAt)
+	}
+	if info.TLS != nil {
+		t.Fatal("Expected no TLS state for a plain connection")
+	}
+	assertEqual(t, info.NegotiatedProtocol, "")
+
+	con.Close()
+	_, ok = con.GetConnectionInfo()
+	assertEqual(t, ok, false)
+}
+
+func TestClient_GetConnectionInfoTLS(t *testing.T) {
+	done := make(chan bool)
+	cert, err := tls.LoadX509KeyPair("./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := testutils.TLSEchoServerWithConfig(done, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"evented/1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:      l.Addr().String(),
+		UseTLS:        true,
+		TLSConfig:     &tls.Config{InsecureSkipVerify: true},
+		TLSMinVersion: tls.VersionTLS13,
+		NextProtos:    []string{"evented/1"},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	info, ok := con.GetConnectionInfo()
+	assertEqual(t, ok, true)
+	if info.TLS == nil {
+		t.Fatal("Expected the TLS state of the connection")
+	}
+	assertEqual(t, info.TLS.Version, uint16(tls.VersionTLS13))
+	assertEqual(t, info.TLS.PeerCertificates[0].Equal(cert.Leaf), true)
+	assertEqual(t, info.NegotiatedProtocol, "evented/1")
+}
@@ -0,0 +1,56 @@
+package eventedconnection
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+)
+
+// DefaultHexDumpLimit is the default number of bytes of each read or write included in a hex dump
+const DefaultHexDumpLimit = 256
+
+// Direction identifies which way data was travelling over the connection
+type Direction int
+
+const (
+	// DirectionRead is data read from the endpoint
+	DirectionRead Direction = iota
+	// DirectionWrite is data written to the endpoint
+	DirectionWrite
+)
+
+func (d Direction) String() string {
+	if d == DirectionWrite {
+		return "write"
+	}
+	return "read"
+}
+
+// HexDumpHook receives a hex dump (in the format of hex.Dump) of data read from or
+// written to the connection when Config.HexDump is enabled.
+type HexDumpHook func(direction Direction, dump string)
+
+// hexDump dumps data if hex dumps are enabled. Dumps go to the HexDumpHook if
+// configured and are otherwise logged at debug level.
+func (conn *Client) hexDump(direction Direction, data []byte) {
+	if !conn.hexDumpEnabled || len(data) == 0 {
+		return
+	}
+
+	dump := hexDumpTruncated(data, conn.hexDumpLimit)
+	if conn.hexDumpHook != nil {
+		conn.hexDumpHook(direction, dump)
+		return
+	}
+
+	conn.logger.Debug(direction.String(), slog.Int("size", len(data)), slog.String("dump", dump))
+}
+
+// hexDumpTruncated dumps at most limit bytes of data (all of it if limit is negative)
+func hexDumpTruncated(data []byte, limit int) string {
+	if limit < 0 || len(data) <= limit {
+		return hex.Dump(data)
+	}
+
+	return hex.Dump(data[:limit]) + fmt.Sprintf("... %d more bytes\n", len(data)-limit)
+}
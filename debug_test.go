@@ -0,0 +1,64 @@
+package eventedconnection_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_HexDump(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	var mutex sync.Mutex
+	dumps := map[Direction]string{}
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		HexDump:      true,
+		HexDumpLimit: 4,
+		HexDumpHook: func(direction Direction, dump string) {
+			mutex.Lock()
+			dumps[direction] = dump
+			mutex.Unlock()
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	payload := []byte("Testing hex dumps")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-con.Read:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	expected := hex.Dump(payload[:4]) + "... 13 more bytes\n"
+	assertEqual(t, dumps[DirectionWrite], expected)
+	assertEqual(t, dumps[DirectionRead], expected)
+	assertEqual(t, strings.Contains(dumps[DirectionRead], "54 65 73 74"), true)
+}
@@ -0,0 +1,222 @@
+package eventedconnection
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// dialer builds the net.Dialer used for establishing the TCP connection
+func (conn *Client) dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:  conn.GetConnectionTimeout(),
+		Resolver: conn.resolver,
+	}
+}
+
+// dial opens a connection to the first reachable endpoint (or with Config.Transport)
+// and returns it along with the endpoint it is connected to. The last dial error is
+// returned if none of the endpoints could be reached.
+func (conn *Client) dial(ctx context.Context) (net.Conn, string, error) {
+	tlsConfig, err := conn.loadTLSConfig()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if conn.transport != nil {
+		return conn.dialTransport(ctx, tlsConfig)
+	}
+
+	endpoints, err := conn.endpoints()
+	if err != nil {
+		return nil, "", wrapTimeout(ErrConnectTimeout, err)
+	}
+
+	for _, endpoint := range endpoints {
+		params := &DialParams{Endpoint: endpoint, TLSConfig: tlsConfig, Dialer: conn.dialer()}
+		if conn.beforeConnectHook != nil {
+			if params.TLSConfig != nil {
+				params.TLSConfig = params.TLSConfig.Clone() // don't let the hook modify the shared config
+			}
+			if err = conn.beforeConnectHook(params); err != nil {
+				continue
+			}
+		}
+
+		var connection net.Conn
+		connection, err = conn.dialEndpoint(ctx, params)
+		if err == nil {
+			return connection, params.Endpoint, nil
+		}
+	}
+
+	return nil, "", wrapTimeout(ErrConnectTimeout, err)
+}
+
+// dialWithRetries dials, retrying temporary failures (see Config.ErrorClassifier) up
+// to Config.DialRetries times with exponential backoff for as long as
+// Config.DialRetryBudget allows. Failures of attempts that are retried are only logged.
+func (conn *Client) dialWithRetries(ctx context.Context) (net.Conn, string, error) {
+	start := conn.clock.Now()
+	delay := conn.dialRetryDelay
+	for attempt := 1; ; attempt++ {
+		connection, endpoint, err := conn.dialThroughBreaker(ctx)
+		if err == nil || attempt > conn.dialRetries || errors.Is(err, ErrCircuitOpen) ||
+			conn.classifyError(err) == ErrorFatal {
+			return connection, endpoint, err
+		}
+
+		wait := conn.jitter(delay)
+		if conn.dialRetryBudget > 0 && conn.since(start)+wait > conn.dialRetryBudget {
+			return nil, "", err
+		}
+		conn.logger.Info("dial failed, retrying", slog.Any("error", err), slog.Int("attempt", attempt))
+
+		timer := conn.clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-conn.done:
+			timer.Stop()
+			return nil, "", err
+		}
+		delay *= 2
+	}
+}
+
+// dialEndpoint opens a TCP (or TLS) connection to a single host:port
+func (conn *Client) dialEndpoint(ctx context.Context, params *DialParams) (net.Conn, error) {
+	transport := TCPTransport{Address: params.Endpoint, Dialer: params.Dialer}
+	connection, err := transport.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.setUpConnection(ctx, connection, params.Endpoint, params.TLSConfig)
+}
+
+// setUpConnection applies Config.EnableNagle to a newly opened connection,
+// upgrades it to TLS if the client uses TLS (or secures it with Config.PSK) and
+// authenticates it if there is an Authenticator. When a StartTLSHook is configured
+// the connection is handed to the hook before being upgraded. connection is closed
+// if any of it fails.
+func (conn *Client) setUpConnection(ctx context.Context, connection net.Conn, endpoint string, tlsConfig *tls.Config) (net.Conn, error) {
+	if tcpConn, ok := connection.(*net.TCPConn); ok && conn.enableNagle {
+		if err := tcpConn.SetNoDelay(false); err != nil {
+			connection.Close()
+			return nil, err
+		}
+	}
+
+	var err error
+	switch {
+	case conn.startTLSHook != nil:
+		if connection, err = conn.startTLS(ctx, connection, endpoint, tlsConfig); err != nil {
+			return nil, err
+		}
+	case conn.useTLS:
+		tlsConn, err := conn.handshake(ctx, connection, tlsConfig, endpoint)
+		if err != nil {
+			connection.Close()
+			return nil, err
+		}
+		connection = tlsConn
+	case len(conn.psk) > 0:
+		pskConn, err := conn.pskHandshake(ctx, connection)
+		if err != nil {
+			connection.Close()
+			return nil, err
+		}
+		connection = pskConn
+	}
+
+	if conn.authenticator != nil {
+		if err = conn.authenticate(ctx, connection); err != nil {
+			connection.Close()
+			return nil, err
+		}
+	}
+
+	return connection, nil
+}
+
+// endpoints returns the list of host:port pairs to try, in order. Without an
+// SRV name this is just conn.endpoint.
+func (conn *Client) endpoints() ([]string, error) {
+	if len(conn.srvName) == 0 {
+		return []string{conn.GetEndpoint()}, nil
+	}
+
+	resolver := conn.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), conn.GetConnectionTimeout())
+	defer cancel()
+
+	_, records, err := resolver.LookupSRV(ctx, conn.srvService, conn.srvProto, conn.srvName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("no SRV records found for " + conn.srvName)
+	}
+	conn.orderSRV(records)
+
+	endpoints := make([]string, 0, len(records))
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		endpoints = append(endpoints, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+
+	return endpoints, nil
+}
+
+// orderSRV sorts records by priority and shuffles those of equal priority by weight
+// (RFC 2782) using conn.random. LookupSRV does the same with the global source, so the
+// records are put in a canonical order first to make the result depend on
+// Config.RandSource alone.
+func (conn *Client) orderSRV(records []*net.SRV) {
+	slices.SortFunc(records, func(a, b *net.SRV) int {
+		return cmp.Or(
+			cmp.Compare(a.Priority, b.Priority),
+			strings.Compare(a.Target, b.Target),
+			cmp.Compare(a.Port, b.Port),
+		)
+	})
+
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && records[end].Priority == records[start].Priority {
+			end++
+		}
+		conn.shuffleByWeight(records[start:end])
+		start = end
+	}
+}
+
+// shuffleByWeight orders records of equal priority so that each is picked next with a
+// probability proportional to its weight
+func (conn *Client) shuffleByWeight(records []*net.SRV) {
+	sum := 0
+	for _, record := range records {
+		sum += int(record.Weight)
+	}
+	for sum > 0 && len(records) > 1 {
+		n := conn.random.intN(sum)
+		for i, s := 0, 0; i < len(records); i++ {
+			if s += int(records[i].Weight); s > n {
+				records[0], records[i] = records[i], records[0]
+				break
+			}
+		}
+		sum -= int(records[0].Weight)
+		records = records[1:]
+	}
+}
@@ -0,0 +1,30 @@
+package eventedconnection
+
+// startDispatcher starts the goroutines calling the OnMessageHook for every message
+// on the Read (or Data) channel. They run until Shutdown.
+func (conn *Client) startDispatcher(concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	for range concurrency {
+		conn.clientRoutines.start(conn.dispatch)
+	}
+}
+
+func (conn *Client) dispatch() {
+	for {
+		select {
+		case data := <-conn.Read:
+			if err := conn.onMessageHook(*data); err != nil {
+				conn.handleError(err)
+			}
+		case data := <-conn.Data:
+			if err := conn.onMessageHook(data); err != nil {
+				conn.handleError(err)
+			}
+		case <-conn.done:
+			return
+		}
+	}
+}
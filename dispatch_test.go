@@ -0,0 +1,70 @@
+package eventedconnection_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_OnMessageHook(t *testing.T) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := make(chan string, 4)
+	errs := make(chan error, 4)
+	errRejected := errors.New("rejected")
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnMessageHook: func(data []byte) error {
+			messages <- string(data)
+			if string(data) == "reject" {
+				return errRejected
+			}
+			return nil
+		},
+		OnErrorHook: func(err error) error {
+			errs <- err
+			return err
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range []string{"reject", "hello"} {
+		data := []byte(message)
+		if err = con.Write(&data); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case received := <-messages:
+			assertEqual(t, received, message)
+		case <-time.After(time.Second):
+			t.Fatalf("OnMessageHook wasn't called for %q", message)
+		}
+	}
+
+	select {
+	case err = <-errs:
+		assertEqual(t, err, errRejected)
+	case <-time.After(time.Second):
+		t.Fatal("expected the hook's error to be passed to the OnErrorHook")
+	}
+	assertEqual(t, con.IsActive(), true)
+}
@@ -0,0 +1,41 @@
+package eventedconnection
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+)
+
+// newEncryptionLayer returns a framedLayer encrypting every message with AES-GCM using
+// key and a random nonce, which is sent in front of the ciphertext
+func newEncryptionLayer(key []byte) (*framedLayer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &framedLayer{
+		encode: func(data []byte) ([]byte, error) {
+			sealed := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+			if _, err := rand.Read(sealed); err != nil {
+				return nil, err
+			}
+			return aead.Seal(sealed, sealed, data, nil), nil
+		},
+		decode: func(payload []byte) ([]byte, error) {
+			if len(payload) < aead.NonceSize() {
+				return nil, ErrDecryption
+			}
+			nonce, ciphertext := payload[:aead.NonceSize()], payload[aead.NonceSize():]
+			message, err := aead.Open(nil, nonce, ciphertext, nil)
+			if err != nil {
+				return nil, ErrDecryption
+			}
+			return message, nil
+		},
+	}, nil
+}
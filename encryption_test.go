@@ -0,0 +1,111 @@
+package eventedconnection_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+var testEncryptionKey = bytes.Repeat([]byte{0x42}, 32)
+
+func TestClient_EncryptionKey(t *testing.T) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written [][]byte
+	conf := Config{
+		Endpoint:      l.Addr().String(),
+		ReadTimeout:   2 * time.Second,
+		WriteTimeout:  1 * time.Second,
+		EncryptionKey: testEncryptionKey,
+		WriteMiddleware: []Middleware{func(next Handler) Handler {
+			return func(data []byte) error {
+				written = append(written, data)
+				return next(data)
+			}
+		}},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the echoed frames may arrive in one read but are delivered as separate messages
+	for _, message := range []string{"first secret", "second secret"} {
+		data := []byte(message)
+		if err = con.Write(&data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, expected := range []string{"first secret", "second secret"} {
+		select {
+		case data := <-con.Read:
+			assertEqual(t, string(*data), expected)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %q", expected)
+		}
+	}
+
+	// middleware sees the plaintext
+	assertEqual(t, string(written[0]), "first secret")
+	assertEqual(t, con.GetStats().BytesWritten > uint64(len("first secretsecond secret")), true)
+}
+
+func TestClient_EncryptionKeyMismatch(t *testing.T) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.Write(append([]byte{0, 0, 0, 16}, bytes.Repeat([]byte{1}, 16)...))
+		time.Sleep(time.Second)
+	}()
+
+	errs := make(chan error, 4)
+	conf := Config{
+		Endpoint:      l.Addr().String(),
+		ReadTimeout:   2 * time.Second,
+		WriteTimeout:  1 * time.Second,
+		EncryptionKey: testEncryptionKey,
+		OnErrorHook: func(err error) error {
+			errs <- err
+			return err
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-con.Disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("expected a frame that fails to decrypt to close the connection")
+	}
+	assertEqual(t, <-errs, ErrDecryption)
+	assertEqual(t, con.Err(), ErrDecryption)
+}
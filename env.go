@@ -0,0 +1,82 @@
+package eventedconnection
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ConfigFromEnv returns a config with the defaults of NewConfig overridden by the
+// environment variables below, each prefixed with prefix (e.g. "EVENTED_" for
+// EVENTED_ENDPOINT). Variables that are unset or empty leave the default in place.
+//
+//	ENDPOINT            Endpoint
+//	CONNECTION_TIMEOUT  ConnectionTimeout, parsed by time.ParseDuration
+//	READ_TIMEOUT        ReadTimeout, parsed by time.ParseDuration
+//	WRITE_TIMEOUT       WriteTimeout, parsed by time.ParseDuration
+//	READ_BUFFER_SIZE    ReadBufferSize
+//	USE_TLS             UseTLS, parsed by strconv.ParseBool
+//	CERT_FILE           CertFile
+//	KEY_FILE            KeyFile
+//	CA_FILE             CAFile
+func ConfigFromEnv(prefix string) (*Config, error) {
+	conf := NewConfig()
+
+	lookup := func(name string) (string, string, bool) {
+		key := prefix + name
+		value := os.Getenv(key)
+		return key, value, len(value) > 0
+	}
+
+	if _, value, ok := lookup("ENDPOINT"); ok {
+		conf.Endpoint = value
+	}
+
+	durations := []struct {
+		name  string
+		field *time.Duration
+	}{
+		{"CONNECTION_TIMEOUT", &conf.ConnectionTimeout},
+		{"READ_TIMEOUT", &conf.ReadTimeout},
+		{"WRITE_TIMEOUT", &conf.WriteTimeout},
+	}
+	for _, d := range durations {
+		if key, value, ok := lookup(d.name); ok {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			*d.field = duration
+		}
+	}
+
+	if key, value, ok := lookup("READ_BUFFER_SIZE"); ok {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		conf.ReadBufferSize = size
+	}
+
+	if key, value, ok := lookup("USE_TLS"); ok {
+		useTLS, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		conf.setUseTLS(useTLS)
+	}
+
+	files := map[string]*string{
+		"CERT_FILE": &conf.CertFile,
+		"KEY_FILE":  &conf.KeyFile,
+		"CA_FILE":   &conf.CAFile,
+	}
+	for name, field := range files {
+		if _, value, ok := lookup(name); ok {
+			*field = value
+		}
+	}
+
+	return conf, nil
+}
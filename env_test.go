@@ -0,0 +1,34 @@
+package eventedconnection_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("EVENTED_ENDPOINT", "localhost:8080")
+	t.Setenv("EVENTED_READ_TIMEOUT", "90s")
+	t.Setenv("EVENTED_READ_BUFFER_SIZE", "4096")
+	t.Setenv("EVENTED_USE_TLS", "true")
+	t.Setenv("EVENTED_CA_FILE", "./testutils/testserver.crt")
+
+	conf, err := ConfigFromEnv("EVENTED_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, conf.Endpoint, "localhost:8080")
+	assertEqual(t, conf.ReadTimeout, 90*time.Second)
+	assertEqual(t, conf.WriteTimeout, DefaultWriteTimeout)
+	assertEqual(t, conf.ReadBufferSize, 4096)
+	assertEqual(t, conf.UseTLS, true)
+	assertEqual(t, conf.TLSConfig != nil, true)
+	assertEqual(t, conf.CAFile, "./testutils/testserver.crt")
+	assertEqual(t, conf.CertFile, "")
+
+	t.Setenv("EVENTED_WRITE_TIMEOUT", "soon")
+	_, err = ConfigFromEnv("EVENTED_")
+	assertNotNil(t, err)
+}
@@ -0,0 +1,119 @@
+package eventedconnection
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrCertificatePinMismatch is returned (wrapped) by Connect when none of the certificates
+// presented by the endpoint match Config.PinnedPublicKeys or Config.PinnedCertificates.
+var ErrCertificatePinMismatch = errors.New("certificate pin mismatch")
+
+// ErrNotConnected is returned by Write when the client hasn't connected yet (or is
+// still connecting).
+var ErrNotConnected = errors.New("not connected")
+
+// ErrClosed is returned by Write when the connection has been closed, or is being
+// closed by CloseGracefully, and by ReadContext and ReadWithTimeout when the connection
+// is closed before a message arrives.
+var ErrClosed = errors.New("connection is closed")
+
+// ErrConnectTimeout wraps the error of a Connect or Reconnect that timed out, see
+// Config.ConnectionTimeout.
+var ErrConnectTimeout = errors.New("connect timed out")
+
+// ErrWriteTimeout wraps the error of a Write that timed out, see Config.WriteTimeout.
+var ErrWriteTimeout = errors.New("write timed out")
+
+// ErrShutdown is returned by Connect and Reconnect once the client has been shut down,
+// and by Err after Shutdown.
+var ErrShutdown = errors.New("client has been shut down")
+
+// ErrWriteBusy is returned by TryWrite when another write is in progress.
+var ErrWriteBusy = errors.New("write in progress")
+
+// ErrRateLimited is returned by Write when the write would exceed Config.MaxWritesPerSecond
+// or Config.MaxBytesPerSecond, see RateLimitPolicy.
+var ErrRateLimited = errors.New("write rate limit exceeded")
+
+// ErrDisconnected is the same error as ErrClosed.
+//
+// Deprecated: use ErrClosed.
+var ErrDisconnected = ErrClosed
+
+// ErrReadTimeout is returned by ReadWithTimeout when no message arrives in time. It
+// also wraps the error closing the connection (see Client.Err) when nothing was read
+// within Config.ReadTimeout.
+var ErrReadTimeout = errors.New("read timed out")
+
+// ErrIdle is the error closing the connection when nothing was read within
+// Config.IdleTimeout and the IdleAction is IdleClose.
+var ErrIdle = errors.New("connection idle")
+
+// ErrCircuitOpen is returned by Connect and Reconnect without dialing while the circuit
+// breaker is open, see Config.CircuitBreakerThreshold.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// ErrQueueFull is returned by Write when the write queue has no room for the write,
+// see Config.WriteQueueSize and Config.WriteQueueMaxBytes.
+var ErrQueueFull = errors.New("write queue is full")
+
+// ErrNoRoute is returned by Router.Dispatch for a message that matches no route when
+// the Router has no fallback handler.
+var ErrNoRoute = errors.New("no route matches message")
+
+// ErrDecryption is the error closing the connection when a message read from it can't be
+// decrypted or authenticated with Config.EncryptionKey.
+var ErrDecryption = errors.New("message decryption failed")
+
+// ErrChecksumMismatch is the error closing the connection when a frame read from it fails
+// checksum validation (see Config.Checksum) and there is no OnChecksumErrorHook.
+var ErrChecksumMismatch = errors.New("frame checksum mismatch")
+
+// ErrAuthenticationFailed is returned (wrapped, with the endpoint's reason if it gave one)
+// by Connect when the endpoint rejects the credentials of Config.Authenticator.
+var ErrAuthenticationFailed = errors.New("authentication failed")
+
+// ErrPSKMismatch is returned (wrapped) by Connect when the endpoint doesn't prove that it
+// knows Config.PSK, and by PSKServer when the client doesn't.
+var ErrPSKMismatch = errors.New("pre-shared key mismatch")
+
+// ErrHealthCheckTimeout is the error of a health check whose probe wasn't answered
+// within Config.HealthCheckTimeout.
+var ErrHealthCheckTimeout = errors.New("health check timed out")
+
+// timeoutError is a timeout that matches both a sentinel such as ErrWriteTimeout and
+// the underlying error with errors.Is, and still reports Timeout like the net error
+type timeoutError struct {
+	sentinel error
+	err      error
+}
+
+func (e *timeoutError) Error() string   { return e.sentinel.Error() + ": " + e.err.Error() }
+func (e *timeoutError) Unwrap() []error { return []error{e.sentinel, e.err} }
+func (e *timeoutError) Timeout() bool   { return true }
+func (e *timeoutError) Temporary() bool { return true }
+
+// opError adds the op and addresses of connection to a timeout that doesn't carry them,
+// such as the context error of a TLS handshake, the way the net package reports its errors
+func opError(op string, connection net.Conn, err error) error {
+	var netErr *net.OpError
+	if !isTimeout(err) || errors.As(err, &netErr) {
+		return err
+	}
+	return &net.OpError{
+		Op:     op,
+		Net:    connection.RemoteAddr().Network(),
+		Source: connection.LocalAddr(),
+		Addr:   connection.RemoteAddr(),
+		Err:    err,
+	}
+}
+
+// wrapTimeout wraps err with sentinel if err is a timeout
+func wrapTimeout(sentinel, err error) error {
+	if err != nil && isTimeout(err) {
+		return &timeoutError{sentinel: sentinel, err: err}
+	}
+	return err
+}
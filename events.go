@@ -0,0 +1,134 @@
+package eventedconnection
+
+import (
+	"net"
+	"time"
+)
+
+// DefaultEventsBufferSize is the default capacity of the Events channel
+const DefaultEventsBufferSize = 16
+
+// Event is a lifecycle event sent on Client.Events. The concrete types are
+// ConnectedEvent, DisconnectedEvent, ErrorEvent, ReadTimeoutEvent, IdleEvent,
+// WatchdogEvent, HealthEvent, ReconnectingEvent, ReconnectExhaustedEvent, CircuitBreakerEvent and
+// SlowConsumerEvent, each of which embeds the Origin of the client that sent it.
+type Event interface {
+	isEvent()
+}
+
+// ConnectedEvent is sent once a connection has been established
+type ConnectedEvent struct {
+	Origin
+	Addr net.Addr // remote address of the connection
+}
+
+// DisconnectedEvent is sent when a connection is closed. Err is the error that
+// caused the disconnect, or nil if the connection was closed with Close.
+type DisconnectedEvent struct {
+	Origin
+	Err error
+}
+
+// ErrorEvent is sent for every error passed to the OnErrorHook
+type ErrorEvent struct {
+	Origin
+	Err error
+}
+
+// ReadTimeoutEvent is sent when nothing was read within the read timeout. Unless an
+// OnReadTimeoutHook keeps the connection open it is followed by an ErrorEvent and a
+// DisconnectedEvent.
+type ReadTimeoutEvent struct {
+	Origin
+}
+
+// IdleEvent is sent when nothing was read within Config.IdleTimeout, before the
+// OnIdleHook is called and the Config.IdleAction is taken. Idle is how long nothing
+// has been read for.
+type IdleEvent struct {
+	Origin
+	Idle time.Duration
+}
+
+// WatchdogEvent is sent when nothing was read within Config.WatchdogTimeout, before the
+// OnWatchdogHook is called and the client reconnects. Silence is how long nothing has
+// been read for.
+type WatchdogEvent struct {
+	Origin
+	Silence time.Duration
+}
+
+// HealthEvent is sent when a health check changes the connection's health, see
+// Config.HealthCheckInterval. Err is the error of the failed check that made it
+// unhealthy.
+type HealthEvent struct {
+	Origin
+	Health Health
+	Err    error
+}
+
+// CircuitBreakerEvent is sent when the circuit breaker guarding dial attempts changes
+// state, see Config.CircuitBreakerThreshold
+type CircuitBreakerEvent struct {
+	Origin
+	From CircuitState
+	To   CircuitState
+}
+
+// SlowConsumerEvent is sent when a message is delivered on the Read channel while the
+// consumers are behind by more than Config.SlowConsumerThreshold
+type SlowConsumerEvent struct {
+	Origin
+	SlowConsumerInfo
+}
+
+// ReconnectingEvent is sent when Reconnect is called. Attempt counts the calls
+// since the last successful reconnect, starting at 1.
+type ReconnectingEvent struct {
+	Origin
+	Attempt int
+}
+
+// ReconnectExhaustedEvent is sent when automatic reconnecting gives up after
+// Config.MaxReconnectAttempts or Config.MaxReconnectDuration. Attempts is the number of
+// attempts made and Err the error that failed the last one.
+type ReconnectExhaustedEvent struct {
+	Origin
+	Attempts int
+	Err      error
+}
+
+func (ConnectedEvent) isEvent()          {}
+func (DisconnectedEvent) isEvent()       {}
+func (ErrorEvent) isEvent()              {}
+func (ReadTimeoutEvent) isEvent()        {}
+func (IdleEvent) isEvent()               {}
+func (WatchdogEvent) isEvent()           {}
+func (HealthEvent) isEvent()             {}
+func (ReconnectingEvent) isEvent()       {}
+func (ReconnectExhaustedEvent) isEvent() {}
+func (CircuitBreakerEvent) isEvent()     {}
+func (SlowConsumerEvent) isEvent()       {}
+
+// SubscribeEvents returns a new channel that receives every subsequent event, like
+// Events, and a function that cancels the subscription and closes the channel.
+// Subscriptions are independent of each other and of Events, so any number of
+// goroutines can follow the lifecycle without taking events from one another.
+// Each channel has a buffer of Config.EventsBufferSize and misses events when full.
+func (conn *Client) SubscribeEvents() (<-chan Event, func()) {
+	return conn.eventSubscribers.subscribe(cap(conn.Events))
+}
+
+// emit sends event on the Events channel and to every subscriber without
+// blocking. Events that don't fit are dropped and counted in Stats.EventsDropped.
+func (conn *Client) emit(event Event) {
+	select {
+	case conn.Events <- event:
+	default:
+		conn.stats.recordDroppedEvent()
+	}
+
+	for range conn.eventSubscribers.publish(event) {
+		conn.stats.recordDroppedEvent()
+	}
+}
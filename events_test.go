@@ -0,0 +1,289 @@
+package eventedconnection_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func nextEvent(t *testing.T, con *Client) Event {
+	t.Helper()
+	select {
+	case event := <-con.Events:
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for an event")
+	}
+	return nil
+}
+
+func TestClient_Events(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	connected, ok := nextEvent(t, con).(ConnectedEvent)
+	assertEqual(t, ok, true)
+	assertNotNil(t, connected.Addr)
+
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	reconnecting, ok := nextEvent(t, con).(ReconnectingEvent)
+	assertEqual(t, ok, true)
+	assertEqual(t, reconnecting.Attempt, 1)
+	disconnected, ok := nextEvent(t, con).(DisconnectedEvent)
+	assertEqual(t, ok, true)
+	assertEqual(t, disconnected.Err, nil)
+	_, ok = nextEvent(t, con).(ConnectedEvent)
+	assertEqual(t, ok, true)
+
+	con.Close()
+	_, ok = nextEvent(t, con).(DisconnectedEvent)
+	assertEqual(t, ok, true)
+}
+
+func TestClient_EventsOnReadError(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  50 * time.Millisecond,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	_, ok := nextEvent(t, con).(ConnectedEvent)
+	assertEqual(t, ok, true)
+
+	_, ok = nextEvent(t, con).(ReadTimeoutEvent)
+	assertEqual(t, ok, true)
+
+	errorEvent, ok := nextEvent(t, con).(ErrorEvent)
+	assertEqual(t, ok, true)
+	assertNotNil(t, errorEvent.Err)
+
+	disconnected, ok := nextEvent(t, con).(DisconnectedEvent)
+	assertEqual(t, ok, true)
+	assertEqual(t, disconnected.Err, errorEvent.Err)
+}
+
+func TestClient_EventsDropped(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:         l.Addr().String(),
+		ReadTimeout:      1 * time.Second,
+		WriteTimeout:     1 * time.Second,
+		EventsBufferSize: 1,
+		OnErrorHook:      func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+
+	assertEqual(t, con.GetStats().EventsDropped, uint64(1))
+	_, ok := nextEvent(t, con).(ConnectedEvent)
+	assertEqual(t, ok, true)
+}
+
+func TestClient_Err(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  50 * time.Millisecond,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.Err(), nil)
+
+	select {
+	case <-con.Disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the read timeout")
+	}
+	assertNotNil(t, con.Err())
+	if netErr, ok := con.Err().(interface{ Timeout() bool }); !ok || !netErr.Timeout() {
+		t.Errorf("expected a timeout error, got %v", con.Err())
+	}
+	if !errors.Is(con.Err(), ErrReadTimeout) || !errors.Is(con.Err(), os.ErrDeadlineExceeded) {
+		t.Errorf("expected the error to match ErrReadTimeout and the net error, got %v", con.Err())
+	}
+
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+	assertEqual(t, con.Err(), nil)
+}
+
+func TestClient_SubscribeEvents(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, cancelFirst := con.SubscribeEvents()
+	defer cancelFirst()
+	second, cancelSecond := con.SubscribeEvents()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// both subscribers see every event, across the reconnect
+	for _, events := range []<-chan Event{first, second} {
+		for _, expected := range []string{"connected", "reconnecting", "disconnected", "connected"} {
+			var event Event
+			select {
+			case event = <-events:
+			case <-time.After(2 * time.Second):
+				t.Fatal("Test timed out while waiting for an event")
+			}
+
+			var name string
+			switch event.(type) {
+			case ConnectedEvent:
+				name = "connected"
+			case ReconnectingEvent:
+				name = "reconnecting"
+			case DisconnectedEvent:
+				name = "disconnected"
+			}
+			assertEqual(t, name, expected)
+		}
+	}
+
+	cancelSecond()
+	con.Close()
+	if _, ok := <-second; ok {
+		t.Error("expected the cancelled subscription to be closed")
+	}
+	_, ok := (<-first).(DisconnectedEvent)
+	assertEqual(t, ok, true)
+}
+
+func TestClient_OnReadTimeoutHook(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	timeouts := make(chan struct{}, 10)
+	numErrors := 0
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  20 * time.Millisecond,
+		WriteTimeout: 1 * time.Second,
+		OnReadTimeoutHook: func() error {
+			timeouts <- struct{}{}
+			if len(timeouts) < 3 {
+				return nil
+			}
+			return errors.New("peer went quiet")
+		},
+		OnErrorHook: func(err error) error {
+			numErrors++
+			return err
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-con.Disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the connection to be closed")
+	}
+	assertEqual(t, len(timeouts), 3)
+	assertEqual(t, numErrors, 1)
+	assertEqual(t, con.Err().Error(), "peer went quiet")
+}
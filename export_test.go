@@ -0,0 +1,28 @@
+package eventedconnection
+
+import "time"
+
+// CircuitBreaker exposes the circuit breaker to the tests, which can't otherwise dial
+// concurrently since a Client serializes its dials
+type CircuitBreaker struct {
+	breaker *circuitBreaker
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) CircuitBreaker {
+	return CircuitBreaker{&circuitBreaker{threshold: threshold, cooldown: cooldown}}
+}
+
+func (b CircuitBreaker) Allow(now time.Time) bool {
+	allowed, _, _ := b.breaker.allow(now)
+	return allowed
+}
+
+func (b CircuitBreaker) Record(now time.Time, err error) {
+	b.breaker.record(now, err)
+}
+
+func (b CircuitBreaker) State() CircuitState {
+	b.breaker.mutex.Lock()
+	defer b.breaker.mutex.Unlock()
+	return b.breaker.state
+}
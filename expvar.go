@@ -0,0 +1,40 @@
+package eventedconnection
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// expvarMutex serializes publishExpvar, so clients created concurrently with the same
+// prefix can't both find the names free
+var expvarMutex sync.Mutex
+
+// publishExpvar publishes the client's state and statistics as expvar variables named
+// prefix + ".endpoint", ".id", ".labels", ".active", ".state" and ".stats". Since
+// expvar has no way to unpublish a variable, prefixes must be unique per process.
+func (conn *Client) publishExpvar(prefix string) error {
+	vars := map[string]expvar.Func{
+		prefix + ".endpoint": func() interface{} { return conn.GetEndpoint() },
+		prefix + ".id":       func() interface{} { return conn.GetID() },
+		prefix + ".labels":   func() interface{} { return conn.GetLabels() },
+		prefix + ".active":   func() interface{} { return conn.IsActive() },
+		prefix + ".state":    func() interface{} { return conn.State().String() },
+		prefix + ".stats":    func() interface{} { return conn.GetStats() },
+	}
+
+	expvarMutex.Lock()
+	defer expvarMutex.Unlock()
+
+	for name := range vars {
+		if expvar.Get(name) != nil {
+			return fmt.Errorf("expvar %q is already published", name)
+		}
+	}
+
+	for name, v := range vars {
+		expvar.Publish(name, v)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package eventedconnection_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_Expvar(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		ExpvarPrefix: "eventedconnection.test",
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, expvar.Get("eventedconnection.test.active").String(), "false")
+	assertEqual(t, expvar.Get("eventedconnection.test.state").String(), `"idle"`)
+	assertEqual(t, expvar.Get("eventedconnection.test.endpoint").String(), `"`+l.Addr().String()+`"`)
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	payload := []byte("Testing expvar")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, expvar.Get("eventedconnection.test.active").String(), "true")
+	assertEqual(t, expvar.Get("eventedconnection.test.state").String(), `"connected"`)
+
+	var stats Stats
+	if err = json.Unmarshal([]byte(expvar.Get("eventedconnection.test.stats").String()), &stats); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, stats.BytesWritten, uint64(len(payload)))
+
+	// the prefix can only be used once
+	_, err = NewClient(&conf)
+	assertNotNil(t, err)
+}
+
+func TestClient_ExpvarConcurrent(t *testing.T) {
+	conf := Config{
+		Endpoint:     "localhost:5555",
+		ExpvarPrefix: "eventedconnection.concurrent",
+	}
+
+	// exactly one of the clients gets the prefix; the others fail instead of panicking
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	created := 0
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if con, err := NewClient(&conf); err == nil {
+				defer con.Shutdown()
+				mutex.Lock()
+				created++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assertEqual(t, created, 1)
+}
@@ -0,0 +1,130 @@
+package eventedconnection
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxFrameSize bounds the length of a frame read by a frameReader, so a corrupt length
+// prefix can't make it buffer without limit
+const maxFrameSize = 16 << 20
+
+// frameHeaderSize is the size of the big-endian uint32 length prefix of a frame
+const frameHeaderSize = 4
+
+// errSkipFrame is returned by a framedLayer's decode to drop a frame without error
+var errSkipFrame = errors.New("skip frame")
+
+// appendFrame appends payload to dst prefixed with its length
+func appendFrame(dst, payload []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	return append(dst, payload...)
+}
+
+// readFrame reads exactly one length-prefixed frame from r during a handshake, so
+// nothing the endpoint sends after it is consumed
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size == 0 || size > maxFrameSize {
+		return nil, fmt.Errorf("invalid handshake frame of %d bytes", size)
+	}
+
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// frameReader reassembles the length-prefixed frames written with appendFrame from
+// the chunks read from the connection
+type frameReader struct {
+	mutex  sync.Mutex
+	buffer []byte
+}
+
+// read buffers data and calls handle for the payload of every complete frame
+func (r *frameReader) read(data []byte, handle func(payload []byte) error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.buffer = append(r.buffer, data...)
+	for len(r.buffer) >= frameHeaderSize {
+		size := binary.BigEndian.Uint32(r.buffer)
+		if size > maxFrameSize {
+			r.buffer = nil
+			return fmt.Errorf("frame of %d bytes exceeds the maximum of %d", size, maxFrameSize)
+		}
+		end := frameHeaderSize + int(size)
+		if len(r.buffer) < end {
+			break
+		}
+
+		payload := r.buffer[frameHeaderSize:end:end]
+		r.buffer = r.buffer[end:]
+		if err := handle(payload); err != nil {
+			return err
+		}
+	}
+
+	if len(r.buffer) == 0 {
+		r.buffer = nil // let the backing array go
+	}
+	return nil
+}
+
+// reset drops a partially read frame, e.g. of a closed connection
+func (r *frameReader) reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.buffer = nil
+}
+
+// framedLayer transforms every written message into a frame with encode and every frame
+// read back with decode, e.g. to encrypt messages. Its middleware sits closest to the
+// connection, beneath Config.ReadMiddleware and Config.WriteMiddleware.
+type framedLayer struct {
+	frames frameReader
+	encode func(data []byte) ([]byte, error)
+	decode func(payload []byte) ([]byte, error)
+}
+
+func (l *framedLayer) readMiddleware(next Handler) Handler {
+	return func(data []byte) error {
+		return l.frames.read(data, func(payload []byte) error {
+			message, err := l.decode(payload)
+			if err == errSkipFrame {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			return next(message)
+		})
+	}
+}
+
+func (l *framedLayer) writeMiddleware(next Handler) Handler {
+	return func(data []byte) error {
+		payload, err := l.encode(data)
+		if err != nil {
+			return err
+		}
+		return next(appendFrame(nil, payload))
+	}
+}
+
+// addLayer installs l beneath the configured middleware
+func (conn *Client) addLayer(l *framedLayer) {
+	conn.layers = append(conn.layers, l)
+	conn.readMiddleware = append([]Middleware{l.readMiddleware}, conn.readMiddleware...)
+	conn.writeMiddleware = append(conn.writeMiddleware, l.writeMiddleware)
+}
@@ -0,0 +1,190 @@
+package eventedconnection
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultHealthCheckTimeout is the default time to wait for the response to a health check probe
+const DefaultHealthCheckTimeout = 5 * time.Second
+
+// Health is the outcome of the active health checks of the current connection, see
+// Config.HealthCheckInterval
+type Health int
+
+const (
+	// HealthUnknown is reported until the first check of the current connection
+	// completes, and when health checks aren't enabled
+	HealthUnknown Health = iota
+	// HealthHealthy means the last check was answered in time
+	HealthHealthy
+	// HealthUnhealthy means the last check failed
+	HealthUnhealthy
+)
+
+func (h Health) String() string {
+	switch h {
+	case HealthUnknown:
+		return "unknown"
+	case HealthHealthy:
+		return "healthy"
+	case HealthUnhealthy:
+		return "unhealthy"
+	}
+	return fmt.Sprintf("Health(%d)", int(h))
+}
+
+// errHealthCheckStopped is returned by probe when the connection goes away before the
+// check completes
+var errHealthCheckStopped = errors.New("health check stopped")
+
+// healthChecker holds the configuration and state of the active health checks
+type healthChecker struct {
+	interval  time.Duration
+	timeout   time.Duration
+	payload   []byte
+	matcher   HealthCheckMatcher
+	reconnect bool
+
+	mutex    sync.Mutex
+	health   Health
+	response chan struct{} // closed when the probe is answered; nil unless a probe is outstanding
+}
+
+func newHealthChecker(conf *Config) *healthChecker {
+	h := &healthChecker{
+		interval:  conf.HealthCheckInterval,
+		timeout:   conf.HealthCheckTimeout,
+		payload:   slices.Clone(conf.HealthCheckPayload),
+		matcher:   conf.HealthCheckMatcher,
+		reconnect: conf.HealthCheckReconnect,
+	}
+	if h.timeout == 0 {
+		h.timeout = DefaultHealthCheckTimeout
+	}
+	return h
+}
+
+// Health returns the outcome of the health checks of the current connection, see
+// Config.HealthCheckInterval. It is HealthUnknown if health checks aren't enabled.
+func (conn *Client) Health() Health {
+	if conn.healthCheck == nil {
+		return HealthUnknown
+	}
+
+	conn.healthCheck.mutex.Lock()
+	defer conn.healthCheck.mutex.Unlock()
+	return conn.healthCheck.health
+}
+
+// resetHealth forgets the health of the previous connection
+func (conn *Client) resetHealth() {
+	conn.healthCheck.mutex.Lock()
+	conn.healthCheck.health = HealthUnknown
+	conn.healthCheck.mutex.Unlock()
+}
+
+// checkHealth probes the connection every health check interval until stop is closed,
+// the client is shut down or a failed check replaces the connection
+func (conn *Client) checkHealth(stop <-chan struct{}) {
+	timer := conn.clock.NewTimer(conn.healthCheck.interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+		case <-stop:
+			return
+		case <-conn.done:
+			return
+		}
+
+		err := conn.probe(stop)
+		if err == errHealthCheckStopped {
+			return
+		}
+		conn.setHealth(err)
+		if err != nil && conn.healthCheck.reconnect {
+			conn.logger.Warn("health check failed, reconnecting", slog.Any("error", err))
+			conn.replaceConnection()
+			return // Reconnect starts new health checks for the new connection
+		}
+		timer.Reset(conn.healthCheck.interval)
+	}
+}
+
+// probe writes the health check payload and waits for the HealthCheckMatcher to report
+// the response
+func (conn *Client) probe(stop <-chan struct{}) error {
+	h := conn.healthCheck
+	response := make(chan struct{})
+	h.mutex.Lock()
+	h.response = response
+	h.mutex.Unlock()
+	defer func() {
+		h.mutex.Lock()
+		h.response = nil
+		h.mutex.Unlock()
+	}()
+
+	if err := conn.writeControl(h.payload, h.timeout); err != nil {
+		return err
+	}
+
+	timer := conn.clock.NewTimer(h.timeout)
+	defer timer.Stop()
+	select {
+	case <-response:
+		return nil
+	case <-timer.C():
+		return ErrHealthCheckTimeout
+	case <-stop:
+		return errHealthCheckStopped
+	case <-conn.done:
+		return errHealthCheckStopped
+	}
+}
+
+// healthResponse reports whether message answers the outstanding health check probe,
+// in which case it is consumed rather than delivered
+func (conn *Client) healthResponse(message []byte) bool {
+	h := conn.healthCheck
+	if h == nil {
+		return false
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.response == nil || !h.matcher(message) {
+		return false
+	}
+	close(h.response)
+	h.response = nil
+	return true
+}
+
+// setHealth records the outcome of a health check, sending a HealthEvent if it changes
+// the connection's health
+func (conn *Client) setHealth(err error) {
+	health := HealthHealthy
+	if err != nil {
+		health = HealthUnhealthy
+		conn.handleError(fmt.Errorf("health check: %w", err))
+	}
+	conn.stats.recordHealthCheck(err != nil)
+
+	h := conn.healthCheck
+	h.mutex.Lock()
+	changed := h.health != health
+	h.health = health
+	h.mutex.Unlock()
+
+	if changed {
+		conn.logger.Info("health changed", slog.String("health", health.String()))
+		conn.emit(HealthEvent{Origin: conn.origin(), Health: health, Err: err})
+	}
+}
@@ -0,0 +1,146 @@
+package eventedconnection_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+// waitForHealth waits for a HealthEvent reporting health
+func waitForHealth(t *testing.T, events <-chan Event, health Health) HealthEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if e, ok := event.(HealthEvent); ok && e.Health == health {
+				return e
+			}
+		case <-timeout:
+			t.Fatalf("Test timed out while waiting for the connection to become %v", health)
+		}
+	}
+}
+
+func TestClient_HealthCheck(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	ping := []byte("PING")
+	conf := Config{
+		Endpoint:            l.Addr().String(),
+		HealthCheckInterval: 20 * time.Millisecond,
+		HealthCheckPayload:  ping,
+		HealthCheckMatcher:  func(message []byte) bool { return bytes.Equal(message, ping) },
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	events, cancel := con.SubscribeEvents()
+	defer cancel()
+
+	assertEqual(t, con.Health(), HealthUnknown)
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitForHealth(t, events, HealthHealthy)
+	assertEqual(t, con.Health(), HealthHealthy)
+
+	// responses are consumed while other messages are still delivered
+	if err = con.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "hello")
+
+	if stats := con.GetStats(); stats.HealthChecks == 0 || stats.HealthCheckFailures != 0 {
+		t.Fatalf("Expected only passed health checks, got %d checks and %d failures", stats.HealthChecks, stats.HealthCheckFailures)
+	}
+}
+
+func TestClient_HealthCheckTimeout(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.BlackHoleServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:            l.Addr().String(),
+		HealthCheckInterval: 20 * time.Millisecond,
+		HealthCheckTimeout:  30 * time.Millisecond,
+		HealthCheckPayload:  []byte("PING"),
+		HealthCheckMatcher:  func([]byte) bool { return true },
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	events, cancel := con.SubscribeEvents()
+	defer cancel()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	event := waitForHealth(t, events, HealthUnhealthy)
+	if !errors.Is(event.Err, ErrHealthCheckTimeout) {
+		t.Fatalf("Expected ErrHealthCheckTimeout, got %v", event.Err)
+	}
+	assertEqual(t, con.Health(), HealthUnhealthy)
+	if stats := con.GetStats(); stats.HealthCheckFailures == 0 {
+		t.Fatal("Expected the failed health check to be counted")
+	}
+	// without HealthCheckReconnect the connection is kept
+	assertEqual(t, con.IsActive(), true)
+	assertEqual(t, con.GetStats().Reconnects, uint64(0))
+}
+
+func TestClient_HealthCheckReconnect(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.BlackHoleServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:             l.Addr().String(),
+		HealthCheckInterval:  20 * time.Millisecond,
+		HealthCheckTimeout:   30 * time.Millisecond,
+		HealthCheckPayload:   []byte("PING"),
+		HealthCheckMatcher:   func([]byte) bool { return true },
+		HealthCheckReconnect: true,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for con.GetStats().Reconnects == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the failed health check to reconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
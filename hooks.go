@@ -0,0 +1,368 @@
+package eventedconnection
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"time"
+)
+
+// HookContext describes the client a context hook is called for, so one hook
+// function can serve many clients without a closure per client.
+type HookContext struct {
+	Client     *Client
+	Endpoint   string            // configured endpoint (or SRV name)
+	ID         string            // Config.ID
+	Labels     map[string]string // Config.Labels; must not be modified
+	RemoteAddr net.Addr          // address of the current connection; nil when disconnected
+	Cause      error             // why the connection is being closed; only set for the BeforeDisconnectContextHook
+}
+
+// Context variants of the hooks in Config. Each is called with the HookContext of
+// the client followed by the arguments of the plain hook, and behaves the same way.
+type (
+	AfterReadContextHook        func(ctx HookContext, data []byte) ([]byte, error)
+	BeforeWriteContextHook      func(ctx HookContext, data []byte) ([]byte, error)
+	AfterConnectContextHook     func(ctx HookContext) error
+	BeforeDisconnectContextHook func(ctx HookContext) error
+	OnErrorContextHook          func(ctx HookContext, err error) error
+	OnReadTimeoutContextHook    func(ctx HookContext) error
+)
+
+// hookContext returns the HookContext for the client's current connection. It is
+// called from within hooks that may run while conn.mutex is held, so it must not
+// take that lock; rawConnection is not used for that reason.
+func (conn *Client) hookContext() HookContext {
+	ctx := HookContext{
+		Client:   conn,
+		Endpoint: conn.GetEndpoint(),
+		ID:       conn.id,
+		Labels:   conn.labels,
+	}
+	if len(ctx.Endpoint) == 0 {
+		ctx.Endpoint = conn.srvName
+	}
+	return ctx
+}
+
+// setContextHooks adapts the context hooks in conf to the plain hooks used
+// internally. A hook and its context variant cannot both be set.
+func (conn *Client) setContextHooks(conf *Config) error {
+	if conf.AfterReadContextHook != nil {
+		if conf.AfterReadHook != nil {
+			return errors.New("only one of AfterReadHook and AfterReadContextHook can be set")
+		}
+		conn.afterReadHook = func(data []byte) ([]byte, error) {
+			return conf.AfterReadContextHook(conn.hookContextWithAddr(), data)
+		}
+	}
+
+	if conf.BeforeWriteContextHook != nil {
+		if conf.BeforeWriteHook != nil {
+			return errors.New("only one of BeforeWriteHook and BeforeWriteContextHook can be set")
+		}
+		conn.beforeWriteHook = func(data []byte) ([]byte, error) {
+			return conf.BeforeWriteContextHook(conn.hookContextWithAddr(), data)
+		}
+	}
+
+	if conf.AfterConnectContextHook != nil {
+		if conf.AfterConnectHook != nil {
+			return errors.New("only one of AfterConnectHook and AfterConnectContextHook can be set")
+		}
+		conn.afterConnectHook = func() error {
+			return conf.AfterConnectContextHook(conn.hookContextWithAddr())
+		}
+	}
+
+	if conf.BeforeDisconnectContextHook != nil {
+		if conf.BeforeDisconnectHook != nil {
+			return errors.New("only one of BeforeDisconnectHook and BeforeDisconnectContextHook can be set")
+		}
+		conn.beforeDisconnectHook = func() error {
+			ctx := conn.hookContextWithAddr()
+			ctx.Cause = conn.Err()
+			return conf.BeforeDisconnectContextHook(ctx)
+		}
+	}
+
+	if conf.OnErrorContextHook != nil {
+		if conf.OnErrorHook != nil {
+			return errors.New("only one of OnErrorHook and OnErrorContextHook can be set")
+		}
+		conn.onErrorHook = func(err error) error {
+			// errors are reported from within Close too, so don't take the lock
+			return conf.OnErrorContextHook(conn.hookContext(), err)
+		}
+	}
+
+	if conf.OnReadTimeoutContextHook != nil {
+		if conf.OnReadTimeoutHook != nil {
+			return errors.New("only one of OnReadTimeoutHook and OnReadTimeoutContextHook can be set")
+		}
+		conn.onReadTimeoutHook = func() error {
+			return conf.OnReadTimeoutContextHook(conn.hookContextWithAddr())
+		}
+	}
+
+	return nil
+}
+
+// hookContextWithAddr returns the HookContext including the remote address.
+// conn.mutex must not be held.
+func (conn *Client) hookContextWithAddr() HookContext {
+	ctx := conn.hookContext()
+	if connection := conn.rawConnection(); connection != nil {
+		ctx.RemoteAddr = connection.RemoteAddr()
+	}
+	return ctx
+}
+
+// HookPanicError is the error reported in place of a hook's result when the hook
+// panics. It is handled like an error returned by the hook (e.g. a panicking
+// AfterReadHook closes the connection) and passed to the OnErrorHook.
+type HookPanicError struct {
+	Hook  string // name of the Config field, e.g. "AfterReadHook"
+	Value any    // value passed to panic
+	Stack []byte // stack trace of the panicking goroutine
+}
+
+func (e *HookPanicError) Error() string {
+	return fmt.Sprintf("%s panicked: %v", e.Hook, e.Value)
+}
+
+// recoverHook is deferred by the hook wrappers and turns a panic into a HookPanicError
+func recoverHook(hook string, err *error) {
+	if r := recover(); r != nil {
+		*err = &HookPanicError{Hook: hook, Value: r, Stack: debug.Stack()}
+	}
+}
+
+// callHook calls a hook that has no error result through fn, reporting a panic in it
+// to the OnErrorHook as a HookPanicError
+func (conn *Client) callHook(hook string, fn func()) {
+	var err error
+	defer func() {
+		if err != nil {
+			conn.handleError(err)
+		}
+	}()
+	defer recoverHook(hook, &err)
+	fn()
+}
+
+// recoverHooks wraps the user hooks so that a panic in one of them is reported as
+// an error instead of crashing the process from one of the client's goroutines.
+func (conn *Client) recoverHooks() {
+	if hook := conn.afterReadHook; hook != nil {
+		conn.afterReadHook = func(data []byte) (processed []byte, err error) {
+			defer recoverHook("AfterReadHook", &err)
+			return hook(data)
+		}
+	}
+
+	if hook := conn.beforeWriteHook; hook != nil {
+		conn.beforeWriteHook = func(data []byte) (processed []byte, err error) {
+			defer recoverHook("BeforeWriteHook", &err)
+			return hook(data)
+		}
+	}
+
+	if hook := conn.beforeConnectHook; hook != nil {
+		conn.beforeConnectHook = func(params *DialParams) (err error) {
+			defer recoverHook("BeforeConnectHook", &err)
+			return hook(params)
+		}
+	}
+
+	if hook := conn.afterConnectHook; hook != nil {
+		conn.afterConnectHook = func() (err error) {
+			defer recoverHook("AfterConnectHook", &err)
+			return hook()
+		}
+	}
+
+	if hook := conn.beforeDisconnectHook; hook != nil {
+		conn.beforeDisconnectHook = func() (err error) {
+			defer recoverHook("BeforeDisconnectHook", &err)
+			return hook()
+		}
+	}
+
+	if hook := conn.onReadTimeoutHook; hook != nil {
+		conn.onReadTimeoutHook = func() (err error) {
+			defer recoverHook("OnReadTimeoutHook", &err)
+			return hook()
+		}
+	}
+
+	if hook := conn.onIdleHook; hook != nil {
+		conn.onIdleHook = func(idle time.Duration) (err error) {
+			defer recoverHook("OnIdleHook", &err)
+			return hook(idle)
+		}
+	}
+
+	if hook := conn.keepaliveHook; hook != nil {
+		conn.keepaliveHook = func() (payload []byte, err error) {
+			defer recoverHook("KeepaliveHook", &err)
+			return hook()
+		}
+	}
+
+	if hook := conn.onMessageHook; hook != nil {
+		conn.onMessageHook = func(data []byte) (err error) {
+			defer recoverHook("OnMessageHook", &err)
+			return hook(data)
+		}
+	}
+
+	if hook := conn.onChecksumErrorHook; hook != nil {
+		conn.onChecksumErrorHook = func(frame []byte) (err error) {
+			defer recoverHook("OnChecksumErrorHook", &err)
+			return hook(frame)
+		}
+	}
+
+	if hook := conn.startTLSHook; hook != nil {
+		conn.startTLSHook = func(rw io.ReadWriter) (err error) {
+			defer recoverHook("StartTLSHook", &err)
+			return hook(rw)
+		}
+	}
+
+	if hook := conn.certExpiryHook; hook != nil {
+		conn.certExpiryHook = func(cert *x509.Certificate, expiresIn time.Duration) (err error) {
+			defer recoverHook("CertExpiryHook", &err)
+			return hook(cert, expiresIn)
+		}
+	}
+
+	if hook := conn.onStateChangeHook; hook != nil {
+		conn.onStateChangeHook = func(from, to State, cause error) {
+			conn.callHook("OnStateChangeHook", func() { hook(from, to, cause) })
+		}
+	}
+
+	if hook := conn.onReconnectAttemptHook; hook != nil {
+		conn.onReconnectAttemptHook = func(attempt ReconnectAttempt) (err error) {
+			defer recoverHook("OnReconnectAttemptHook", &err)
+			return hook(attempt)
+		}
+	}
+
+	if hook := conn.onRatesHook; hook != nil {
+		conn.onRatesHook = func(rates Rates) {
+			conn.callHook("OnRatesHook", func() { hook(rates) })
+		}
+	}
+
+	if hook := conn.onWatchdogHook; hook != nil {
+		conn.onWatchdogHook = func(silence time.Duration) {
+			conn.callHook("OnWatchdogHook", func() { hook(silence) })
+		}
+	}
+
+	if hook := conn.onReconnectExhaustedHook; hook != nil {
+		conn.onReconnectExhaustedHook = func(attempts int, lastErr error) {
+			conn.callHook("OnReconnectExhaustedHook", func() { hook(attempts, lastErr) })
+		}
+	}
+
+	if hook := conn.onSlowConsumerHook; hook != nil {
+		conn.onSlowConsumerHook = func(info SlowConsumerInfo) {
+			conn.callHook("OnSlowConsumerHook", func() { hook(info) })
+		}
+	}
+
+	if hook := conn.onWriteExpiredHook; hook != nil {
+		conn.onWriteExpiredHook = func(data []byte) {
+			conn.callHook("OnWriteExpiredHook", func() { hook(data) })
+		}
+	}
+
+	if hook := conn.ackExtractor; hook != nil {
+		conn.ackExtractor = func(data []byte) (seq uint64, ok bool) {
+			conn.callHook("AckExtractor", func() { seq, ok = hook(data) })
+			return
+		}
+	}
+
+	if conn.healthCheck != nil {
+		hook := conn.healthCheck.matcher
+		conn.healthCheck.matcher = func(message []byte) (ok bool) {
+			conn.callHook("HealthCheckMatcher", func() { ok = hook(message) })
+			return
+		}
+	}
+
+	if hook := conn.sequenceHook; hook != nil {
+		conn.sequenceHook = func(seq uint64, data []byte) (payload []byte, err error) {
+			defer recoverHook("SequenceHook", &err)
+			return hook(seq, data)
+		}
+	}
+
+	if hook := conn.onDeliveryFailedHook; hook != nil {
+		conn.onDeliveryFailedHook = func(seq uint64, data []byte) {
+			conn.callHook("OnDeliveryFailedHook", func() { hook(seq, data) })
+		}
+	}
+
+	if hook := conn.hexDumpHook; hook != nil {
+		conn.hexDumpHook = func(direction Direction, dump string) {
+			conn.callHook("HexDumpHook", func() { hook(direction, dump) })
+		}
+	}
+
+	if hook := conn.classifyError; hook != nil {
+		conn.classifyError = func(err error) (class ErrorClass) {
+			panicked := true
+			conn.callHook("ErrorClassifier", func() {
+				class = hook(err)
+				panicked = false
+			})
+			if panicked {
+				class = ClassifyError(err)
+			}
+			return class
+		}
+	}
+
+	for i, middleware := range conn.readMiddleware {
+		conn.readMiddleware[i] = recoverMiddleware("ReadMiddleware", middleware)
+	}
+	for i, middleware := range conn.writeMiddleware {
+		conn.writeMiddleware[i] = recoverMiddleware("WriteMiddleware", middleware)
+	}
+
+	// a panic in the OnErrorHook can't be reported to itself, so it is only logged
+	if hook := conn.onErrorHook; hook != nil {
+		conn.onErrorHook = func(err error) error {
+			var panicErr error
+			defer func() {
+				if panicErr != nil {
+					conn.logger.Error("hook panicked", slog.Any("error", panicErr))
+				}
+			}()
+			defer recoverHook("OnErrorHook", &panicErr)
+			return hook(err)
+		}
+	}
+}
+
+// recoverMiddleware wraps the Handlers built by middleware so that a panic in one of
+// them is returned as a HookPanicError
+func recoverMiddleware(hook string, middleware Middleware) Middleware {
+	return func(next Handler) Handler {
+		handler := middleware(next)
+		return func(data []byte) (err error) {
+			defer recoverHook(hook, &err)
+			return handler(data)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package eventedconnection_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_ContextHooks(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	var mutex sync.Mutex
+	connected := map[string]HookContext{}
+	afterConnect := func(ctx HookContext) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		connected[ctx.ID] = ctx
+		return nil
+	}
+	afterRead := func(ctx HookContext, data []byte) ([]byte, error) {
+		return append([]byte(ctx.ID+": "), data...), nil
+	}
+
+	clients := map[string]*Client{}
+	for _, id := range []string{"first", "second"} {
+		conf := Config{
+			Endpoint:                l.Addr().String(),
+			ID:                      id,
+			ReadTimeout:             1 * time.Second,
+			WriteTimeout:            1 * time.Second,
+			AfterConnectContextHook: afterConnect,
+			AfterReadContextHook:    afterRead,
+		}
+
+		con, err := NewClient(&conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = con.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		defer con.Close()
+		clients[id] = con
+	}
+
+	for id, con := range clients {
+		assertEqual(t, connected[id].Client, con)
+		assertEqual(t, connected[id].Endpoint, l.Addr().String())
+		assertNotNil(t, connected[id].RemoteAddr)
+
+		payload := []byte("hello")
+		if err = con.Write(&payload); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case data := <-con.Read:
+			assertEqual(t, string(*data), id+": hello")
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting to read from connection")
+		}
+	}
+}
+
+func TestNewClient_ConflictingContextHooks(t *testing.T) {
+	conf := Config{
+		Endpoint:           "localhost:5555",
+		OnErrorHook:        func(err error) error { return err },
+		OnErrorContextHook: func(ctx HookContext, err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	assertNotNil(t, err)
+	if con != nil {
+		t.Error("Expected con to be nil")
+	}
+}
+
+func TestClient_HookPanics(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	errs := make(chan error, 10)
+	conf := Config{
+		Endpoint:         l.Addr().String(),
+		ReadTimeout:      1 * time.Second,
+		WriteTimeout:     1 * time.Second,
+		AfterConnectHook: func() error { panic("connect hook") },
+		AfterReadHook:    func(data []byte) ([]byte, error) { panic("read hook") },
+		OnErrorHook: func(err error) error {
+			errs <- err
+			return err
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.IsActive(), true)
+
+	var panicErr *HookPanicError
+	if !errors.As(<-errs, &panicErr) {
+		t.Fatal("Expected a HookPanicError")
+	}
+	assertEqual(t, panicErr.Hook, "AfterConnectHook")
+	assertEqual(t, panicErr.Value, "connect hook")
+
+	payload := []byte("hello")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-con.Disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the connection to be closed")
+	}
+	if !errors.As(<-errs, &panicErr) {
+		t.Fatal("Expected a HookPanicError")
+	}
+	assertEqual(t, panicErr.Hook, "AfterReadHook")
+	assertEqual(t, errors.As(con.Err(), &panicErr), true)
+}
+
+func TestClient_KeepReadingOnHookError(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	errMalformed := errors.New("malformed message")
+	errs := make(chan error, 1)
+	conf := Config{
+		Endpoint: l.Addr().String(),
+		AfterReadHook: func(data []byte) ([]byte, error) {
+			if string(data) == "bad" {
+				return nil, errMalformed
+			}
+			return data, nil
+		},
+		OnErrorHook: func(err error) error {
+			errs <- err
+			return err
+		},
+		KeepReadingOnHookError: true,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	bad := []byte("bad")
+	if err = con.Write(&bad); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-errs:
+		assertEqual(t, err, errMalformed)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the hook error")
+	}
+
+	good := []byte("good")
+	if err = con.Write(&good); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), "good")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+	assertEqual(t, con.IsActive(), true)
+}
+
+func TestClient_ReentrantHooks(t *testing.T) {
+	// the server sends a greeting and records everything it reads until the client hangs up
+	done := make(chan bool)
+	l, recorder, err := testutils.RecordingServer(done, testutils.RecordingOptions{Greeting: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	var con *Client
+	reconnected := false
+	conf := Config{
+		Endpoint: l.Addr().String(),
+		AfterConnectHook: func() error {
+			if reconnected {
+				return nil
+			}
+			reconnected = true
+			return con.Reconnect()
+		},
+		BeforeDisconnectHook: func() error {
+			con.Close() // already closing, so it returns right away
+			return con.WriteString("goodbye")
+		},
+	}
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.GetStats().Reconnects, uint64(1))
+
+	// the consumer of the Read channel closes the connection
+	select {
+	case <-con.Read:
+		con.Close()
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+
+	connections, err := recorder.WaitForClosed(2, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range connections {
+		assertEqual(t, string(c.Received), "goodbye")
+	}
+}
+
+func TestClient_CloseDuringCloseInProgress(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	conf := Config{
+		Endpoint: l.Addr().String(),
+		BeforeDisconnectHook: func() error {
+			close(entered)
+			<-release
+			return nil
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	go con.Close()
+	<-entered
+
+	returned := make(chan struct{})
+	go func() {
+		con.Close()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Close to return while another close is in progress")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		con.WaitClosed()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Expected WaitClosed to wait for the close in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for WaitClosed to return")
+	}
+	assertEqual(t, con.IsActive(), false)
+}
+
+func TestClient_WriteFromBeforeDisconnectHookAfterWriteError(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.StallingServer(done, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	var con *Client
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		WriteTimeout: 50 * time.Millisecond,
+		BeforeDisconnectHook: func() error {
+			return con.WriteString("goodbye") // fails, but mustn't wait for the failed write
+		},
+	}
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	written := make(chan error, 1)
+	go func() { written <- con.WriteBytes(make([]byte, 64<<20)) }()
+	select {
+	case err := <-written:
+		if !errors.Is(err, ErrWriteTimeout) {
+			t.Fatalf("Expected ErrWriteTimeout, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Test timed out while waiting for the failed write")
+	}
+	assertEqual(t, con.IsActive(), false)
+}
+
+// panicAuthenticator panics as soon as the exchange starts
+type panicAuthenticator struct{ PlainAuthenticator }
+
+func (*panicAuthenticator) Start() ([]byte, error) { panic("authenticator") }
+
+func TestClient_CallbackPanicsFailConnect(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	tests := []struct {
+		hook string
+		conf Config
+	}{
+		{"Transport", Config{
+			Transport: TransportFunc(func(context.Context) (net.Conn, error) { panic("transport") }),
+		}},
+		{"Authenticator", Config{
+			Endpoint:      l.Addr().String(),
+			Authenticator: &panicAuthenticator{},
+		}},
+	}
+	for _, test := range tests {
+		con, err := NewClient(&test.conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var panicErr *HookPanicError
+		if err = con.Connect(); !errors.As(err, &panicErr) {
+			t.Fatalf("Expected a HookPanicError from %s, got %v", test.hook, err)
+		}
+		assertEqual(t, panicErr.Hook, test.hook)
+		con.Shutdown()
+	}
+}
+
+// panicWriter panics on every write
+type panicWriter struct{}
+
+func (panicWriter) Write([]byte) (int, error) { panic("writer") }
+
+// panicOn returns middleware that panics when it is passed word
+func panicOn(word string) Middleware {
+	return func(next Handler) Handler {
+		return func(data []byte) error {
+			if string(data) == word {
+				panic(word)
+			}
+			return next(data)
+		}
+	}
+}
+
+func TestClient_CallbackPanics(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	errs := make(chan error, 10)
+	conf := Config{
+		Endpoint:        l.Addr().String(),
+		ReadTee:         panicWriter{},
+		ReadMiddleware:  []Middleware{panicOn("read")},
+		WriteMiddleware: []Middleware{panicOn("write")},
+		AutoReconnect:   true,
+		ReconnectDelay:  10 * time.Millisecond,
+		ErrorClassifier: func(error) ErrorClass { panic("classifier") },
+		OnErrorHook: func(err error) error {
+			errs <- err
+			return err
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForPanic := func(hook string) {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for {
+			select {
+			case err := <-errs:
+				var panicErr *HookPanicError
+				if errors.As(err, &panicErr) && panicErr.Hook == hook {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("Test timed out while waiting for the %s to panic", hook)
+			}
+		}
+	}
+
+	var panicErr *HookPanicError
+	if err = con.WriteString("write"); !errors.As(err, &panicErr) {
+		t.Fatalf("Expected a HookPanicError, got %v", err)
+	}
+	assertEqual(t, panicErr.Hook, "WriteMiddleware")
+
+	// the tee's panic is only reported, the message is still delivered
+	if err = con.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+	waitForPanic("ReadTee")
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "hello")
+
+	// the read middleware's panic closes the connection, and the classifier's panic
+	// leaves the reconnect to the default classification, for which panics are fatal
+	if err = con.WriteString("read"); err != nil {
+		t.Fatal(err)
+	}
+	waitForPanic("ReadMiddleware")
+	waitForPanic("ErrorClassifier")
+	deadline := time.Now().Add(2 * time.Second)
+	for con.State() != StateClosed {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the connection to be closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
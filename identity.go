@@ -0,0 +1,58 @@
+package eventedconnection
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Origin identifies the client an event came from. It is embedded in every Event.
+type Origin struct {
+	ID     string
+	Labels map[string]string
+}
+
+// origin returns the Origin of events sent by this client
+func (conn *Client) origin() Origin {
+	return Origin{ID: conn.id, Labels: conn.labels}
+}
+
+// GetID returns the value of conn.id
+func (conn *Client) GetID() string {
+	return conn.id
+}
+
+// GetLabels returns a copy of the client's labels
+func (conn *Client) GetLabels() map[string]string {
+	return maps.Clone(conn.labels)
+}
+
+// identityLogAttrs returns the log attributes identifying a client configured with conf
+func identityLogAttrs(conf *Config) []any {
+	var attrs []any
+	if len(conf.ID) > 0 {
+		attrs = append(attrs, slog.String("id", conf.ID))
+	}
+	if len(conf.Labels) > 0 {
+		labels := make([]any, 0, len(conf.Labels))
+		for _, k := range slices.Sorted(maps.Keys(conf.Labels)) {
+			labels = append(labels, slog.String(k, conf.Labels[k]))
+		}
+		attrs = append(attrs, slog.Group("labels", labels...))
+	}
+	return attrs
+}
+
+// identityAttributes returns the span attributes identifying the client
+func (conn *Client) identityAttributes() []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if len(conn.id) > 0 {
+		attrs = append(attrs, attribute.String("eventedconnection.id", conn.id))
+	}
+	for _, k := range slices.Sorted(maps.Keys(conn.labels)) {
+		attrs = append(attrs, attribute.String("eventedconnection.label."+k, conn.labels[k]))
+	}
+	return attrs
+}
@@ -0,0 +1,64 @@
+package eventedconnection_test
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_IDAndLabels(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	output := &syncBuffer{}
+	conf := NewConfig()
+	conf.Endpoint = l.Addr().String()
+	conf.ReadTimeout = 1 * time.Second
+	conf.ID = "primary"
+	conf.Labels = map[string]string{"region": "eu"}
+	conf.Logger = slog.New(slog.NewJSONHandler(output, nil))
+
+	con, err := NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Labels["region"] = "us" // the client keeps its own copy
+
+	assertEqual(t, con.GetID(), "primary")
+	assertEqual(t, con.GetLabels()["region"], "eu")
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+
+	connected, ok := nextEvent(t, con).(ConnectedEvent)
+	assertEqual(t, ok, true)
+	assertEqual(t, connected.ID, "primary")
+	assertEqual(t, connected.Labels["region"], "eu")
+
+	for _, record := range output.records(t) {
+		assertEqual(t, record["id"], "primary")
+		labels, _ := record["labels"].(map[string]interface{})
+		assertEqual(t, labels["region"], "eu")
+	}
+}
+
+func TestConfig_UnmarshalIDAndLabels(t *testing.T) {
+	conf := NewConfig()
+	err := conf.Unmarshal(strings.NewReader(`{"endpoint": "localhost:8080", "connectionTimeout": "1s", "readTimeout": "1s", "writeTimeout": "1s", "id": "primary", "labels": {"region": "eu"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, conf.ID, "primary")
+	assertEqual(t, conf.Labels["region"], "eu")
+}
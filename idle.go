@@ -0,0 +1,93 @@
+package eventedconnection
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IdleAction decides what the client does when nothing was read from the connection
+// within Config.IdleTimeout
+type IdleAction int
+
+const (
+	// IdleIgnore keeps waiting for data
+	IdleIgnore IdleAction = iota
+	// IdlePing writes Config.IdlePingPayload to prompt the peer to answer
+	IdlePing
+	// IdleReconnect replaces the connection with a new one, see Client.Reconnect
+	IdleReconnect
+	// IdleClose closes the connection with ErrIdle
+	IdleClose
+)
+
+func (a IdleAction) String() string {
+	switch a {
+	case IdleIgnore:
+		return "ignore"
+	case IdlePing:
+		return "ping"
+	case IdleReconnect:
+		return "reconnect"
+	case IdleClose:
+		return "close"
+	}
+	return fmt.Sprintf("IdleAction(%d)", int(a))
+}
+
+// ParseIdleAction converts an action name ("ignore", "ping", "reconnect" or "close", as
+// returned by IdleAction.String) into the corresponding IdleAction.
+func ParseIdleAction(name string) (IdleAction, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "ignore":
+		return IdleIgnore, nil
+	case "ping":
+		return IdlePing, nil
+	case "reconnect":
+		return IdleReconnect, nil
+	case "close":
+		return IdleClose, nil
+	}
+	return 0, fmt.Errorf("unknown idle action %q", name)
+}
+
+// readDeadline returns the deadline for the next read when nothing has been read since
+// lastRead, and whether it is the idle deadline rather than the read timeout
+func (conn *Client) readDeadline(lastRead time.Time) (time.Time, bool) {
+	now := conn.clock.Now()
+	if conn.idleTimeout <= 0 {
+		return now.Add(conn.GetReadTimeout()), false
+	}
+
+	deadline := lastRead.Add(conn.GetReadTimeout())
+	if idle := now.Add(conn.idleTimeout); idle.Before(deadline) {
+		return idle, true
+	}
+	return deadline, false
+}
+
+// onIdle handles an IdleTimeout expiring after nothing was read for idle. It returns
+// whether the read loop should carry on reading from the connection, or the error to
+// close it with.
+func (conn *Client) onIdle(idle time.Duration) (bool, error) {
+	conn.stats.recordIdle()
+	conn.emit(IdleEvent{Origin: conn.origin(), Idle: idle})
+	if conn.onIdleHook != nil {
+		if err := conn.onIdleHook(idle); err != nil {
+			return false, err
+		}
+	}
+
+	switch conn.idleAction {
+	case IdlePing:
+		// a failed ping is reported by write, which also closes the connection if need be
+		_ = conn.writeControl(conn.idlePingPayload, conn.GetWriteTimeout())
+	case IdleReconnect:
+		// Reconnect retires this read loop and starts a new one for the new connection
+		_ = conn.Reconnect()
+		return false, nil
+	case IdleClose:
+		return false, ErrIdle
+	}
+	return true, nil
+}
@@ -0,0 +1,106 @@
+package eventedconnection_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_IdlePing(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:        l.Addr().String(),
+		ReadTimeout:     2 * time.Second,
+		IdleTimeout:     50 * time.Millisecond,
+		IdleAction:      IdlePing,
+		IdlePingPayload: []byte("PING"),
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	// the echo server answers the ping
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), "PING")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the ping")
+	}
+	assertEqual(t, con.IsActive(), true)
+	assertEqual(t, con.GetStats().IdleTimeouts > 0, true)
+
+	for event := range con.Events {
+		if idle, ok := event.(IdleEvent); ok {
+			assertEqual(t, idle.Idle >= conf.IdleTimeout, true)
+			break
+		}
+	}
+}
+
+func TestClient_IdleClose(t *testing.T) {
+	// a server that never sends anything
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			defer c.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	idled := 0
+	conf := Config{
+		Endpoint:    l.Addr().String(),
+		ReadTimeout: 5 * time.Second,
+		IdleTimeout: 50 * time.Millisecond,
+		IdleAction:  IdleClose,
+		OnIdleHook: func(idle time.Duration) error {
+			idled++
+			return nil
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-con.Disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the connection to be closed")
+	}
+	assertEqual(t, errors.Is(con.Err(), ErrIdle), true)
+	assertEqual(t, idled, 1)
+}
+
+func TestParseIdleAction(t *testing.T) {
+	for _, action := range []IdleAction{IdleIgnore, IdlePing, IdleReconnect, IdleClose} {
+		parsed, err := ParseIdleAction(action.String())
+		assertEqual(t, err, nil)
+		assertEqual(t, parsed, action)
+	}
+	_, err := ParseIdleAction("sleep")
+	assertNotNil(t, err)
+}
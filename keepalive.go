@@ -0,0 +1,52 @@
+package eventedconnection
+
+// keepalive writes a keepalive message whenever nothing was written to the connection
+// for conn.keepaliveInterval, until stop is closed or the client is shut down
+func (conn *Client) keepalive(stop <-chan struct{}) {
+	last := conn.clock.Now() // when something was last written, as far as the keepalive knows
+	timer := conn.clock.NewTimer(conn.keepaliveInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+		case <-stop:
+			return
+		case <-conn.done:
+			return
+		}
+
+		if written := conn.GetStats().LastWriteAt; written.After(last) {
+			last = written
+		}
+		if wait := conn.keepaliveInterval - conn.since(last); wait > 0 {
+			timer.Reset(wait) // written to since, so the connection isn't silent yet
+			continue
+		}
+
+		conn.sendKeepalive()
+		last = conn.clock.Now()
+		timer.Reset(conn.keepaliveInterval)
+	}
+}
+
+// sendKeepalive writes the configured keepalive payload, or the one returned by the
+// KeepaliveHook
+func (conn *Client) sendKeepalive() {
+	payload := conn.keepalivePayload
+	if conn.keepaliveHook != nil {
+		var err error
+		if payload, err = conn.keepaliveHook(); err != nil {
+			conn.handleError(err)
+			return
+		}
+	}
+	if len(payload) == 0 {
+		return
+	}
+
+	// errors are reported by write
+	if err := conn.writeControl(payload, conn.GetWriteTimeout()); err == nil {
+		conn.stats.recordKeepalive()
+	}
+}
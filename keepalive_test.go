@@ -0,0 +1,56 @@
+package eventedconnection_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_Keepalive(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	sent := 0
+	conf := Config{
+		Endpoint:          l.Addr().String(),
+		KeepaliveInterval: 50 * time.Millisecond,
+		KeepaliveHook: func() ([]byte, error) {
+			sent++
+			return []byte("KA" + strconv.Itoa(sent)), nil
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	// the echo server sends the keepalive back
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), "KA1")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for a keepalive")
+	}
+
+	// writing restarts the interval, so no keepalive is sent while writes keep coming
+	payload := []byte("data")
+	for range 5 {
+		if err = con.Write(&payload); err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, string(*<-con.Read), "data")
+		time.Sleep(20 * time.Millisecond)
+	}
+	assertEqual(t, con.GetStats().KeepalivesSent, uint64(1))
+}
@@ -0,0 +1,281 @@
+package eventedconnection_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_Shutdown(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Close is not terminal
+	con.Close()
+	select {
+	case <-con.Done():
+		t.Fatal("Done closed by Close")
+	default:
+	}
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+
+	con.Shutdown()
+	con.Shutdown()
+	select {
+	case <-con.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for Done")
+	}
+	assertEqual(t, con.IsActive(), false)
+	assertEqual(t, con.Err(), ErrShutdown)
+	assertEqual(t, con.Reconnect(), ErrShutdown)
+	assertEqual(t, con.Connect(), ErrShutdown)
+}
+
+func TestClient_CloseGracefully(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	tests := []struct {
+		name         string
+		ackExtractor AckExtractor
+		timeout      time.Duration
+		wantErr      error
+	}{
+		{"acknowledged", extractSeq, 2 * time.Second, nil},
+		{"never acknowledged", func([]byte) (uint64, bool) { return 0, false }, 50 * time.Millisecond, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := Config{Endpoint: l.Addr().String(), AckExtractor: tt.ackExtractor}
+			con, err := NewClient(&conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer con.Shutdown()
+
+			if err = con.Connect(); err != nil {
+				t.Fatal(err)
+			}
+			if err = con.WriteString("last words"); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			err = con.CloseGracefully(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CloseGracefully returned %v, want %v", err, tt.wantErr)
+			}
+			assertEqual(t, con.IsActive(), false)
+			assertEqual(t, con.WriteString("too late"), ErrClosed)
+		})
+	}
+}
+
+func TestClient_CloseWithTimeout(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	release := make(chan struct{})
+	conf := Config{
+		Endpoint: l.Addr().String(),
+		BeforeDisconnectHook: func() error {
+			<-release // a hook that hangs
+			return nil
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	assertEqual(t, con.CloseWithTimeout(50*time.Millisecond), context.DeadlineExceeded)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("CloseWithTimeout took %v", elapsed)
+	}
+
+	close(release)
+	select {
+	case <-con.Disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the close to complete")
+	}
+}
+
+func TestClient_CloseWithError(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	errMaintenance := errors.New("maintenance")
+	var hookCause error
+	conf := Config{
+		Endpoint:       l.Addr().String(),
+		AutoReconnect:  true,
+		ReconnectDelay: 10 * time.Millisecond,
+		BeforeDisconnectContextHook: func(ctx HookContext) error {
+			hookCause = ctx.Cause
+			return nil
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	con.CloseWithError(errMaintenance)
+
+	assertEqual(t, hookCause, errMaintenance)
+	assertEqual(t, con.Err(), errMaintenance)
+	for event := range con.Events {
+		if disconnected, ok := event.(DisconnectedEvent); ok {
+			assertEqual(t, disconnected.Err, errMaintenance)
+			break
+		}
+	}
+
+	// a deliberate close isn't reconnected
+	time.Sleep(50 * time.Millisecond)
+	assertEqual(t, con.GetStats().Reconnects, uint64(0))
+	assertEqual(t, con.IsActive(), false)
+}
+
+func TestClient_WaitClosed(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:          l.Addr().String(),
+		KeepaliveInterval: 10 * time.Millisecond,
+		KeepalivePayload:  []byte("ping"),
+		OnMessageHook:     func(data []byte) error { return nil },
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	waitClosed := func() {
+		t.Helper()
+		waited := make(chan struct{})
+		go func() {
+			con.WaitClosed()
+			close(waited)
+		}()
+		select {
+		case <-waited:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting for WaitClosed")
+		}
+	}
+
+	time.Sleep(30 * time.Millisecond) // let some keepalives be echoed
+	con.Close()
+	waitClosed()
+	con.Shutdown()
+	waitClosed()
+}
+
+func TestClient_MaxConnectionAge(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:         l.Addr().String(),
+		MaxConnectionAge: 100 * time.Millisecond,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Connected, then Reconnecting, Disconnected and Connected again
+	timeout := time.After(2 * time.Second)
+	for connects := 0; connects < 2; {
+		select {
+		case event := <-con.Events:
+			if _, ok := event.(ConnectedEvent); ok {
+				connects++
+			}
+		case <-timeout:
+			t.Fatal("Test timed out while waiting for the connection to be recycled")
+		}
+	}
+	assertEqual(t, con.Err(), nil)
+
+	payload := []byte("after recycle")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), string(payload))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+	if reconnects := con.GetStats().Reconnects; reconnects < 1 {
+		t.Errorf("Expected the connection to be recycled, got %d reconnects", reconnects)
+	}
+}
@@ -0,0 +1,34 @@
+package eventedconnection
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler is a slog.Handler that drops all records. It is used when no
+// Logger is configured.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// newLogger returns the logger used by a client: the configured logger with the
+// client's attributes attached, or one that discards everything.
+func newLogger(conf *Config) *slog.Logger {
+	if conf.Logger == nil {
+		return slog.New(discardHandler{})
+	}
+
+	attrs := append([]any{slog.String("endpoint", conf.Endpoint)}, identityLogAttrs(conf)...)
+	return conf.Logger.With(attrs...)
+}
+
+// handleError logs err, sends it as an ErrorEvent and passes it to the OnErrorHook
+func (conn *Client) handleError(err error) {
+	conn.logger.Error("connection error", slog.Any("error", err))
+	conn.stats.recordError()
+	conn.emit(ErrorEvent{Origin: conn.origin(), Err: err})
+	conn.onErrorHook(err)
+}
@@ -0,0 +1,87 @@
+package eventedconnection_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+// syncBuffer is a bytes.Buffer safe for use by the client's goroutines
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func (b *syncBuffer) records(t *testing.T) []map[string]interface{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b.buffer.Bytes()))
+	for decoder.More() {
+		record := map[string]interface{}{}
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestClient_Logger(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	output := &syncBuffer{}
+	conf := NewConfig()
+	conf.Endpoint = l.Addr().String()
+	conf.ReadTimeout = 1 * time.Second
+	conf.Logger = slog.New(slog.NewJSONHandler(output, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	con, err := NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+
+	payload := []byte("Testing logging")
+	assertNotNil(t, con.Write(&payload))
+
+	messages := []string{}
+	for _, record := range output.records(t) {
+		assertEqual(t, record["endpoint"], l.Addr().String())
+		messages = append(messages, record["msg"].(string))
+	}
+
+	if assertEqual(t, len(messages), 3); len(messages) == 3 {
+		assertEqual(t, messages[0], "connected")
+		assertEqual(t, messages[1], "disconnected")
+		assertEqual(t, messages[2], "connection error")
+	}
+}
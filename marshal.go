@@ -0,0 +1,184 @@
+package eventedconnection
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+// MarshalJSON encodes the config in the format read by Unmarshal: durations are
+// strings parsable by time.ParseDuration and TLS versions and cipher suites are
+// given by name. Fields that can't be represented in JSON, such as hooks,
+// TLSConfig and Logger, are left out.
+func (conf Config) MarshalJSON() ([]byte, error) {
+	return json.Marshal(conf.fileConfig())
+}
+
+// fileConfig converts the config into its file representation
+func (conf *Config) fileConfig() *fileConfig {
+	fc := fileConfig{
+		Endpoint:                conf.Endpoint,
+		ConnectionTimeout:       conf.ConnectionTimeout.String(),
+		ReadTimeout:             conf.ReadTimeout.String(),
+		WriteTimeout:            conf.WriteTimeout.String(),
+		ReadBufferSize:          conf.ReadBufferSize,
+		ReadChannelSize:         conf.ReadChannelSize,
+		ValueReads:              conf.ValueReads,
+		PooledReads:             conf.PooledReads,
+		RingBufferSize:          conf.RingBufferSize,
+		ReplayBufferSize:        conf.ReplayBufferSize,
+		ID:                      conf.ID,
+		Labels:                  conf.Labels,
+		SRVService:              conf.SRVService,
+		SRVProto:                conf.SRVProto,
+		SRVName:                 conf.SRVName,
+		ExpvarPrefix:            conf.ExpvarPrefix,
+		HexDump:                 conf.HexDump,
+		HexDumpLimit:            conf.HexDumpLimit,
+		EventsBufferSize:        conf.EventsBufferSize,
+		EnableNagle:             conf.EnableNagle,
+		OnMessageConcurrency:    conf.OnMessageConcurrency,
+		Checksum:                conf.Checksum,
+		KeepReadingOnHookError:  conf.KeepReadingOnHookError,
+		MaxWritesPerSecond:      conf.MaxWritesPerSecond,
+		MaxBytesPerSecond:       conf.MaxBytesPerSecond,
+		PSKIdentity:             conf.PSKIdentity,
+		UseTLS:                  conf.UseTLS,
+		CertFile:                conf.CertFile,
+		KeyFile:                 conf.KeyFile,
+		CAFile:                  conf.CAFile,
+		PinnedPublicKeys:        conf.PinnedPublicKeys,
+		PinnedCertificates:      conf.PinnedCertificates,
+		NextProtos:              conf.NextProtos,
+		AutoReconnect:           conf.AutoReconnect,
+		MaxReconnectAttempts:    conf.MaxReconnectAttempts,
+		DialRetries:             conf.DialRetries,
+		CircuitBreakerThreshold: conf.CircuitBreakerThreshold,
+		WriteQueueSize:          conf.WriteQueueSize,
+		WriteQueueMaxBytes:      conf.WriteQueueMaxBytes,
+		WriteQueueFile:          conf.WriteQueueFile,
+		WriteQueueFileMaxSize:   conf.WriteQueueFileMaxSize,
+		MaxDeliveryAttempts:     conf.MaxDeliveryAttempts,
+	}
+
+	if conf.TLSMinVersion != 0 {
+		fc.TLSMinVersion = tls.VersionName(conf.TLSMinVersion)
+	}
+	if conf.TLSMaxVersion != 0 {
+		fc.TLSMaxVersion = tls.VersionName(conf.TLSMaxVersion)
+	}
+	for _, id := range conf.TLSCipherSuites {
+		fc.TLSCipherSuites = append(fc.TLSCipherSuites, tls.CipherSuiteName(id))
+	}
+	if conf.RateLimitPolicy != RateLimitBlock {
+		fc.RateLimitPolicy = conf.RateLimitPolicy.String()
+	}
+	if conf.IdleTimeout != 0 {
+		fc.IdleTimeout = conf.IdleTimeout.String()
+	}
+	if conf.RateWindow != 0 {
+		fc.RateWindow = conf.RateWindow.String()
+	}
+	if conf.RateSampleInterval != 0 {
+		fc.RateSampleInterval = conf.RateSampleInterval.String()
+	}
+	if conf.WatchdogTimeout != 0 {
+		fc.WatchdogTimeout = conf.WatchdogTimeout.String()
+	}
+	if conf.IdleAction != IdleIgnore {
+		fc.IdleAction = conf.IdleAction.String()
+	}
+	fc.IdlePingPayload = string(conf.IdlePingPayload)
+	if conf.KeepaliveInterval != 0 {
+		fc.KeepaliveInterval = conf.KeepaliveInterval.String()
+	}
+	fc.KeepalivePayload = string(conf.KeepalivePayload)
+	if conf.HealthCheckInterval != 0 {
+		fc.HealthCheckInterval = conf.HealthCheckInterval.String()
+	}
+	if conf.HealthCheckTimeout != 0 {
+		fc.HealthCheckTimeout = conf.HealthCheckTimeout.String()
+	}
+	fc.HealthCheckPayload = string(conf.HealthCheckPayload)
+	fc.HealthCheckReconnect = conf.HealthCheckReconnect
+	if conf.WriteQueueTTL != 0 {
+		fc.WriteQueueTTL = conf.WriteQueueTTL.String()
+	}
+	if conf.CircuitBreakerCooldown != 0 {
+		fc.CircuitBreakerCooldown = conf.CircuitBreakerCooldown.String()
+	}
+	if conf.MaxConnectionAge != 0 {
+		fc.MaxConnectionAge = conf.MaxConnectionAge.String()
+	}
+	if conf.WriteErrorPolicy != WriteErrorClose {
+		fc.WriteErrorPolicy = conf.WriteErrorPolicy.String()
+	}
+	if conf.SlowConsumerThreshold != 0 {
+		fc.SlowConsumerThreshold = conf.SlowConsumerThreshold.String()
+	}
+	if conf.DialRetryDelay != 0 {
+		fc.DialRetryDelay = conf.DialRetryDelay.String()
+	}
+	if conf.DialRetryBudget != 0 {
+		fc.DialRetryBudget = conf.DialRetryBudget.String()
+	}
+	if conf.ReconnectDelay != 0 {
+		fc.ReconnectDelay = conf.ReconnectDelay.String()
+	}
+	if conf.MaxReconnectDelay != 0 {
+		fc.MaxReconnectDelay = conf.MaxReconnectDelay.String()
+	}
+	if conf.MaxReconnectDuration != 0 {
+		fc.MaxReconnectDuration = conf.MaxReconnectDuration.String()
+	}
+	if conf.CertExpiryWarning != 0 {
+		fc.CertExpiryWarning = conf.CertExpiryWarning.String()
+	}
+
+	return &fc
+}
+
+// EffectiveConfig returns the configuration the client is running with: the Config
+// it was created from with the defaults it applied filled in. Marshal it to JSON to
+// dump or persist the effective settings.
+func (conn *Client) EffectiveConfig() *Config {
+	conn.settingsMutex.RLock()
+	conf := conn.config
+	conn.settingsMutex.RUnlock()
+
+	conf.Labels = maps.Clone(conf.Labels)
+	conf.PinnedPublicKeys = slices.Clone(conf.PinnedPublicKeys)
+	conf.PinnedCertificates = slices.Clone(conf.PinnedCertificates)
+	conf.NextProtos = slices.Clone(conf.NextProtos)
+	conf.TLSCipherSuites = slices.Clone(conf.TLSCipherSuites)
+
+	conf.ConnectionTimeout = conn.GetConnectionTimeout()
+	conf.ReadTimeout = conn.GetReadTimeout()
+	conf.WriteTimeout = conn.GetWriteTimeout()
+	conf.ReadBufferSize = conn.GetReadBufferSize()
+	conf.HexDumpLimit = conn.hexDumpLimit
+	conf.EventsBufferSize = cap(conn.Events)
+	conf.ReadChannelSize = cap(conn.Read) + cap(conn.Data) // only one of them is used
+	if conf.DialRetries > 0 {
+		conf.DialRetryDelay = conn.dialRetryDelay
+	}
+	if conn.breaker != nil {
+		conf.CircuitBreakerCooldown = conn.breaker.cooldown
+	}
+	if conf.AutoReconnect {
+		conf.ReconnectDelay = conn.reconnectDelay
+		conf.MaxReconnectDelay = conn.maxReconnectDelay
+	}
+	if conn.healthCheck != nil {
+		conf.HealthCheckTimeout = conn.healthCheck.timeout
+	}
+	if conn.acks != nil {
+		conf.MaxDeliveryAttempts = conn.maxDeliveryAttempts
+	}
+	if conf.UseTLS || conf.StartTLSHook != nil {
+		conf.CertExpiryWarning = conn.certExpiryWarning
+	}
+
+	return &conf
+}
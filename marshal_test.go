@@ -0,0 +1,94 @@
+package eventedconnection_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+)
+
+func TestConfig_MarshalJSON(t *testing.T) {
+	conf := NewConfig()
+	conf.Endpoint = "localhost:8080"
+	conf.ReadTimeout = 90 * time.Second
+	conf.ID = "primary"
+	conf.Labels = map[string]string{"region": "eu"}
+	conf.UseTLS = true
+	conf.CAFile = "./testutils/testserver.crt"
+	conf.TLSMinVersion = tls.VersionTLS12
+	conf.TLSCipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+	conf.CertExpiryWarning = 48 * time.Hour
+	conf.MaxBytesPerSecond = 1024
+	conf.RateLimitPolicy = RateLimitError
+	conf.MaxReconnectAttempts = 5
+	conf.MaxReconnectDuration = time.Minute
+	conf.AfterConnectHook = func() error { return nil }
+
+	data, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := NewConfig()
+	if err = decoded.Unmarshal(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, decoded.Endpoint, conf.Endpoint)
+	assertEqual(t, decoded.ConnectionTimeout, conf.ConnectionTimeout)
+	assertEqual(t, decoded.ReadTimeout, conf.ReadTimeout)
+	assertEqual(t, decoded.WriteTimeout, conf.WriteTimeout)
+	assertEqual(t, decoded.ReadBufferSize, conf.ReadBufferSize)
+	assertEqual(t, decoded.ID, conf.ID)
+	assertEqual(t, decoded.Labels["region"], "eu")
+	assertEqual(t, decoded.UseTLS, true)
+	assertEqual(t, decoded.TLSConfig != nil, true)
+	assertEqual(t, decoded.CAFile, conf.CAFile)
+	assertEqual(t, decoded.TLSMinVersion, conf.TLSMinVersion)
+	assertEqual(t, decoded.TLSMaxVersion, uint16(0))
+	assertEqual(t, len(decoded.TLSCipherSuites), 1)
+	assertEqual(t, decoded.TLSCipherSuites[0], conf.TLSCipherSuites[0])
+	assertEqual(t, decoded.CertExpiryWarning, conf.CertExpiryWarning)
+	assertEqual(t, decoded.MaxBytesPerSecond, conf.MaxBytesPerSecond)
+	assertEqual(t, decoded.RateLimitPolicy, RateLimitError)
+	assertEqual(t, decoded.MaxReconnectAttempts, 5)
+	assertEqual(t, decoded.MaxReconnectDuration, time.Minute)
+}
+
+func TestConfig_UnmarshalInvalidDuration(t *testing.T) {
+	data := `{"endpoint": "localhost:8080", "connectionTimeout": "5s", "readTimeout": "5s",
+		"writeTimeout": "5s", "reconnectDelay": "soon"}`
+
+	err := NewConfig().Unmarshal(strings.NewReader(data))
+	if err == nil || !strings.Contains(err.Error(), "reconnectDelay") {
+		t.Fatalf("Expected an error naming reconnectDelay, got %v", err)
+	}
+}
+
+func TestClient_EffectiveConfig(t *testing.T) {
+	conf := Config{Endpoint: "localhost:5555", ReadTimeout: 2 * time.Second}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	effective := con.EffectiveConfig()
+	assertEqual(t, effective.Endpoint, conf.Endpoint)
+	assertEqual(t, effective.ReadTimeout, 2*time.Second)
+	assertEqual(t, effective.ConnectionTimeout, DefaultConnectionTimeout)
+	assertEqual(t, effective.WriteTimeout, DefaultWriteTimeout)
+	assertEqual(t, effective.ReadBufferSize, DefaultReadBufferSize)
+	assertEqual(t, effective.EventsBufferSize, DefaultEventsBufferSize)
+
+	data, err := json.Marshal(effective)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"connectionTimeout":"30s"`)) {
+		t.Errorf("Expected the default connection timeout in %s", data)
+	}
+}
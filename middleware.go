@@ -0,0 +1,47 @@
+package eventedconnection
+
+import "errors"
+
+// Middleware wraps a Handler to add behaviour around it, e.g. metrics, compression or
+// tracing. It may transform the data before passing it to next, call next several
+// times or not at all, and inspect or replace the error next returns. Each Middleware
+// is called once, by NewClient, so state kept by the Handler it returns lasts as long
+// as the client.
+type Middleware func(next Handler) Handler
+
+// chain wraps handler in middleware so that middleware[0] is the outermost
+func chain(handler Handler, middleware []Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// pipeline is a chain of middleware around a handler, built once so that state kept by
+// the middleware lives as long as the client. Messages must be passed through it one
+// at a time: reads from the read loop and writes under conn.writeMutex.
+type pipeline struct {
+	handler    Handler // the middleware wrapped around the inner handler
+	handlerErr error   // returned by the inner handler for the message in progress
+}
+
+// newPipeline wraps handler in middleware so that middleware[0] is the outermost
+func newPipeline(middleware []Middleware, handler Handler) *pipeline {
+	p := &pipeline{}
+	p.handler = chain(func(data []byte) error {
+		p.handlerErr = handler(data)
+		return p.handlerErr
+	}, middleware)
+	return p
+}
+
+// run passes data through the pipeline. The inner handler reports its own errors;
+// errors raised by the middleware itself are passed to report.
+func (p *pipeline) run(data []byte, report func(error)) error {
+	p.handlerErr = nil
+	err := p.handler(data)
+	if err != nil && (p.handlerErr == nil || !errors.Is(err, p.handlerErr)) {
+		report(err)
+	}
+	return err
+}
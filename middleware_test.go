@@ -0,0 +1,141 @@
+package eventedconnection_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func prefixMiddleware(prefix string) Middleware {
+	return func(next Handler) Handler {
+		return func(data []byte) error {
+			return next(append([]byte(prefix), data...))
+		}
+	}
+}
+
+func TestClient_Middleware(t *testing.T) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errRejected := errors.New("rejected")
+	rejectOn := func(word string) Middleware {
+		return func(next Handler) Handler {
+			return func(data []byte) error {
+				if bytes.Contains(data, []byte(word)) {
+					return errRejected
+				}
+				return next(data)
+			}
+		}
+	}
+
+	errs := make(chan error, 4)
+	conf := Config{
+		Endpoint:        l.Addr().String(),
+		ReadTimeout:     2 * time.Second,
+		WriteTimeout:    1 * time.Second,
+		WriteMiddleware: []Middleware{rejectOn("reject"), prefixMiddleware("a"), prefixMiddleware("b")},
+		ReadMiddleware:  []Middleware{prefixMiddleware("c"), prefixMiddleware("d"), rejectOn("bad")},
+		OnErrorHook: func(err error) error {
+			errs <- err
+			return err
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("!")
+	if err = con.Write(&data); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case received := <-con.Read:
+		assertEqual(t, string(*received), "dcba!")
+	case <-time.After(time.Second):
+		t.Fatal("expected a message")
+	}
+
+	// an error from the write chain is returned without closing the connection
+	data = []byte("reject")
+	assertEqual(t, con.Write(&data), errRejected)
+	assertEqual(t, <-errs, errRejected)
+	assertEqual(t, con.IsActive(), true)
+	stats := con.GetStats()
+	assertEqual(t, stats.WriteErrors, uint64(1))
+
+	// an error from the read chain closes the connection
+	data = []byte("bad")
+	if err = con.Write(&data); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-con.Disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("expected the read chain's error to close the connection")
+	}
+	assertEqual(t, <-errs, errRejected)
+}
+
+func TestClient_MiddlewareState(t *testing.T) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// counter numbers the messages passing through it, so its closure must outlive them
+	var built atomic.Int32
+	counter := func(next Handler) Handler {
+		built.Add(1)
+		count := 0
+		return func(data []byte) error {
+			count++
+			return next(fmt.Appendf(data, "%d", count))
+		}
+	}
+
+	conf := Config{
+		Endpoint:        l.Addr().String(),
+		WriteMiddleware: []Middleware{counter},
+		ReadMiddleware:  []Middleware{counter},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if err = con.WriteString("m"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := con.ReadWithTimeout(2 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, string(data), fmt.Sprintf("m%d%d", i, i))
+	}
+	assertEqual(t, built.Load(), int32(2))
+}
@@ -0,0 +1,16 @@
+//go:build !chaos
+
+package eventedconnection
+
+import "net"
+
+// chaosState is empty unless built with the chaos build tag, see chaos.go
+type chaosState struct{}
+
+func (conn *Client) chaosRead(connection net.Conn, buffer []byte) (int, error) {
+	return connection.Read(buffer)
+}
+
+func (conn *Client) chaosWrite(connection net.Conn, payload []byte) (int, error) {
+	return connection.Write(payload)
+}
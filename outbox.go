@@ -0,0 +1,186 @@
+package eventedconnection
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// outboxRecordHeader is the size of the header preceding each write in the outbox
+// file: the time it was queued in Unix nanoseconds, its sequence number (zero if not
+// numbered, see Config.AckExtractor) and its length, all big-endian
+const outboxRecordHeader = 20
+
+// outbox persists the write queue in an append-only file, see Config.WriteQueueFile.
+// Writes longer than maxFrameSize aren't persisted, and a longer record in the file
+// is taken for corruption.
+type outbox struct {
+	path    string
+	file    *os.File
+	size    int64 // current size of the file
+	maxSize int64 // zero for unlimited
+}
+
+// openOutbox opens (or creates) the outbox file at path and returns the writes it
+// holds. A record cut short by a crash is discarded.
+func openOutbox(path string, maxSize int64) (*outbox, []queuedWrite, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var messages []queuedWrite
+	var size int64
+	reader := bufio.NewReader(file)
+	header := make([]byte, outboxRecordHeader)
+	for {
+		if _, err = io.ReadFull(reader, header); err != nil {
+			break
+		}
+		length := binary.BigEndian.Uint32(header[16:])
+		if length > maxFrameSize {
+			file.Close()
+			return nil, nil, fmt.Errorf("outbox file %s is corrupt: record of %d bytes at offset %d", path, length, size)
+		}
+		data := make([]byte, length)
+		if _, err = io.ReadFull(reader, data); err != nil {
+			break
+		}
+		queuedAt := time.Unix(0, int64(binary.BigEndian.Uint64(header)))
+		seq := binary.BigEndian.Uint64(header[8:])
+		messages = append(messages, queuedWrite{data: data, queuedAt: queuedAt, seq: seq})
+		size += int64(outboxRecordHeader + len(data))
+	}
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		file.Close()
+		return nil, nil, err
+	}
+
+	// drop a partial record at the end and append after the last complete one
+	o := &outbox{path: path, file: file, maxSize: maxSize}
+	if err = o.truncate(size); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return o, messages, nil
+}
+
+// append writes message to the end of the file. It reports false without writing
+// anything if the file would grow beyond maxSize or message is too long.
+func (o *outbox) append(message queuedWrite) (bool, error) {
+	if !o.fits(o.size, message) {
+		return false, nil
+	}
+
+	record := encodeRecord(message)
+	if n, err := o.file.Write(record); err != nil {
+		if n > 0 {
+			// cut off the torn record, or the writes appended after it would be misread
+			err = errors.Join(err, o.truncate(o.size))
+		}
+		return false, err
+	}
+	o.size += int64(len(record))
+	return true, nil
+}
+
+// fits reports whether message can be added to a file of size bytes
+func (o *outbox) fits(size int64, message queuedWrite) bool {
+	if len(message.data) > maxFrameSize {
+		return false
+	}
+	return o.maxSize == 0 || size+outboxRecordHeader+int64(len(message.data)) <= o.maxSize
+}
+
+// encodeRecord returns the record holding message in the file
+func encodeRecord(message queuedWrite) []byte {
+	record := make([]byte, outboxRecordHeader, outboxRecordHeader+len(message.data))
+	binary.BigEndian.PutUint64(record, uint64(message.queuedAt.UnixNano()))
+	binary.BigEndian.PutUint64(record[8:], message.seq)
+	binary.BigEndian.PutUint32(record[16:], uint32(len(message.data)))
+	return append(record, message.data...)
+}
+
+// rewrite replaces the contents of the file with messages, e.g. once the writes
+// before them were flushed. The new contents are written to a temporary file that is
+// renamed over the old one, so a crash leaves either of them intact. The messages that
+// don't fit within maxSize are left out and reported by the returned error.
+func (o *outbox) rewrite(messages []queuedWrite) error {
+	dir := filepath.Dir(o.path)
+	temp, err := os.CreateTemp(dir, filepath.Base(o.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+
+	writer := bufio.NewWriter(temp)
+	var size int64
+	kept := 0
+	for _, message := range messages {
+		if !o.fits(size, message) {
+			break
+		}
+		record := encodeRecord(message)
+		if _, err = writer.Write(record); err != nil {
+			return fail(err)
+		}
+		size += int64(len(record))
+		kept++
+	}
+	if err = writer.Flush(); err != nil {
+		return fail(err)
+	}
+	if err = temp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err = os.Rename(temp.Name(), o.path); err != nil {
+		return fail(err)
+	}
+
+	o.file.Close()
+	o.file = temp
+	o.size = size
+	if err = syncDir(dir); err != nil {
+		return err
+	}
+	if kept < len(messages) {
+		return fmt.Errorf("%d queued writes don't fit in the outbox file and weren't persisted", len(messages)-kept)
+	}
+	return nil
+}
+
+// syncDir flushes the directory entries of dir, e.g. a rename, to disk
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// truncate cuts the file down to size bytes and moves the write offset to its end
+func (o *outbox) truncate(size int64) error {
+	if err := o.file.Truncate(size); err != nil {
+		return err
+	}
+	if _, err := o.file.Seek(size, io.SeekStart); err != nil {
+		return err
+	}
+	o.size = size
+	return nil
+}
+
+func (o *outbox) close() error {
+	return o.file.Close()
+}
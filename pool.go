@@ -0,0 +1,46 @@
+package eventedconnection
+
+import (
+	"bytes"
+	"sync"
+)
+
+// readBufferPool recycles the buffers messages are read into when Config.PooledReads
+// is set
+type readBufferPool struct {
+	pool sync.Pool // of *[]byte
+}
+
+// get returns a buffer of length size, reusing a released one if it is large enough
+func (p *readBufferPool) get(size int) *[]byte {
+	if buffer, ok := p.pool.Get().(*[]byte); ok && cap(*buffer) >= size {
+		*buffer = (*buffer)[:size]
+		return buffer
+	}
+	buffer := make([]byte, size)
+	return &buffer
+}
+
+func (p *readBufferPool) put(buffer *[]byte) {
+	*buffer = (*buffer)[:0]
+	p.pool.Put(buffer)
+}
+
+// Release returns the buffer of a message received from the Read channel to the
+// client's pool when Config.PooledReads is set, so a later read can reuse it instead
+// of allocating. data must not be used after it is released. Without PooledReads it
+// does nothing.
+func (conn *Client) Release(data *[]byte) {
+	if conn.readBuffers == nil || data == nil {
+		return
+	}
+	conn.readBuffers.put(data)
+}
+
+// CopyAndRelease returns a copy of a message received from the Read channel and
+// releases the original, see Release.
+func (conn *Client) CopyAndRelease(data *[]byte) []byte {
+	message := bytes.Clone(*data)
+	conn.Release(data)
+	return message
+}
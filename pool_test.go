@@ -0,0 +1,87 @@
+package eventedconnection_test
+
+import (
+	"crypto/rand"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_PooledReads(t *testing.T) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: time.Second,
+		PooledReads:  true,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	for _, message := range []string{"first", "second"} {
+		payload := []byte(message)
+		if err = con.Write(&payload); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case data := <-con.Read:
+			messages = append(messages, string(con.CopyAndRelease(data)))
+		case <-time.After(time.Second):
+			t.Fatalf("expected %q", message)
+		}
+	}
+
+	// a released buffer may be reused by the next read, the copies are unaffected
+	assertEqual(t, messages[0], "first")
+	assertEqual(t, messages[1], "second")
+}
+
+func BenchmarkThroughputPooled(b *testing.B) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	conf := Config{Endpoint: l.Addr().String(), PooledReads: true}
+	con, err := NewClient(&conf)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		b.Fatal(err)
+	}
+	defer con.Close()
+
+	payload := make([]byte, 32*1024)
+	rand.Read(payload)
+
+	b.ReportAllocs()
+	for range b.N {
+		if err = con.Write(&payload); err != nil {
+			b.Fatal(err)
+		}
+		for total := 0; total < len(payload); {
+			data := <-con.Read
+			total += len(*data)
+			con.Release(data)
+		}
+	}
+}
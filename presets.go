@@ -0,0 +1,31 @@
+package eventedconnection
+
+import "time"
+
+// NewLowLatencyConfig returns a config tuned for interactive protocols that exchange
+// small messages: Nagle's algorithm stays off, reads use a small buffer so messages
+// are delivered as soon as they arrive and short timeouts surface a slow endpoint
+// quickly. The fields can be adjusted before calling NewClient.
+func NewLowLatencyConfig() *Config {
+	conf := NewConfig()
+	conf.ReadBufferSize = 4 * 1024
+	conf.ConnectionTimeout = 5 * time.Second
+	conf.WriteTimeout = 1 * time.Second
+	conf.EnableNagle = false
+	return conf
+}
+
+// NewHighThroughputConfig returns a config tuned for bulk transfers: Nagle's
+// algorithm coalesces small writes, reads use a large buffer, the Read and Events
+// channels are deeper so bursts don't stall the read loop and the write timeout allows
+// for large payloads on a busy link. The fields can be adjusted before calling
+// NewClient.
+func NewHighThroughputConfig() *Config {
+	conf := NewConfig()
+	conf.ReadBufferSize = 64 * 1024
+	conf.WriteTimeout = 30 * time.Second
+	conf.EnableNagle = true
+	conf.ReadChannelSize = 16 * DefaultReadChannelSize
+	conf.EventsBufferSize = 4 * DefaultEventsBufferSize
+	return conf
+}
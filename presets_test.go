@@ -0,0 +1,49 @@
+package eventedconnection_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestConfigPresets(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	for _, conf := range []*Config{NewLowLatencyConfig(), NewHighThroughputConfig()} {
+		conf.Endpoint = l.Addr().String()
+		conf.Logger = nil
+		if err = conf.Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		con, err := NewClient(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = con.Connect(); err != nil {
+			t.Fatal(err)
+		}
+
+		payload := []byte("Testing presets")
+		if err = con.Write(&payload); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case data := <-con.Read:
+			assertEqual(t, string(*data), string(payload))
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting to read from connection")
+		}
+		con.Close()
+	}
+
+	assertEqual(t, NewLowLatencyConfig().EnableNagle, false)
+	assertEqual(t, NewHighThroughputConfig().EnableNagle, true)
+}
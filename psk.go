@@ -0,0 +1,237 @@
+package eventedconnection
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// MinPSKSize is the minimum length in bytes of a pre-shared key, see Config.PSK
+const MinPSKSize = 16
+
+// pskVersion opens the client's hello, so the format can evolve
+const pskVersion = 1
+
+// pskRandomSize is the size of the random values each side contributes to the key
+const pskRandomSize = 32
+
+// pskMaxRecord is the largest amount of data encrypted as one record
+const pskMaxRecord = 16 << 10
+
+// PSKLookup returns the pre-shared key of the client presenting identity, for PSKServer.
+// Returning an error rejects the client.
+type PSKLookup func(identity string) ([]byte, error)
+
+// newPSKLayer returns the encryption layer (see newEncryptionLayer) of a connection,
+// keyed with the HMAC-SHA256 of both randoms under the pre-shared key so every
+// connection gets a fresh key and records can't be replayed from another connection
+func newPSKLayer(key, clientRandom, serverRandom []byte) (*framedLayer, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(clientRandom)
+	mac.Write(serverRandom)
+	return newEncryptionLayer(mac.Sum(nil))
+}
+
+// pskConfirm checks that sealed is proof, sealed by the peer's layer, which it can
+// only be if the peer knows the pre-shared key
+func pskConfirm(layer *framedLayer, sealed, proof []byte) error {
+	opened, err := layer.decode(sealed)
+	if err != nil || !bytes.Equal(opened, proof) {
+		return ErrPSKMismatch
+	}
+	return nil
+}
+
+// PSKClient runs the client side of the pre-shared key handshake over connection,
+// presenting identity, and returns a connection encrypting everything written to and
+// read from it with a key derived from key. Both sides prove that they know key without
+// sending it; the handshake fails with ErrPSKMismatch if the endpoint's proof doesn't
+// match. The handshake is bound by connection's deadlines. connection is not closed
+// if the handshake fails.
+func PSKClient(connection net.Conn, identity string, key []byte) (net.Conn, error) {
+	hello := make([]byte, 1+pskRandomSize, 1+pskRandomSize+len(identity))
+	hello[0] = pskVersion
+	if _, err := rand.Read(hello[1:]); err != nil {
+		return nil, err
+	}
+	hello = append(hello, identity...)
+	if _, err := connection.Write(appendFrame(nil, hello)); err != nil {
+		return nil, err
+	}
+
+	// the endpoint's random, followed by the hello sealed with the connection's key
+	reply, err := readFrame(connection)
+	if err != nil {
+		return nil, err
+	}
+	if len(reply) <= pskRandomSize {
+		return nil, fmt.Errorf("invalid PSK handshake reply of %d bytes", len(reply))
+	}
+	serverRandom := reply[:pskRandomSize]
+
+	layer, err := newPSKLayer(key, hello[1:1+pskRandomSize], serverRandom)
+	if err != nil {
+		return nil, err
+	}
+	if err = pskConfirm(layer, reply[pskRandomSize:], hello); err != nil {
+		return nil, err
+	}
+
+	proof, err := layer.encode(serverRandom)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := connection.Write(appendFrame(nil, proof)); err != nil {
+		return nil, err
+	}
+	return &pskConn{Conn: connection, layer: layer, buffer: make([]byte, 4096)}, nil
+}
+
+// PSKServer runs the endpoint's side of the pre-shared key handshake started by
+// PSKClient over connection, looking up the client's key with lookup, and returns the
+// encrypted connection. It fails with ErrPSKMismatch if the client's proof doesn't
+// match the key. The handshake is bound by connection's deadlines. connection is not
+// closed if the handshake fails.
+func PSKServer(connection net.Conn, lookup PSKLookup) (net.Conn, error) {
+	hello, err := readFrame(connection)
+	if err != nil {
+		return nil, err
+	}
+	if len(hello) < 1+pskRandomSize || hello[0] != pskVersion {
+		return nil, errors.New("invalid PSK handshake hello")
+	}
+	clientRandom, identity := hello[1:1+pskRandomSize], string(hello[1+pskRandomSize:])
+
+	key, err := lookup(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	serverRandom := make([]byte, pskRandomSize)
+	if _, err := rand.Read(serverRandom); err != nil {
+		return nil, err
+	}
+	layer, err := newPSKLayer(key, clientRandom, serverRandom)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := layer.encode(hello)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := connection.Write(appendFrame(nil, slices.Concat(serverRandom, sealed))); err != nil {
+		return nil, err
+	}
+
+	proof, err := readFrame(connection)
+	if err != nil {
+		return nil, err
+	}
+	if err = pskConfirm(layer, proof, serverRandom); err != nil {
+		return nil, err
+	}
+	return &pskConn{Conn: connection, layer: layer, buffer: make([]byte, 4096)}, nil
+}
+
+// pskConn sends the stream written to it as records encrypted by the connection's
+// encryption layer, framed like EncryptionKey messages, and decrypts the records read.
+type pskConn struct {
+	net.Conn
+	layer *framedLayer
+
+	readMutex sync.Mutex
+	buffer    []byte
+	pending   []byte // decrypted data not read yet
+	readErr   error  // sticky decryption error
+
+	writeMutex sync.Mutex
+}
+
+// Read reads and decrypts records until there is data to return
+func (c *pskConn) Read(p []byte) (int, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	for len(c.pending) == 0 {
+		if c.readErr != nil {
+			return 0, c.readErr
+		}
+
+		n, err := c.Conn.Read(c.buffer)
+		if n > 0 {
+			if err := c.layer.frames.read(c.buffer[:n], c.open); err != nil {
+				c.readErr = err
+				return 0, err
+			}
+		}
+		if err != nil && len(c.pending) == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// open decrypts a record. c.readMutex must be held.
+func (c *pskConn) open(record []byte) error {
+	data, err := c.layer.decode(record)
+	if err != nil {
+		return err
+	}
+	c.pending = append(c.pending, data...)
+	return nil
+}
+
+// Write encrypts p as one or more records, written to the connection at once
+func (c *pskConn) Write(p []byte) (int, error) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	var out []byte
+	for data := p; ; {
+		n := min(len(data), pskMaxRecord)
+		record, err := c.layer.encode(data[:n])
+		if err != nil {
+			return 0, err
+		}
+		out = appendFrame(out, record)
+		if data = data[n:]; len(data) == 0 {
+			break
+		}
+	}
+
+	if _, err := c.Conn.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// pskHandshake runs PSKClient over a newly established connection, within the
+// connection timeout
+func (conn *Client) pskHandshake(ctx context.Context, connection net.Conn) (net.Conn, error) {
+	_, span := conn.startSpan(ctx, "eventedconnection.PSKHandshake")
+	defer span.End()
+
+	if err := connection.SetDeadline(conn.clock.Now().Add(conn.GetConnectionTimeout())); err != nil {
+		return nil, err
+	}
+	defer connection.SetDeadline(time.Time{})
+
+	pskConn, err := PSKClient(connection, conn.pskIdentity, conn.psk)
+	if err != nil {
+		err = opError("handshake", connection, err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+	return pskConn, nil
+}
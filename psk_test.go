@@ -0,0 +1,126 @@
+package eventedconnection_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+var testPSK = []byte("0123456789abcdef0123456789abcdef")
+
+func TestClient_PSK(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.PSKEchoServer(done, map[string][]byte{"device-1": testPSK})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String(), PSKIdentity: "device-1", PSK: testPSK}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// larger than a single record
+	message := bytes.Repeat([]byte("0123456789"), 5000)
+	if err = con.WriteBytes(message); err != nil {
+		t.Fatal(err)
+	}
+	var received []byte
+	for len(received) < len(message) {
+		data, err := con.ReadWithTimeout(2 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, data...)
+	}
+	assertEqual(t, bytes.Equal(received, message), true)
+}
+
+func TestClient_PSKMismatch(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.PSKEchoServer(done, map[string][]byte{"device-1": testPSK})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String(), PSKIdentity: "device-1", PSK: []byte("another key of 32 bytes at least")}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	err = con.Connect()
+	if !errors.Is(err, ErrPSKMismatch) {
+		t.Fatalf("Expected ErrPSKMismatch, got %v", err)
+	}
+	assertEqual(t, ClassifyError(err), ErrorFatal)
+	assertEqual(t, con.IsActive(), false)
+}
+
+// teeConn records everything written to the connection
+type teeConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *teeConn) Write(p []byte) (int, error) {
+	c.written.Write(p)
+	return c.Conn.Write(p)
+}
+
+func TestPSKClient(t *testing.T) {
+	clientEnd, serverEnd := net.Pipe()
+	defer clientEnd.Close()
+	defer serverEnd.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := PSKServer(serverEnd, func(identity string) ([]byte, error) {
+			if identity != "device-1" {
+				return nil, errors.New("unknown identity")
+			}
+			return testPSK, nil
+		})
+		if err != nil {
+			received <- nil
+			return
+		}
+		data := make([]byte, len("secret message"))
+		io.ReadFull(conn, data)
+		received <- data
+	}()
+
+	tee := &teeConn{Conn: clientEnd}
+	conn, err := PSKClient(tee, "device-1", testPSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Write([]byte("secret message")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case data := <-received:
+		assertEqual(t, string(data), "secret message")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the message")
+	}
+	// neither the key nor the message go over the wire in the clear
+	assertEqual(t, bytes.Contains(tee.written.Bytes(), testPSK), false)
+	assertEqual(t, bytes.Contains(tee.written.Bytes(), []byte("secret")), false)
+}
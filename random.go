@@ -0,0 +1,44 @@
+package eventedconnection
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// randomSource draws the random durations behind the reconnect and dial retry jitter
+// and the connection age jitter, and the order of SRV targets of equal priority, from
+// Config.RandSource, or from the global source if there is none
+type randomSource struct {
+	mutex sync.Mutex
+	rand  *rand.Rand // nil to use the global source
+}
+
+func newRandomSource(src rand.Source) *randomSource {
+	if src == nil {
+		return &randomSource{}
+	}
+	return &randomSource{rand: rand.New(src)}
+}
+
+// duration returns a random duration in [0, n); n must be positive
+func (r *randomSource) duration(n time.Duration) time.Duration {
+	if r.rand == nil {
+		return rand.N(n)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return time.Duration(r.rand.Int64N(int64(n)))
+}
+
+// intN returns a random int in [0, n); n must be positive
+func (r *randomSource) intN(n int) int {
+	if r.rand == nil {
+		return rand.IntN(n)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rand.IntN(n)
+}
@@ -0,0 +1,146 @@
+package eventedconnection
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitPolicy decides what Write does when Config.MaxWritesPerSecond or
+// Config.MaxBytesPerSecond would be exceeded
+type RateLimitPolicy int
+
+const (
+	// RateLimitBlock makes Write wait until the write is within the limits. It fails with
+	// ErrRateLimited if that would take longer than the write timeout.
+	RateLimitBlock RateLimitPolicy = iota
+	// RateLimitError makes Write fail with ErrRateLimited right away
+	RateLimitError
+)
+
+func (p RateLimitPolicy) String() string {
+	switch p {
+	case RateLimitBlock:
+		return "block"
+	case RateLimitError:
+		return "error"
+	}
+	return fmt.Sprintf("RateLimitPolicy(%d)", int(p))
+}
+
+// ParseRateLimitPolicy converts a policy name ("block" or "error", as returned by
+// RateLimitPolicy.String) into the corresponding RateLimitPolicy.
+func ParseRateLimitPolicy(name string) (RateLimitPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "block":
+		return RateLimitBlock, nil
+	case "error":
+		return RateLimitError, nil
+	}
+	return 0, fmt.Errorf("unknown rate limit policy %q", name)
+}
+
+// tokenBucket holds up to one second's worth of tokens, refilled continuously at rate
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: rate} // full, however long ago last was
+}
+
+// delay refills the bucket and returns how long to wait until n tokens are available.
+// A request larger than the bucket waits for a full bucket.
+func (b *tokenBucket) delay(now time.Time, n float64) time.Duration {
+	b.tokens = math.Min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	missing := math.Min(n, b.rate) - b.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / b.rate * float64(time.Second))
+}
+
+// rateLimiter enforces Config.MaxWritesPerSecond and Config.MaxBytesPerSecond
+type rateLimiter struct {
+	mutex  sync.Mutex
+	writes *tokenBucket // nil if unlimited
+	bytes  *tokenBucket // nil if unlimited
+	policy RateLimitPolicy
+}
+
+func newRateLimiter(conf *Config) *rateLimiter {
+	if conf.MaxWritesPerSecond <= 0 && conf.MaxBytesPerSecond <= 0 {
+		return nil
+	}
+
+	limiter := rateLimiter{policy: conf.RateLimitPolicy}
+	if conf.MaxWritesPerSecond > 0 {
+		limiter.writes = newTokenBucket(conf.MaxWritesPerSecond)
+	}
+	if conf.MaxBytesPerSecond > 0 {
+		limiter.bytes = newTokenBucket(float64(conf.MaxBytesPerSecond))
+	}
+	return &limiter
+}
+
+// reserve takes the tokens for writing size bytes and returns how long to wait before
+// writing. It takes nothing and fails with ErrRateLimited if the wait would be longer
+// than maxWait.
+func (l *rateLimiter) reserve(size int, maxWait time.Duration, now time.Time) (time.Duration, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	var wait time.Duration
+	if l.writes != nil {
+		wait = max(wait, l.writes.delay(now, 1))
+	}
+	if l.bytes != nil {
+		wait = max(wait, l.bytes.delay(now, float64(size)))
+	}
+	if wait > maxWait {
+		return 0, ErrRateLimited
+	}
+
+	// tokens may go negative, which delays the following writes
+	if l.writes != nil {
+		l.writes.tokens--
+	}
+	if l.bytes != nil {
+		l.bytes.tokens -= float64(size)
+	}
+	return wait, nil
+}
+
+// limitRate waits until a write of size bytes is within the configured rate limits,
+// for at most timeout. With block false, or the RateLimitError policy, it doesn't
+// wait at all.
+func (conn *Client) limitRate(size int, timeout time.Duration, block bool) error {
+	if conn.rateLimiter == nil {
+		return nil
+	}
+
+	var maxWait time.Duration
+	if block && conn.rateLimiter.policy == RateLimitBlock {
+		maxWait = timeout
+	}
+
+	wait, err := conn.rateLimiter.reserve(size, maxWait, conn.clock.Now())
+	if err != nil || wait == 0 {
+		return err
+	}
+
+	timer := conn.clock.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-conn.done:
+		return ErrShutdown
+	}
+}
@@ -0,0 +1,98 @@
+package eventedconnection_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func connectRateLimitedClient(t *testing.T, conf Config) (*Client, func()) {
+	t.Helper()
+
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Endpoint = l.Addr().String()
+	conf.ReadTimeout = 2 * time.Second
+	conf.WriteTimeout = time.Second
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	return con, func() {
+		con.Close()
+		close(done)
+	}
+}
+
+func TestClient_RateLimitError(t *testing.T) {
+	con, cleanup := connectRateLimitedClient(t, Config{MaxWritesPerSecond: 5, RateLimitPolicy: RateLimitError})
+	defer cleanup()
+
+	data := []byte("x")
+	for range 5 {
+		if err := con.Write(&data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(t, con.Write(&data), ErrRateLimited)
+	assertEqual(t, con.TryWrite(&data), ErrRateLimited)
+}
+
+func TestClient_RateLimitBlock(t *testing.T) {
+	con, cleanup := connectRateLimitedClient(t, Config{MaxWritesPerSecond: 20})
+	defer cleanup()
+
+	data := []byte("x")
+	start := time.Now()
+	for range 22 {
+		if err := con.Write(&data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the burst of 20 is free, the 2 writes after it wait 50ms each
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected writes to be delayed, took %s", elapsed)
+	}
+
+	// TryWrite doesn't wait
+	assertEqual(t, con.TryWrite(&data), ErrRateLimited)
+}
+
+func TestClient_RateLimitBytes(t *testing.T) {
+	con, cleanup := connectRateLimitedClient(t, Config{MaxBytesPerSecond: 10})
+	defer cleanup()
+
+	// a write larger than the bucket goes through once it is full
+	data := []byte(strings.Repeat("x", 20))
+	if err := con.Write(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	// but the next one would have to wait longer than its timeout
+	data = []byte("y")
+	assertEqual(t, con.WriteWithTimeout(&data, 100*time.Millisecond), ErrRateLimited)
+}
+
+func TestParseRateLimitPolicy(t *testing.T) {
+	for _, policy := range []RateLimitPolicy{RateLimitBlock, RateLimitError} {
+		parsed, err := ParseRateLimitPolicy(policy.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, parsed, policy)
+	}
+
+	_, err := ParseRateLimitPolicy("drop")
+	assertNotNil(t, err)
+}
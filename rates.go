@@ -0,0 +1,93 @@
+package eventedconnection
+
+import "time"
+
+// DefaultRateSampleInterval is the default interval between samples of the rolling
+// rates, see Config.RateWindow
+const DefaultRateSampleInterval = time.Second
+
+// Rates are the client's traffic and error rates over the last Config.RateWindow,
+// reported in Stats.Rates and passed to the OnRatesHook
+type Rates struct {
+	BytesReadPerSec       float64
+	BytesWrittenPerSec    float64
+	MessagesReadPerSec    float64 // messages delivered per second
+	MessagesWrittenPerSec float64
+	ErrorsPerMin          float64
+}
+
+// rateSample holds the counters the rates are computed from at one point in time
+type rateSample struct {
+	at              time.Time
+	bytesRead       uint64
+	bytesWritten    uint64
+	messagesRead    uint64
+	messagesWritten uint64
+	errors          uint64
+}
+
+// rateSampler keeps the samples covering the rate window. It is only used by the
+// sampling goroutine.
+type rateSampler struct {
+	interval time.Duration
+	window   time.Duration
+	samples  []rateSample // oldest first
+}
+
+func newRateSampler(conf *Config) *rateSampler {
+	r := &rateSampler{interval: conf.RateSampleInterval, window: conf.RateWindow}
+	if r.interval == 0 {
+		r.interval = min(DefaultRateSampleInterval, r.window)
+	}
+	return r
+}
+
+// add records sample and returns the rates between it and the oldest sample of the
+// window
+func (r *rateSampler) add(sample rateSample) Rates {
+	r.samples = append(r.samples, sample)
+	// keep the last sample taken at least a window ago, so the rates span the whole window
+	start := sample.at.Add(-r.window)
+	for len(r.samples) > 1 && !r.samples[1].at.After(start) {
+		r.samples = r.samples[1:]
+	}
+
+	oldest := r.samples[0]
+	elapsed := sample.at.Sub(oldest.at)
+	if elapsed <= 0 {
+		return Rates{}
+	}
+	perSecond := func(now, then uint64) float64 {
+		return float64(now-then) / elapsed.Seconds()
+	}
+	return Rates{
+		BytesReadPerSec:       perSecond(sample.bytesRead, oldest.bytesRead),
+		BytesWrittenPerSec:    perSecond(sample.bytesWritten, oldest.bytesWritten),
+		MessagesReadPerSec:    perSecond(sample.messagesRead, oldest.messagesRead),
+		MessagesWrittenPerSec: perSecond(sample.messagesWritten, oldest.messagesWritten),
+		ErrorsPerMin:          perSecond(sample.errors, oldest.errors) * 60,
+	}
+}
+
+// sampleRates updates the rolling rates every sample interval until the client is
+// shut down
+func (conn *Client) sampleRates(sampler *rateSampler) {
+	sampler.add(conn.stats.rateSample(conn.clock.Now()))
+	timer := conn.clock.NewTimer(sampler.interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+		case <-conn.done:
+			return
+		}
+
+		rates := sampler.add(conn.stats.rateSample(conn.clock.Now()))
+		conn.stats.recordRates(rates)
+		if conn.onRatesHook != nil {
+			conn.onRatesHook(rates)
+		}
+		timer.Reset(sampler.interval)
+	}
+}
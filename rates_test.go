@@ -0,0 +1,120 @@
+package eventedconnection_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+// waitForSampler waits for the rate sampler to take its first sample and start its timer
+func waitForSampler(t *testing.T, clock *testutils.FakeClock) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for clock.Timers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the rate sampler")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// advanceSample advances clock by d once the rate sampler is waiting and returns the
+// rates passed to the OnRatesHook
+func advanceSample(t *testing.T, clock *testutils.FakeClock, d time.Duration, samples <-chan Rates) Rates {
+	t.Helper()
+	waitForSampler(t, clock)
+	clock.Advance(d)
+	select {
+	case rates := <-samples:
+		return rates
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the OnRatesHook")
+	}
+	return Rates{}
+}
+
+func TestClient_Rates(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	clock := testutils.NewFakeClock(time.Now())
+	samples := make(chan Rates, 10)
+	conf := Config{
+		Endpoint:           l.Addr().String(),
+		Clock:              clock,
+		RateWindow:         10 * time.Second,
+		RateSampleInterval: time.Second,
+		OnRatesHook:        func(rates Rates) { samples <- rates },
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	waitForSampler(t, clock)
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if err = con.WriteString("hello"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = con.ReadWithTimeout(2 * time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rates := advanceSample(t, clock, time.Second, samples)
+	assertEqual(t, rates.BytesWrittenPerSec, 10.0)
+	assertEqual(t, rates.BytesReadPerSec, 10.0)
+	assertEqual(t, rates.MessagesWrittenPerSec, 2.0)
+	assertEqual(t, rates.MessagesReadPerSec, 2.0)
+	assertEqual(t, rates.ErrorsPerMin, 0.0)
+	assertEqual(t, con.GetStats().Rates, rates)
+
+	// the rates span the whole window once it has passed
+	for range 9 {
+		rates = advanceSample(t, clock, time.Second, samples)
+	}
+	assertEqual(t, rates.BytesWrittenPerSec, 1.0)
+	assertEqual(t, rates.MessagesWrittenPerSec, 0.2)
+
+	// and drop to zero once the traffic has left the window
+	rates = advanceSample(t, clock, time.Second, samples)
+	assertEqual(t, rates, Rates{})
+}
+
+func TestClient_RatesErrors(t *testing.T) {
+	clock := testutils.NewFakeClock(time.Now())
+	samples := make(chan Rates, 10)
+	conf := Config{
+		Endpoint:    "127.0.0.1:1",
+		Clock:       clock,
+		RateWindow:  time.Minute,
+		OnRatesHook: func(rates Rates) { samples <- rates },
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	waitForSampler(t, clock)
+
+	for range 3 {
+		if err = con.WriteString("hello"); err == nil {
+			t.Fatal("Expected writing without a connection to fail")
+		}
+	}
+
+	// the interval defaults to a second
+	rates := advanceSample(t, clock, time.Second, samples)
+	assertEqual(t, rates.ErrorsPerMin, 180.0)
+	assertEqual(t, con.GetStats().Errors, uint64(3))
+}
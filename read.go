@@ -0,0 +1,150 @@
+package eventedconnection
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"os"
+	"slices"
+	"time"
+)
+
+// ReadFull reads exactly len(buf) bytes from the connection into buf, assembling
+// them from as many messages on the Read channel as needed. Like Stream, it consumes
+// the Read channel and keeps the rest of a partially read message for the next call.
+//
+// If timeout is positive and expires first, ReadFull returns 0 and
+// os.ErrDeadlineExceeded and the bytes read so far are kept, so the call can simply
+// be retried. If the connection is closed it returns the number of bytes read and
+// io.EOF if none were, or io.ErrUnexpectedEOF otherwise.
+func (conn *Client) ReadFull(buf []byte, timeout time.Duration) (int, error) {
+	conn.readMutex.Lock()
+	defer conn.readMutex.Unlock()
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	n := 0
+	for n < len(buf) {
+		if len(conn.pending) == 0 {
+			message, err := conn.nextMessage(ctx)
+			if errors.Is(err, context.DeadlineExceeded) {
+				// put back what was read so a retry starts from the same place
+				conn.pending = slices.Clone(buf[:n])
+				return 0, os.ErrDeadlineExceeded
+			}
+			if err != nil {
+				if n == 0 {
+					return 0, io.EOF
+				}
+				return n, io.ErrUnexpectedEOF
+			}
+			conn.pending = message
+		}
+
+		copied := copy(buf[n:], conn.pending)
+		conn.pending = conn.pending[copied:]
+		n += copied
+	}
+
+	return n, nil
+}
+
+// ReadN reads exactly n bytes from the connection, see ReadFull. On error the
+// returned slice holds whatever was read before the connection was closed.
+func (conn *Client) ReadN(n int, timeout time.Duration) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := conn.ReadFull(buf, timeout)
+	return buf[:read], err
+}
+
+// ReadContext returns the next message from the Read channel, or the rest of a
+// message partially consumed by Stream or ReadFull. It returns ErrClosed
+// once the connection is closed and everything received has been read, or
+// ctx.Err() if ctx is done first.
+func (conn *Client) ReadContext(ctx context.Context) ([]byte, error) {
+	conn.readMutex.Lock()
+	defer conn.readMutex.Unlock()
+
+	if len(conn.pending) > 0 {
+		message := conn.pending
+		conn.pending = nil
+		return message, nil
+	}
+
+	message, err := conn.nextMessage(ctx)
+	if errors.Is(err, io.EOF) {
+		return nil, ErrClosed
+	}
+	return message, err
+}
+
+// ReadWithTimeout is like ReadContext but gives up with ErrReadTimeout after d.
+// A d of zero or less waits until a message arrives or the connection is closed.
+func (conn *Client) ReadWithTimeout(d time.Duration) ([]byte, error) {
+	ctx := context.Background()
+	if d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	message, err := conn.ReadContext(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, ErrReadTimeout
+	}
+	return message, err
+}
+
+// Messages returns an iterator over incoming messages (see ReadContext) for use with
+// for-range. The iteration ends when the connection is closed; if it was closed
+// because of an error other than io.EOF (the peer hanging up) or ErrShutdown, that
+// error is yielded as the last element first. Like Stream, it consumes the Read channel.
+//
+//	for message, err := range con.Messages() {
+//		if err != nil {
+//			log.Println("connection lost:", err)
+//			break
+//		}
+//		handle(message)
+//	}
+func (conn *Client) Messages() iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		for {
+			message, err := conn.ReadContext(context.Background())
+			if err != nil {
+				if cause := conn.Err(); cause != nil && !errors.Is(cause, io.EOF) && !errors.Is(cause, ErrShutdown) {
+					yield(nil, cause)
+				}
+				return
+			}
+			if !yield(message, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Subscribe returns a new channel that receives every subsequent message, after the
+// AfterReadHook and read middleware. Unlike receivers of the Read channel, which take
+// messages from one another, subscribers are independent: each gets every message.
+// Subscribers share the messages, so they must not modify them. Each channel has a
+// buffer of Config.ReadChannelSize and misses messages when full, which are counted in
+// Stats.SubscriberDrops; the read loop never waits for a subscriber. While there are
+// subscribers, messages aren't sent on the Read (or Data) channel, so it needn't be
+// consumed, unless an OnMessageHook is set, which keeps being called for every message.
+func (conn *Client) Subscribe() <-chan []byte {
+	ch, _ := conn.subscribers.subscribe(cap(conn.Read) + cap(conn.Data)) // only one of them is used
+	return ch
+}
+
+// Unsubscribe cancels a subscription made with Subscribe and closes its channel. It
+// does nothing if ch was already unsubscribed.
+func (conn *Client) Unsubscribe(ch <-chan []byte) {
+	conn.subscribers.unsubscribe(ch)
+}
@@ -0,0 +1,189 @@
+package eventedconnection_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_ReadN(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	message := []byte("\x00\x05hello")
+	if err := con.Write(&message); err != nil {
+		t.Fatal(err)
+	}
+
+	header, err := con.ReadN(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(header), "\x00\x05")
+
+	body := make([]byte, int(header[1]))
+	n, err := con.ReadFull(body, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, n, 5)
+	assertEqual(t, string(body), "hello")
+
+	// a timed out read keeps the partial data for the next attempt
+	first := []byte("ab")
+	if err = con.Write(&first); err != nil {
+		t.Fatal(err)
+	}
+	_, err = con.ReadN(4, 100*time.Millisecond)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded, got %v", err)
+	}
+
+	second := []byte("cd")
+	if err = con.Write(&second); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadN(4, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "abcd")
+
+	con.Close()
+	_, err = con.ReadN(1, time.Second)
+	assertEqual(t, err, io.EOF)
+}
+
+func TestClient_ReadWithTimeout(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	message := []byte("ping")
+	if err := con.Write(&message); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := con.ReadWithTimeout(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "ping")
+
+	_, err = con.ReadWithTimeout(100 * time.Millisecond)
+	assertEqual(t, err, ErrReadTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = con.ReadContext(ctx)
+	assertEqual(t, err, context.Canceled)
+
+	con.Close()
+	_, err = con.ReadWithTimeout(time.Second)
+	assertEqual(t, err, ErrClosed)
+}
+
+func TestClient_Messages(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	message := []byte("hello")
+	if err := con.Write(&message); err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for data, err := range con.Messages() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, string(data), "hello")
+		count++
+		con.Close()
+	}
+	assertEqual(t, count, 1)
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	first, second := con.Subscribe(), con.Subscribe()
+	if err := con.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ch := range []<-chan []byte{first, second} {
+		select {
+		case data := <-ch:
+			assertEqual(t, string(data), "hello")
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting for the subscription")
+		}
+	}
+	select {
+	case data := <-con.Read:
+		t.Fatalf("Expected no message on the Read channel while subscribed, got %q", *data)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	con.Unsubscribe(first)
+	con.Unsubscribe(first)
+	_, open := <-first
+	assertEqual(t, open, false)
+
+	con.Unsubscribe(second)
+	if err := con.WriteString("again"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "again")
+}
+
+func TestClient_SubscribeDrops(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:        l.Addr().String(),
+		ReadChannelSize: 1,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	sub := con.Subscribe()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// nobody reads the Read channel or the full subscription, yet the read loop goes on
+	for i, message := range []string{"one", "two", "three"} {
+		if err = con.WriteString(message); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(2 * time.Second)
+		for con.GetStats().MessagesDelivered < uint64(i+1) {
+			if time.Now().After(deadline) {
+				t.Fatalf("Test timed out while waiting for %q to be delivered", message)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	assertEqual(t, con.GetStats().SubscriberDrops, uint64(2))
+	assertEqual(t, string(<-sub), "one")
+}
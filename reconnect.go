@@ -0,0 +1,211 @@
+package eventedconnection
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// DefaultReconnectDelay is the default wait before the first automatic reconnect attempt
+const DefaultReconnectDelay = 500 * time.Millisecond
+
+// DefaultMaxReconnectDelay is the default upper bound of the wait between automatic reconnect attempts
+const DefaultMaxReconnectDelay = 30 * time.Second
+
+// DefaultDialRetryDelay is the default wait before the first retry of a failed dial, see Config.DialRetries
+const DefaultDialRetryDelay = 100 * time.Millisecond
+
+// ErrorClass tells temporary errors, after which reconnecting may succeed, apart
+// from fatal ones that will keep failing until something is changed.
+type ErrorClass int
+
+const (
+	// ErrorTemporary covers network failures such as timeouts, resets and refused
+	// connections, and the peer hanging up.
+	ErrorTemporary ErrorClass = iota
+	// ErrorFatal covers authentication and protocol failures such as a rejected
+	// certificate, and the client being shut down.
+	ErrorFatal
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorTemporary:
+		return "temporary"
+	case ErrorFatal:
+		return "fatal"
+	}
+	return fmt.Sprintf("ErrorClass(%d)", int(c))
+}
+
+// ErrorClassifier decides whether an error closing the connection, or failing a
+// reconnect, is worth retrying. See Config.ErrorClassifier.
+type ErrorClassifier func(err error) ErrorClass
+
+// ClassifyError is the default ErrorClassifier. TLS certificate and handshake
+// failures, ErrCertificatePinMismatch, ErrAuthenticationFailed, ErrPSKMismatch,
+// ErrDecryption, ErrChecksumMismatch, hook panics and ErrShutdown are fatal; any other
+// error is temporary.
+func ClassifyError(err error) ErrorClass {
+	var (
+		verificationErr *tls.CertificateVerificationError
+		alertErr        tls.AlertError
+		recordErr       tls.RecordHeaderError
+		authorityErr    x509.UnknownAuthorityError
+		invalidErr      x509.CertificateInvalidError
+		hostnameErr     x509.HostnameError
+		panicErr        *HookPanicError
+	)
+
+	switch {
+	case errors.Is(err, ErrShutdown),
+		errors.Is(err, ErrCertificatePinMismatch),
+		errors.Is(err, ErrAuthenticationFailed),
+		errors.Is(err, ErrPSKMismatch),
+		errors.Is(err, ErrDecryption),
+		errors.Is(err, ErrChecksumMismatch),
+		errors.As(err, &verificationErr),
+		errors.As(err, &alertErr),
+		errors.As(err, &recordErr),
+		errors.As(err, &authorityErr),
+		errors.As(err, &invalidErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &panicErr):
+		return ErrorFatal
+	}
+	return ErrorTemporary
+}
+
+// maybeAutoReconnect starts reconnecting in the background after the connection was
+// closed because of cause, if Config.AutoReconnect is set and no reconnect loop is
+// running yet. conn.mutex must be held.
+func (conn *Client) maybeAutoReconnect(cause error) {
+	if conn.autoReconnect {
+		conn.startReconnecting(cause)
+	}
+}
+
+// startReconnecting starts the reconnect loop in the background after cause closed the
+// connection or failed to replace it, unless it is running already. conn.mutex must
+// be held.
+func (conn *Client) startReconnecting(cause error) {
+	if cause == nil || conn.reconnecting || conn.isShutdown() {
+		return
+	}
+	conn.reconnecting = true
+	conn.routines.start(func() { conn.reconnectLoop(cause) })
+}
+
+// replaceConnection calls Reconnect, and keeps retrying with backoff like
+// Config.AutoReconnect (whether or not it is set) if that fails
+func (conn *Client) replaceConnection() {
+	if err := conn.Reconnect(); err != nil {
+		conn.mutex.Lock()
+		conn.startReconnecting(err)
+		conn.mutex.Unlock()
+	}
+}
+
+// reconnectLoop calls Reconnect with exponential backoff until it succeeds, fails
+// with a fatal error, the OnReconnectAttemptHook stops it, the reconnect budget is
+// spent or the client is shut down.
+func (conn *Client) reconnectLoop(cause error) {
+	delay := conn.reconnectDelay
+	start := conn.clock.Now()
+	for attempt := 1; conn.classifyError(cause) == ErrorTemporary; attempt++ {
+		wait := conn.jitter(delay)
+		if conn.reconnectExhausted(attempt, conn.since(start)+wait) {
+			conn.giveUpReconnecting(attempt-1, cause)
+			return
+		}
+
+		timer := conn.clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-conn.done:
+			timer.Stop()
+			conn.stopReconnecting()
+			return
+		}
+
+		if conn.onReconnectAttemptHook != nil {
+			err := conn.onReconnectAttemptHook(ReconnectAttempt{Attempt: attempt, Delay: wait, LastErr: cause})
+			if err != nil {
+				conn.handleError(err)
+				conn.logger.Warn("reconnecting stopped by hook", slog.Any("error", err))
+				conn.stopReconnecting()
+				return
+			}
+		}
+
+		if cause = conn.Reconnect(); cause == nil {
+			// the new connection may have failed before this loop was done with it
+			if cause = conn.lostWhileReconnecting(); cause == nil {
+				return
+			}
+			delay = conn.reconnectDelay
+			start = conn.clock.Now()
+			attempt = 0
+			continue
+		}
+		delay = min(2*delay, conn.maxReconnectDelay)
+		if conn.breaker != nil {
+			// no point in trying again before the circuit breaker lets a probe through
+			delay = max(delay, conn.breaker.retryIn(conn.clock.Now()))
+		}
+	}
+
+	if !conn.isShutdown() {
+		conn.logger.Warn("not reconnecting after fatal error", slog.Any("error", cause))
+	}
+	conn.stopReconnecting()
+}
+
+// reconnectExhausted reports whether the reconnect budget (Config.MaxReconnectAttempts
+// and Config.MaxReconnectDuration) rules out attempt, which would start at elapsed
+// since the connection was lost
+func (conn *Client) reconnectExhausted(attempt int, elapsed time.Duration) bool {
+	return (conn.maxReconnectAttempts > 0 && attempt > conn.maxReconnectAttempts) ||
+		(conn.maxReconnectDuration > 0 && elapsed > conn.maxReconnectDuration)
+}
+
+// giveUpReconnecting ends the reconnect loop once the reconnect budget is spent
+func (conn *Client) giveUpReconnecting(attempts int, cause error) {
+	conn.logger.Warn("giving up reconnecting", slog.Int("attempts", attempts), slog.Any("error", cause))
+	conn.stopReconnecting()
+	conn.emit(ReconnectExhaustedEvent{Origin: conn.origin(), Attempts: attempts, Err: cause})
+	if conn.onReconnectExhaustedHook != nil {
+		conn.onReconnectExhaustedHook(attempts, cause)
+	}
+}
+
+// lostWhileReconnecting returns the error that closed the connection if it was closed
+// again since it was established, or nil after marking the reconnect loop as done.
+func (conn *Client) lostWhileReconnecting() error {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	if conn.c == nil && conn.disconnectErr != nil && !conn.isShutdown() {
+		return conn.disconnectErr
+	}
+	conn.reconnecting = false
+	return nil
+}
+
+func (conn *Client) stopReconnecting() {
+	conn.mutex.Lock()
+	conn.reconnecting = false
+	conn.mutex.Unlock()
+}
+
+// jitter returns a random duration between half of d and d, so that many clients
+// losing their connections at once don't all reconnect at the same time.
+func (conn *Client) jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + conn.random.duration(d/2+1)
+}
@@ -0,0 +1,450 @@
+package eventedconnection_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+// hangUpServer hangs up on the first hangUps connections it accepts and echoes on
+// the rest. It returns the listener and a counter of accepted connections.
+func hangUpServer(t *testing.T, hangUps int32) (net.Listener, *atomic.Int32) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	var accepted atomic.Int32
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if accepted.Add(1) <= hangUps {
+				c.Close()
+				continue
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+
+	return l, &accepted
+}
+
+func TestClient_AutoReconnect(t *testing.T) {
+	l, accepted := hangUpServer(t, 1)
+
+	conf := Config{
+		Endpoint:       l.Addr().String(),
+		AutoReconnect:  true,
+		ReconnectDelay: 10 * time.Millisecond,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for con.GetStats().Reconnects == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the client to reconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assertEqual(t, accepted.Load(), int32(2))
+
+	payload := []byte("still there")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), string(payload))
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+}
+
+func TestClient_AutoReconnect_Fatal(t *testing.T) {
+	l, accepted := hangUpServer(t, 1)
+
+	conf := Config{
+		Endpoint:        l.Addr().String(),
+		AutoReconnect:   true,
+		ReconnectDelay:  10 * time.Millisecond,
+		ErrorClassifier: func(err error) ErrorClass { return ErrorFatal },
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	<-con.Disconnected
+
+	time.Sleep(100 * time.Millisecond)
+	assertEqual(t, accepted.Load(), int32(1))
+	assertEqual(t, con.GetStats().Reconnects, uint64(0))
+}
+
+// refusingServer hangs up on the first connection it accepts and stops listening, so
+// reconnects are refused
+func refusingServer(t *testing.T) net.Listener {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		c, err := l.Accept()
+		l.Close()
+		if err == nil {
+			c.Close()
+		}
+	}()
+	return l
+}
+
+func TestClient_OnReconnectAttemptHook(t *testing.T) {
+	l := refusingServer(t)
+
+	attempts := make(chan ReconnectAttempt, 10)
+	stop := errors.New("enough")
+	errs := make(chan error, 10)
+	conf := Config{
+		Endpoint:       l.Addr().String(),
+		AutoReconnect:  true,
+		ReconnectDelay: 10 * time.Millisecond,
+		OnReconnectAttemptHook: func(attempt ReconnectAttempt) error {
+			attempts <- attempt
+			if attempt.Attempt == 3 {
+				return stop
+			}
+			return nil
+		},
+		OnErrorHook: func(err error) error {
+			errs <- err
+			return err
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	var attempt ReconnectAttempt
+	for i := 1; i <= 3; i++ {
+		select {
+		case attempt = <-attempts:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Test timed out while waiting for attempt %d", i)
+		}
+		assertEqual(t, attempt.Attempt, i)
+		if attempt.LastErr == nil {
+			t.Fatalf("Expected attempt %d to carry the last error", i)
+		}
+	}
+	// the delay doubles after every failed attempt, with up to half of it as jitter
+	if attempt.Delay < 20*time.Millisecond || attempt.Delay > 40*time.Millisecond {
+		t.Fatalf("Expected the third attempt to wait 20-40ms, waited %v", attempt.Delay)
+	}
+
+	// the hook stopped reconnecting
+	deadline := time.After(2 * time.Second)
+	for err = nil; !errors.Is(err, stop); {
+		select {
+		case err = <-errs:
+		case <-deadline:
+			t.Fatal("Test timed out while waiting for the hook's error")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	assertEqual(t, len(attempts), 0)
+	assertEqual(t, con.IsActive(), false)
+}
+
+func TestClient_MaxReconnectAttempts(t *testing.T) {
+	l := refusingServer(t)
+
+	type exhaustion struct {
+		attempts int
+		err      error
+	}
+	exhausted := make(chan exhaustion, 1)
+	attempts := 0
+	conf := Config{
+		Endpoint:             l.Addr().String(),
+		AutoReconnect:        true,
+		ReconnectDelay:       10 * time.Millisecond,
+		MaxReconnectAttempts: 2,
+		OnReconnectAttemptHook: func(ReconnectAttempt) error {
+			attempts++
+			return nil
+		},
+		OnReconnectExhaustedHook: func(attempts int, lastErr error) {
+			exhausted <- exhaustion{attempts, lastErr}
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	events, cancel := con.SubscribeEvents()
+	defer cancel()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-exhausted:
+		assertEqual(t, e.attempts, 2)
+		assertNotNil(t, e.err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for reconnecting to be exhausted")
+	}
+	assertEqual(t, attempts, 2)
+	assertEqual(t, con.IsActive(), false)
+
+	for {
+		select {
+		case event := <-events:
+			if e, ok := event.(ReconnectExhaustedEvent); ok {
+				assertEqual(t, e.Attempts, 2)
+				return
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting for the ReconnectExhaustedEvent")
+		}
+	}
+}
+
+func TestClient_MaxReconnectDuration(t *testing.T) {
+	l := refusingServer(t)
+
+	exhausted := make(chan int, 1)
+	conf := Config{
+		Endpoint:             l.Addr().String(),
+		AutoReconnect:        true,
+		ReconnectDelay:       10 * time.Millisecond,
+		MaxReconnectDuration: 100 * time.Millisecond,
+		OnReconnectExhaustedHook: func(attempts int, lastErr error) {
+			exhausted <- attempts
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	start := time.Now()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case attempts := <-exhausted:
+		// waits of 5-10ms, 10-20ms, 20-40ms and 40-80ms fit in 100ms at most
+		if attempts < 2 || attempts > 4 {
+			t.Fatalf("Expected 2 to 4 attempts, got %d", attempts)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("Expected reconnecting to give up after about 100ms, took %v", elapsed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for reconnecting to be exhausted")
+	}
+}
+
+func TestClient_RandSource(t *testing.T) {
+	// reconnectDelays returns the delays waited before the reconnect attempts of a
+	// client with a seeded RandSource
+	reconnectDelays := func(seed uint64) []time.Duration {
+		l := refusingServer(t)
+
+		var delays []time.Duration
+		exhausted := make(chan struct{})
+		conf := Config{
+			Endpoint:             l.Addr().String(),
+			AutoReconnect:        true,
+			ReconnectDelay:       10 * time.Millisecond,
+			MaxReconnectAttempts: 4,
+			RandSource:           rand.NewPCG(seed, seed),
+			OnReconnectAttemptHook: func(attempt ReconnectAttempt) error {
+				delays = append(delays, attempt.Delay)
+				return nil
+			},
+			OnReconnectExhaustedHook: func(int, error) { close(exhausted) },
+		}
+		con, err := NewClient(&conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer con.Shutdown()
+
+		if err = con.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-exhausted:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting for reconnecting to be exhausted")
+		}
+		return delays
+	}
+
+	first, second := reconnectDelays(42), reconnectDelays(42)
+	assertEqual(t, len(first), 4)
+	assertEqual(t, slices.Equal(first, second), true)
+
+	other := reconnectDelays(7)
+	assertEqual(t, slices.Equal(first, other), false)
+}
+
+func TestClient_RandSourceSRV(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "a.test.", Port: 1, Priority: 10, Weight: 10},
+		{Target: "b.test.", Port: 2, Priority: 10, Weight: 20},
+		{Target: "c.test.", Port: 3, Priority: 10, Weight: 30},
+		{Target: "d.test.", Port: 4, Priority: 10, Weight: 40},
+		{Target: "e.test.", Port: 5, Priority: 20, Weight: 0},
+	}
+
+	// srvOrder returns the endpoints tried by a few connects of a client with a
+	// seeded RandSource, in order
+	srvOrder := func(seed uint64) []string {
+		var tried []string
+		conf := Config{
+			SRVName:    "evented-connection.test",
+			Resolver:   testutils.SRVResolver(records),
+			RandSource: rand.NewPCG(seed, seed),
+			BeforeConnectHook: func(params *DialParams) error {
+				tried = append(tried, params.Endpoint)
+				return errors.New("skipped")
+			},
+			OnErrorHook: func(err error) error { return err },
+		}
+		con, err := NewClient(&conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer con.Shutdown()
+
+		if err = con.Connect(); err == nil {
+			t.Fatal("Expected every endpoint to be skipped")
+		}
+		for range 3 {
+			if err = con.Reconnect(); err == nil {
+				t.Fatal("Expected every endpoint to be skipped")
+			}
+		}
+		// the lower priority target is only tried after the others
+		for i := len(records) - 1; i < len(tried); i += len(records) {
+			assertEqual(t, tried[i], "e.test:5")
+		}
+		return tried
+	}
+
+	first, second := srvOrder(42), srvOrder(42)
+	assertEqual(t, len(first), 4*len(records))
+	assertEqual(t, slices.Equal(first, second), true)
+
+	other := srvOrder(7)
+	assertEqual(t, slices.Equal(first, other), false)
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{io.EOF, ErrorTemporary},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrorTemporary},
+		{ErrShutdown, ErrorFatal},
+		{ErrChecksumMismatch, ErrorFatal},
+		{tls.AlertError(42), ErrorFatal},
+		{&net.OpError{Op: "remote error", Err: tls.AlertError(48)}, ErrorFatal},
+	}
+	for _, tt := range tests {
+		assertEqual(t, ClassifyError(tt.err), tt.want)
+	}
+	assertEqual(t, ErrorFatal.String(), "fatal")
+}
+
+func TestClient_DialRetries(t *testing.T) {
+	l, _ := hangUpServer(t, 0)
+
+	errDown := errors.New("endpoint down")
+	tests := []struct {
+		name      string
+		failures  int
+		failWith  error
+		retries   int
+		budget    time.Duration
+		wantErr   error
+		wantDials int
+	}{
+		{"succeeds after retries", 2, errDown, 3, 0, nil, 3},
+		{"runs out of retries", 5, errDown, 2, 0, errDown, 3},
+		{"runs out of budget", 5, errDown, 10, 25 * time.Millisecond, errDown, 2},
+		{"fatal errors aren't retried", 5, ErrCertificatePinMismatch, 3, 0, ErrCertificatePinMismatch, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dials := 0
+			conf := Config{
+				Endpoint: l.Addr().String(),
+				BeforeConnectHook: func(params *DialParams) error {
+					dials++
+					if dials <= tt.failures {
+						return tt.failWith
+					}
+					return nil
+				},
+				DialRetries:     tt.retries,
+				DialRetryDelay:  20 * time.Millisecond,
+				DialRetryBudget: tt.budget,
+			}
+			con, err := NewClient(&conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer con.Shutdown()
+
+			assertEqual(t, con.Connect(), tt.wantErr)
+			assertEqual(t, dials, tt.wantDials)
+		})
+	}
+}
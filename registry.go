@@ -0,0 +1,65 @@
+package eventedconnection
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// hookRegistry holds the hooks registered with RegisterHook, by name
+var hookRegistry = struct {
+	sync.RWMutex
+	hooks map[string]any
+}{hooks: map[string]any{}}
+
+// RegisterHook makes hook available under name to configurations loaded with
+// Config.Unmarshal, which reference hooks in their "hooks" object by the json name
+// of the Config field, e.g. {"hooks": {"afterReadHook": "uppercase"}}. hook must be a
+// function with the signature of the hook type it is used for; the type is checked
+// when a configuration references it. Names must be unique.
+func RegisterHook(name string, hook any) error {
+	if hook == nil || reflect.TypeOf(hook).Kind() != reflect.Func {
+		return fmt.Errorf("hook %q is not a function", name)
+	}
+
+	hookRegistry.Lock()
+	defer hookRegistry.Unlock()
+
+	if _, ok := hookRegistry.hooks[name]; ok {
+		return fmt.Errorf("hook %q is already registered", name)
+	}
+	hookRegistry.hooks[name] = hook
+	return nil
+}
+
+// setNamedHooks sets the hook fields named by the keys of hooks (e.g. "afterReadHook"
+// for AfterReadHook) to the registered hooks named by the values.
+func (conf *Config) setNamedHooks(hooks map[string]string) error {
+	hookRegistry.RLock()
+	defer hookRegistry.RUnlock()
+
+	config := reflect.ValueOf(conf).Elem()
+	for key, name := range hooks {
+		if !strings.HasSuffix(key, "Hook") {
+			return fmt.Errorf("unknown hook %q", key)
+		}
+		field := config.FieldByName(strings.ToUpper(key[:1]) + key[1:])
+		if !field.IsValid() || field.Kind() != reflect.Func {
+			return fmt.Errorf("unknown hook %q", key)
+		}
+
+		hook, ok := hookRegistry.hooks[name]
+		if !ok {
+			return fmt.Errorf("hook %q is not registered", name)
+		}
+
+		value := reflect.ValueOf(hook)
+		if !value.Type().ConvertibleTo(field.Type()) {
+			return fmt.Errorf("hook %q (%s) cannot be used as %s", name, value.Type(), field.Type().Name())
+		}
+		field.Set(value.Convert(field.Type()))
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package eventedconnection_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/joedursun/EventedConnection"
+)
+
+func TestRegisterHook(t *testing.T) {
+	err := RegisterHook("test.uppercase", func(data []byte) ([]byte, error) {
+		return bytes.ToUpper(data), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNotNil(t, RegisterHook("test.uppercase", func() error { return nil }))
+	assertNotNil(t, RegisterHook("test.invalid", "not a function"))
+
+	conf := NewConfig()
+	err = conf.Unmarshal(strings.NewReader(`{
+		"endpoint": "localhost:8080",
+		"connectionTimeout": "1s", "readTimeout": "1s", "writeTimeout": "1s",
+		"hooks": {"afterReadHook": "test.uppercase", "beforeWriteHook": "test.uppercase"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	processed, err := conf.AfterReadHook([]byte("hello"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(processed), "HELLO")
+	assertNotNil(t, conf.BeforeWriteHook)
+
+	for _, hooks := range []string{
+		`{"afterReadHook": "test.missing"}`,
+		`{"afterConnectHook": "test.uppercase"}`,
+		`{"endpoint": "test.uppercase"}`,
+	} {
+		conf = NewConfig()
+		err = conf.Unmarshal(strings.NewReader(`{"endpoint": "localhost:8080", "hooks": ` + hooks + `}`))
+		assertNotNil(t, err)
+	}
+}
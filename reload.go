@@ -0,0 +1,205 @@
+package eventedconnection
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LoadConfigFile reads a config from a JSON file, or a TOML file if the name ends in
+// ".toml", on top of the defaults of NewConfig.
+func LoadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := NewConfig()
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = conf.DecodeTOML(f)
+	} else {
+		err = conf.Unmarshal(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return conf, nil
+}
+
+// reconnectSettings are the settings that ApplyConfig can only apply by reconnecting
+type reconnectSettings struct {
+	Endpoint           string
+	CertFile           string
+	KeyFile            string
+	CAFile             string
+	PinnedPublicKeys   []string
+	PinnedCertificates []string
+	NextProtos         []string
+	TLSMinVersion      uint16
+	TLSMaxVersion      uint16
+	TLSCipherSuites    []uint16
+}
+
+func newReconnectSettings(conf *Config) reconnectSettings {
+	return reconnectSettings{
+		Endpoint:           conf.Endpoint,
+		CertFile:           conf.CertFile,
+		KeyFile:            conf.KeyFile,
+		CAFile:             conf.CAFile,
+		PinnedPublicKeys:   conf.PinnedPublicKeys,
+		PinnedCertificates: conf.PinnedCertificates,
+		NextProtos:         conf.NextProtos,
+		TLSMinVersion:      conf.TLSMinVersion,
+		TLSMaxVersion:      conf.TLSMaxVersion,
+		TLSCipherSuites:    conf.TLSCipherSuites,
+	}
+}
+
+// ApplyConfig applies the settings of conf that can change while the client is running:
+//
+//   - ConnectionTimeout, ReadTimeout, WriteTimeout and ReadBufferSize take effect
+//     immediately, as with SetReadTimeout and friends.
+//   - Endpoint and the TLS files and policy (CertFile, KeyFile, CAFile, pins,
+//     NextProtos, TLS versions and cipher suites) are applied by reconnecting, and
+//     only if they changed.
+//
+// Changing UseTLS or the SRV settings requires a new client, so ApplyConfig fails
+// without applying anything if they differ. All other fields, such as hooks, are ignored.
+func (conn *Client) ApplyConfig(conf *Config) error {
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	conn.settingsMutex.Lock()
+	current := conn.config
+	if conf.UseTLS != current.UseTLS || conf.SRVService != current.SRVService ||
+		conf.SRVProto != current.SRVProto || conf.SRVName != current.SRVName {
+		conn.settingsMutex.Unlock()
+		return errors.New("UseTLS and the SRV settings cannot be changed without creating a new client")
+	}
+
+	// start from the running config so the TLSConfig, hooks etc. it was created with are kept
+	updated := current
+	updated.Labels = maps.Clone(current.Labels)
+	updated.ConnectionTimeout = conf.ConnectionTimeout
+	updated.ReadTimeout = conf.ReadTimeout
+	updated.WriteTimeout = conf.WriteTimeout
+	updated.ReadBufferSize = conf.ReadBufferSize
+
+	conn.connectionTimeout = orDefault(conf.ConnectionTimeout, DefaultConnectionTimeout)
+	conn.readTimeout = orDefault(conf.ReadTimeout, DefaultReadTimeout)
+	conn.writeTimeout = orDefault(conf.WriteTimeout, DefaultWriteTimeout)
+	conn.readBufferSize = orDefault(conf.ReadBufferSize, DefaultReadBufferSize)
+
+	changed := !reflect.DeepEqual(newReconnectSettings(conf), newReconnectSettings(&current))
+	if changed {
+		updated.Endpoint = conf.Endpoint
+		updated.CertFile = conf.CertFile
+		updated.KeyFile = conf.KeyFile
+		updated.CAFile = conf.CAFile
+		updated.PinnedPublicKeys = conf.PinnedPublicKeys
+		updated.PinnedCertificates = conf.PinnedCertificates
+		updated.NextProtos = conf.NextProtos
+		updated.TLSMinVersion = conf.TLSMinVersion
+		updated.TLSMaxVersion = conf.TLSMaxVersion
+		updated.TLSCipherSuites = conf.TLSCipherSuites
+
+		conn.endpoint = updated.Endpoint
+		conn.setTLSSettings(&updated)
+	}
+	conn.config = updated
+	conn.settingsMutex.Unlock()
+
+	if changed && conn.IsActive() {
+		conn.logger.Info("configuration changed, reconnecting")
+		return conn.Reconnect()
+	}
+	return nil
+}
+
+// WatchConfigFile reloads the config file at path (see LoadConfigFile) and applies it
+// with ApplyConfig whenever the file's modification time changes, checked every
+// interval, and whenever one of signals (e.g. syscall.SIGHUP) is received. An interval
+// of zero or less disables the checks, so only the signals trigger a reload. Errors
+// are passed to the OnErrorHook and leave the running configuration in place.
+// Watching stops when the returned function is called or the client is shut down.
+func (conn *Client) WatchConfigFile(path string, interval time.Duration, signals ...os.Signal) (stop func()) {
+	modTime := func() time.Time {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		return info.ModTime()
+	}
+
+	reload := func() {
+		conf, err := LoadConfigFile(path)
+		if err == nil {
+			err = conn.ApplyConfig(conf)
+		}
+		if err != nil {
+			conn.handleError(err)
+			return
+		}
+		conn.logger.Info("configuration reloaded", slog.String("path", path))
+	}
+
+	stopped := make(chan struct{})
+	signalled := make(chan os.Signal, 1)
+	if len(signals) > 0 {
+		signal.Notify(signalled, signals...)
+	}
+
+	lastModTime := modTime()
+	conn.clientRoutines.start(func() {
+		defer signal.Stop(signalled)
+
+		var timer Timer
+		var checks <-chan time.Time // nil, i.e. never ready, without an interval
+		if interval > 0 {
+			timer = conn.clock.NewTimer(interval)
+			defer timer.Stop()
+			checks = timer.C()
+		}
+
+		for {
+			select {
+			case <-checks:
+				if current := modTime(); !current.Equal(lastModTime) {
+					lastModTime = current
+					reload()
+				}
+				timer.Reset(interval)
+			case <-signalled:
+				lastModTime = modTime()
+				reload()
+			case <-stopped:
+				return
+			case <-conn.Done():
+				return
+			}
+		}
+	})
+
+	var once sync.Once
+	return func() { once.Do(func() { close(stopped) }) }
+}
+
+// orDefault returns value, or fallback if value is zero
+func orDefault[T comparable](value, fallback T) T {
+	var zero T
+	if value == zero {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,287 @@
+package eventedconnection_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_ApplyConfig(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	_, ok := nextEvent(t, con).(ConnectedEvent)
+	assertEqual(t, ok, true)
+
+	// timeouts apply without reconnecting
+	updated := conf
+	updated.ReadTimeout = 2 * time.Second
+	updated.WriteTimeout = 0
+	if err = con.ApplyConfig(&updated); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.GetReadTimeout(), 2*time.Second)
+	assertEqual(t, con.GetWriteTimeout(), DefaultWriteTimeout)
+	assertEqual(t, con.EffectiveConfig().ReadTimeout, 2*time.Second)
+	select {
+	case event := <-con.Events:
+		t.Errorf("Expected no reconnect, got %T", event)
+	default:
+	}
+
+	updated.UseTLS = true
+	assertNotNil(t, con.ApplyConfig(&updated))
+}
+
+func TestClient_WatchConfigFile(t *testing.T) {
+	done := make(chan bool)
+	first, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	path := filepath.Join(t.TempDir(), "client.json")
+	writeConfig := func(endpoint string, modTime time.Time) {
+		data := fmt.Sprintf(`{"endpoint": %q, "connectionTimeout": "1s", "readTimeout": "1s", "writeTimeout": "1s"}`, endpoint)
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(first.Addr().String(), time.Now().Add(-time.Hour))
+
+	conf, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Logger = nil
+
+	con, err := NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	_, ok := nextEvent(t, con).(ConnectedEvent)
+	assertEqual(t, ok, true)
+
+	stop := con.WatchConfigFile(path, 10*time.Millisecond)
+	defer stop()
+
+	writeConfig(second.Addr().String(), time.Now())
+	_, ok = nextEvent(t, con).(ReconnectingEvent)
+	assertEqual(t, ok, true)
+	_, ok = nextEvent(t, con).(DisconnectedEvent)
+	assertEqual(t, ok, true)
+	_, ok = nextEvent(t, con).(ConnectedEvent)
+	assertEqual(t, ok, true)
+	assertEqual(t, con.GetEndpoint(), second.Addr().String())
+	assertEqual(t, con.IsActive(), true)
+}
+
+func TestClient_WatchConfigFileClock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.json")
+	writeConfig := func(endpoint string) {
+		data := fmt.Sprintf(`{"endpoint": %q, "connectionTimeout": "1s", "readTimeout": "1s", "writeTimeout": "1s"}`, endpoint)
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("localhost:5555")
+
+	conf, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := testutils.NewFakeClock(time.Now())
+	conf.Clock = clock
+	conf.Logger = nil
+
+	con, err := NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	stop := con.WatchConfigFile(path, time.Minute)
+	defer stop()
+	writeConfig("localhost:6666")
+	modTime := time.Now().Add(time.Hour)
+	if err = os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is only checked once the client's clock says the interval has passed
+	deadline := time.Now().Add(2 * time.Second)
+	for clock.Timers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the watcher to start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	assertEqual(t, con.GetEndpoint(), "localhost:5555")
+	clock.Advance(time.Minute)
+
+	for con.GetEndpoint() != "localhost:6666" {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the config file to be reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClient_WatchConfigFileSignalsOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.json")
+	writeConfig := func(endpoint string) {
+		data := fmt.Sprintf(`{"endpoint": %q, "connectionTimeout": "1s", "readTimeout": "1s", "writeTimeout": "1s"}`, endpoint)
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig("localhost:5555")
+
+	conf, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := testutils.NewFakeClock(time.Now())
+	conf.Clock = clock
+	conf.Logger = nil
+
+	con, err := NewClient(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	stop := con.WatchConfigFile(path, 0, syscall.SIGHUP)
+	defer stop()
+	writeConfig("localhost:6666")
+	modTime := time.Now().Add(time.Hour)
+	if err = os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// without an interval no timer is started and the file is only read when signalled
+	time.Sleep(50 * time.Millisecond)
+	assertEqual(t, clock.Timers(), 0)
+	assertEqual(t, con.GetEndpoint(), "localhost:5555")
+
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = process.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("can't signal the test process: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for con.GetEndpoint() != "localhost:6666" {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the config file to be reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClient_ApplyConfigKeepsCertExpirySettings(t *testing.T) {
+	done := make(chan bool)
+	first, err := testutils.TLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := testutils.TLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:  first.Addr().String(),
+		UseTLS:    true,
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	updated := conf
+	updated.Endpoint = second.Addr().String()
+	if err = con.ApplyConfig(&updated); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.EffectiveConfig().CertExpiryWarning, DefaultCertExpiryWarning)
+
+	// a panicking hook is still recovered after a reload
+	errs := make(chan error, 10)
+	conf.CertExpiryWarning = 100 * 365 * 24 * time.Hour
+	conf.CertExpiryHook = func(*x509.Certificate, time.Duration) error { panic("expiring") }
+	conf.OnErrorHook = func(err error) error {
+		errs <- err
+		return err
+	}
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	updated = conf
+	updated.Endpoint = second.Addr().String()
+	if err = con.ApplyConfig(&updated); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.EffectiveConfig().CertExpiryWarning, conf.CertExpiryWarning)
+	for recovered := 0; recovered < 2; {
+		select {
+		case err := <-errs:
+			var panicErr *HookPanicError
+			if errors.As(err, &panicErr) && panicErr.Hook == "CertExpiryHook" {
+				recovered++
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting for the recovered CertExpiryHook panics")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package eventedconnection
+
+import (
+	"slices"
+	"sync"
+)
+
+// replayBuffer keeps copies of the last messages delivered, see Config.ReplayBufferSize
+type replayBuffer struct {
+	mutex    sync.Mutex
+	messages [][]byte // ring of the last len(messages) messages
+	next     int      // index the next message is stored at
+	count    int      // messages stored, up to len(messages)
+}
+
+func newReplayBuffer(size int) *replayBuffer {
+	return &replayBuffer{messages: make([][]byte, size)}
+}
+
+// add stores a copy of message, evicting the oldest one once the buffer is full
+func (b *replayBuffer) add(message []byte) {
+	message = slices.Clone(message)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.messages[b.next] = message
+	b.next = (b.next + 1) % len(b.messages)
+	b.count = min(b.count+1, len(b.messages))
+}
+
+// snapshot returns copies of the stored messages, oldest first
+func (b *replayBuffer) snapshot() [][]byte {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	messages := make([][]byte, 0, b.count)
+	start := b.next - b.count
+	if start < 0 {
+		start += len(b.messages)
+	}
+	for i := range b.count {
+		messages = append(messages, slices.Clone(b.messages[(start+i)%len(b.messages)]))
+	}
+	return messages
+}
+
+// Replay returns copies of the last Config.ReplayBufferSize messages delivered, oldest
+// first, so a consumer that restarted, or a caller after Reconnect, can catch up on
+// context it missed such as a banner sent once per connection. The messages survive
+// reconnects. Replay returns nil unless Config.ReplayBufferSize is set.
+func (conn *Client) Replay() [][]byte {
+	if conn.replay == nil {
+		return nil
+	}
+	return conn.replay.snapshot()
+}
@@ -0,0 +1,48 @@
+package eventedconnection_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_Replay(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String(), ReplayBufferSize: 2}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	assertEqual(t, len(con.Replay()), 0)
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range []string{"one", "two", "three"} {
+		if err = con.WriteString(message); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case data := <-con.Read:
+			assertEqual(t, string(*data), message)
+			(*data)[0] = 'x' // the replay buffer keeps its own copy
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting to read from connection")
+		}
+	}
+
+	replayed := con.Replay()
+	assertEqual(t, len(replayed), 2)
+	assertEqual(t, string(replayed[0]), "two")
+	assertEqual(t, string(replayed[1]), "three")
+}
@@ -0,0 +1,131 @@
+package eventedconnection
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// RingBuffer is the zero-copy read path enabled by Config.RingBufferSize. The read
+// loop reads from the connection straight into the ring and consumers get slices of
+// it from Next, which stay valid until they are acknowledged with Ack; only then is
+// their space reused. Nothing is copied along the way, at the cost of bypassing the
+// AfterReadHook, ReadMiddleware and the Read channel. A RingBuffer is meant for a
+// single consumer.
+type RingBuffer struct {
+	conn   *Client
+	mutex  sync.Mutex
+	buffer []byte
+	read   uint64 // total bytes acknowledged
+	write  uint64 // total bytes read from the connection
+
+	dataReady  chan struct{} // signalled when data is committed
+	spaceReady chan struct{} // signalled when data is acknowledged
+}
+
+func newRingBuffer(conn *Client, size int) *RingBuffer {
+	return &RingBuffer{
+		conn:       conn,
+		buffer:     make([]byte, size),
+		dataReady:  make(chan struct{}, 1),
+		spaceReady: make(chan struct{}, 1),
+	}
+}
+
+// RingBuffer returns the client's ring buffer, or nil unless Config.RingBufferSize is set
+func (conn *Client) RingBuffer() *RingBuffer {
+	return conn.ring
+}
+
+// Next waits for data and returns the oldest unacknowledged bytes that are contiguous
+// in the ring, which may be fewer than Len when they wrap around its end. The slice
+// references the ring: it must not be modified and is only valid until acknowledged.
+// Next returns io.EOF once the connection is closed and everything has been
+// acknowledged, or ctx.Err() if ctx is done first.
+func (r *RingBuffer) Next(ctx context.Context) ([]byte, error) {
+	for {
+		if data := r.readable(); len(data) > 0 {
+			return data, nil
+		}
+
+		select {
+		case <-r.dataReady:
+		case <-r.conn.disconnected():
+			if data := r.readable(); len(data) > 0 {
+				return data, nil
+			}
+			return nil, io.EOF
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Ack acknowledges the first n unacknowledged bytes, letting the read loop reuse
+// their space. Slices returned by Next covering them must not be used afterwards.
+func (r *RingBuffer) Ack(n int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if n < 0 || uint64(n) > r.write-r.read {
+		return fmt.Errorf("cannot acknowledge %d of %d buffered bytes", n, r.write-r.read)
+	}
+	r.read += uint64(n)
+	notify(r.spaceReady)
+	return nil
+}
+
+// Len returns the number of unacknowledged bytes in the ring
+func (r *RingBuffer) Len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return int(r.write - r.read)
+}
+
+// readable returns the oldest contiguous unacknowledged bytes
+func (r *RingBuffer) readable() []byte {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	start := int(r.read % uint64(len(r.buffer)))
+	end := min(len(r.buffer), start+int(r.write-r.read))
+	return r.buffer[start:end]
+}
+
+// reserve waits for free space and returns the contiguous free region to read into.
+// It returns nil if stop is closed first.
+func (r *RingBuffer) reserve(stop <-chan struct{}) []byte {
+	for {
+		r.mutex.Lock()
+		free := len(r.buffer) - int(r.write-r.read)
+		start := int(r.write % uint64(len(r.buffer)))
+		r.mutex.Unlock()
+
+		if free > 0 {
+			return r.buffer[start:min(len(r.buffer), start+free)]
+		}
+
+		select {
+		case <-r.spaceReady:
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// commit makes n bytes read into the region returned by reserve available to Next
+func (r *RingBuffer) commit(n int) {
+	r.mutex.Lock()
+	r.write += uint64(n)
+	r.mutex.Unlock()
+	notify(r.dataReady)
+}
+
+// notify wakes up a waiter on c without blocking
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,86 @@
+package eventedconnection_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_RingBuffer(t *testing.T) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{
+		Endpoint:       l.Addr().String(),
+		ReadTimeout:    2 * time.Second,
+		WriteTimeout:   time.Second,
+		RingBufferSize: 16,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	ring := con.RingBuffer()
+	assertNotNil(t, ring)
+
+	// more than the ring holds, so the read loop has to wait for acknowledgements
+	// and the data wraps around the end of the ring
+	payload := []byte(strings.Repeat("0123456789", 5))
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var received []byte
+	for len(received) < len(payload) {
+		data, err := ring.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 16 {
+			t.Fatalf("Expected at most 16 bytes, got %d", len(data))
+		}
+		received = append(received, data...)
+		if err = ring.Ack(len(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(t, string(received), string(payload))
+	assertEqual(t, ring.Len(), 0)
+	assertNotNil(t, ring.Ack(1))
+
+	con.Close()
+	_, err = ring.Next(ctx)
+	assertEqual(t, err, io.EOF)
+}
+
+func TestClient_RingBufferDisabled(t *testing.T) {
+	conf := Config{Endpoint: "localhost:5555"}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if con.RingBuffer() != nil {
+		t.Error("Expected no ring buffer")
+	}
+
+	conf.RingBufferSize = 1024
+	conf.PooledReads = true
+	_, err = NewClient(&conf)
+	assertNotNil(t, err)
+}
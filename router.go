@@ -0,0 +1,80 @@
+package eventedconnection
+
+import (
+	"bytes"
+	"regexp"
+	"sync"
+)
+
+// Handler handles a single message, e.g. one delivered by the dispatcher (see
+// Config.OnMessageHook) or a Router.
+type Handler func(data []byte) error
+
+// Matcher reports whether a message should be handled by a route
+type Matcher func(data []byte) bool
+
+// Router dispatches messages to handlers by matching them against routes in the
+// order the routes were added, for protocols multiplexing several message types over
+// one connection. Messages matching no route go to the fallback handler. Use
+// Router.Dispatch as the Config.OnMessageHook, or call it with messages read from
+// the Read channel. A Router is safe for concurrent use.
+type Router struct {
+	mutex    sync.RWMutex
+	routes   []route
+	fallback Handler
+}
+
+type route struct {
+	match   Matcher
+	handler Handler
+}
+
+// NewRouter returns an empty Router
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle routes messages for which match returns true to handler
+func (r *Router) Handle(match Matcher, handler Handler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.routes = append(r.routes, route{match: match, handler: handler})
+}
+
+// HandlePrefix routes messages starting with prefix to handler
+func (r *Router) HandlePrefix(prefix []byte, handler Handler) {
+	prefix = bytes.Clone(prefix)
+	r.Handle(func(data []byte) bool { return bytes.HasPrefix(data, prefix) }, handler)
+}
+
+// HandleRegexp routes messages matching re to handler
+func (r *Router) HandleRegexp(re *regexp.Regexp, handler Handler) {
+	r.Handle(re.Match, handler)
+}
+
+// Fallback sets the handler for messages matching no route. Without one, Dispatch
+// returns ErrNoRoute for them.
+func (r *Router) Fallback(handler Handler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.fallback = handler
+}
+
+// Dispatch passes data to the handler of the first matching route, or to the
+// fallback handler, and returns the handler's error.
+func (r *Router) Dispatch(data []byte) error {
+	r.mutex.RLock()
+	handler := r.fallback
+	for _, route := range r.routes {
+		if route.match(data) {
+			handler = route.handler
+			break
+		}
+	}
+	r.mutex.RUnlock()
+
+	if handler == nil {
+		return ErrNoRoute
+	}
+	return handler(data)
+}
@@ -0,0 +1,53 @@
+package eventedconnection_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	. "github.com/joedursun/EventedConnection"
+)
+
+func TestRouter_Dispatch(t *testing.T) {
+	router := NewRouter()
+
+	var handled []string
+	record := func(name string) Handler {
+		return func(data []byte) error {
+			handled = append(handled, name+":"+string(data))
+			return nil
+		}
+	}
+
+	router.HandlePrefix([]byte("TEMP"), record("temp"))
+	router.HandleRegexp(regexp.MustCompile(`^ACK \d+$`), record("ack"))
+	router.Handle(func(data []byte) bool { return len(data) > 0 && data[0] == 0x02 }, record("binary"))
+
+	for _, message := range []string{"TEMP 21.5", "ACK 42", "\x02\x01", "ACK x"} {
+		err := router.Dispatch([]byte(message))
+		if message == "ACK x" {
+			assertEqual(t, errors.Is(err, ErrNoRoute), true)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router.Fallback(record("fallback"))
+	if err := router.Dispatch([]byte("ACK x")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first matching route wins
+	router.HandlePrefix([]byte("TEMP 2"), record("unreachable"))
+	if err := router.Dispatch([]byte("TEMP 22")); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"temp:TEMP 21.5", "ack:ACK 42", "binary:\x02\x01", "fallback:ACK x", "temp:TEMP 22"}
+	assertEqual(t, len(handled), len(expected))
+	for i := range expected {
+		assertEqual(t, handled[i], expected[i])
+	}
+}
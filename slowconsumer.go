@@ -0,0 +1,58 @@
+package eventedconnection
+
+import (
+	"sync"
+	"time"
+)
+
+// SlowConsumerInfo describes how far the consumers of the Read channel are behind
+type SlowConsumerInfo struct {
+	Blocked    time.Duration // how long the read loop waited for room in the Read channel
+	OldestWait time.Duration // how long the oldest message in the Read channel has been waiting
+	Queued     int           // messages waiting in the Read channel
+}
+
+// consumerMonitor tracks how long messages wait in the Read channel for slow consumer
+// detection
+type consumerMonitor struct {
+	mutex     sync.Mutex
+	threshold time.Duration
+	enqueued  []time.Time // delivery times of the messages that may still be queued, oldest first
+}
+
+// observe records a delivery at now after the read loop was blocked for blocked, with
+// queued messages left in the Read channel, and reports whether the consumer is slow.
+// The messages missing from the channel are the oldest ones since it is a FIFO.
+func (m *consumerMonitor) observe(now time.Time, blocked time.Duration, queued int) (SlowConsumerInfo, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.enqueued = append(m.enqueued, now)
+	if consumed := len(m.enqueued) - queued; consumed > 0 {
+		m.enqueued = append(m.enqueued[:0], m.enqueued[consumed:]...)
+	}
+
+	info := SlowConsumerInfo{Blocked: blocked, Queued: queued}
+	if len(m.enqueued) > 0 {
+		info.OldestWait = now.Sub(m.enqueued[0])
+	}
+	return info, info.Blocked >= m.threshold || info.OldestWait >= m.threshold
+}
+
+// checkConsumer reports a slow consumer if a delivery that blocked the read loop for
+// blocked exceeded Config.SlowConsumerThreshold
+func (conn *Client) checkConsumer(blocked time.Duration) {
+	if conn.consumerMonitor == nil {
+		return
+	}
+	info, slow := conn.consumerMonitor.observe(conn.clock.Now(), blocked, len(conn.Read)+len(conn.Data))
+	if !slow {
+		return
+	}
+
+	conn.stats.recordSlowDelivery()
+	conn.emit(SlowConsumerEvent{Origin: conn.origin(), SlowConsumerInfo: info})
+	if conn.onSlowConsumerHook != nil {
+		conn.onSlowConsumerHook(info)
+	}
+}
@@ -0,0 +1,72 @@
+package eventedconnection_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_SlowConsumer(t *testing.T) {
+	done := make(chan bool)
+	defer close(done)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reports := make(chan SlowConsumerInfo, 8)
+	conf := Config{
+		Endpoint:              l.Addr().String(),
+		ReadTimeout:           2 * time.Second,
+		WriteTimeout:          time.Second,
+		ReadChannelSize:       1,
+		SlowConsumerThreshold: 50 * time.Millisecond,
+		OnSlowConsumerHook: func(info SlowConsumerInfo) {
+			reports <- info
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// nobody reads while the second message waits for room in the Read channel
+	for _, message := range []string{"first", "second"} {
+		data := []byte(message)
+		if err = con.Write(&data); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case data := <-con.Read:
+			assertEqual(t, string(*data), expected)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %q", expected)
+		}
+	}
+
+	select {
+	case info := <-reports:
+		if info.Blocked < conf.SlowConsumerThreshold && info.OldestWait < conf.SlowConsumerThreshold {
+			t.Errorf("Unexpected report %+v", info)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the OnSlowConsumerHook to be called")
+	}
+
+	stats := con.GetStats()
+	assertEqual(t, stats.DeliveriesBlocked, uint64(1))
+	assertEqual(t, stats.BlockedTime >= 50*time.Millisecond, true)
+	assertEqual(t, stats.SlowDeliveries >= 1, true)
+}
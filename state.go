@@ -0,0 +1,91 @@
+package eventedconnection
+
+import "fmt"
+
+// State is the lifecycle state of a Client
+type State int
+
+const (
+	// StateIdle is the state of a new client before Connect is called
+	StateIdle State = iota
+	// StateConnecting means the client is dialing (and possibly handshaking)
+	StateConnecting
+	// StateConnected means the connection is established and being read from
+	StateConnected
+	// StateClosing means the connection is being closed and the BeforeDisconnectHook is running
+	StateClosing
+	// StateClosed means the connection was closed or could not be established
+	StateClosed
+	// StateReconnecting covers the whole of Reconnect, from closing the old connection
+	// until the new one is established (StateConnected) or has failed (StateClosed)
+	StateReconnecting
+)
+
+var stateNames = [...]string{
+	StateIdle:         "idle",
+	StateConnecting:   "connecting",
+	StateConnected:    "connected",
+	StateClosing:      "closing",
+	StateClosed:       "closed",
+	StateReconnecting: "reconnecting",
+}
+
+func (s State) String() string {
+	if s >= 0 && int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// StateChange describes a transition between two states. Err is the error that
+// caused the transition, if any (e.g. a failed dial or a read error).
+type StateChange struct {
+	From State
+	To   State
+	Err  error
+}
+
+// State returns the current state of the client
+func (conn *Client) State() State {
+	conn.stateMutex.Lock()
+	defer conn.stateMutex.Unlock()
+	return conn.state
+}
+
+// StateChanges returns a channel that receives every subsequent state transition,
+// including those caused by Reconnect, and a function that cancels the
+// subscription and closes the channel. Transitions are dropped if the channel is
+// full, so receivers should keep up.
+func (conn *Client) StateChanges() (<-chan StateChange, func()) {
+	return conn.stateChanges.subscribe(DefaultEventsBufferSize)
+}
+
+// setState moves the client to state to, publishing the transition and calling the
+// OnStateChangeHook unless the client is already in that state.
+func (conn *Client) setState(to State, err error) {
+	conn.stateMutex.Lock()
+	from := conn.state
+	if from == to {
+		conn.stateMutex.Unlock()
+		return
+	}
+	conn.state = to
+	conn.stateChanges.publish(StateChange{From: from, To: to, Err: err})
+	conn.stateMutex.Unlock()
+
+	if conn.onStateChangeHook != nil {
+		conn.onStateChangeHook(from, to, err)
+	}
+}
+
+// setStateUnlessReconnecting is used for the intermediate states that Reconnect
+// folds into StateReconnecting.
+func (conn *Client) setStateUnlessReconnecting(to State, err error) {
+	conn.stateMutex.Lock()
+	reconnecting := conn.state == StateReconnecting
+	conn.stateMutex.Unlock()
+
+	if !reconnecting {
+		conn.setState(to, err)
+	}
+}
@@ -0,0 +1,150 @@
+package eventedconnection_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func nextStateChange(t *testing.T, changes <-chan StateChange) StateChange {
+	t.Helper()
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for a state change")
+	}
+	return StateChange{}
+}
+
+func TestClient_State(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.State(), StateIdle)
+
+	changes, cancel := con.StateChanges()
+	defer cancel()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.State(), StateConnected)
+	assertEqual(t, nextStateChange(t, changes), StateChange{From: StateIdle, To: StateConnecting})
+	assertEqual(t, nextStateChange(t, changes), StateChange{From: StateConnecting, To: StateConnected})
+
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, nextStateChange(t, changes), StateChange{From: StateConnected, To: StateReconnecting})
+	assertEqual(t, nextStateChange(t, changes), StateChange{From: StateReconnecting, To: StateConnected})
+
+	con.Close()
+	assertEqual(t, con.State(), StateClosed)
+	assertEqual(t, nextStateChange(t, changes), StateChange{From: StateConnected, To: StateClosing})
+	assertEqual(t, nextStateChange(t, changes), StateChange{From: StateClosing, To: StateClosed})
+
+	cancel()
+	if _, ok := <-changes; ok {
+		t.Error("expected the channel to be closed after cancelling")
+	}
+}
+
+func TestClient_StateConnectFailure(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := l.Addr().String()
+	l.Close()
+
+	conf := Config{
+		Endpoint:    endpoint,
+		OnErrorHook: func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, cancel := con.StateChanges()
+	defer cancel()
+
+	if err = con.Connect(); err == nil {
+		t.Fatal("expected Connect to fail")
+	}
+	assertEqual(t, con.State(), StateClosed)
+	nextStateChange(t, changes)
+	change := nextStateChange(t, changes)
+	assertEqual(t, change.From, StateConnecting)
+	assertEqual(t, change.To, StateClosed)
+	if !errors.Is(change.Err, err) {
+		t.Errorf("expected %v, got %v", err, change.Err)
+	}
+}
+
+func TestState_String(t *testing.T) {
+	assertEqual(t, StateReconnecting.String(), "reconnecting")
+	assertEqual(t, State(42).String(), "State(42)")
+}
+
+func TestClient_OnStateChangeHook(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	var transitions []StateChange
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+		OnStateChangeHook: func(from, to State, err error) {
+			transitions = append(transitions, StateChange{From: from, To: to, Err: err})
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+
+	expected := []StateChange{
+		{From: StateIdle, To: StateConnecting},
+		{From: StateConnecting, To: StateConnected},
+		{From: StateConnected, To: StateClosing},
+		{From: StateClosing, To: StateClosed},
+	}
+	assertEqual(t, len(transitions), len(expected))
+	for i := range expected {
+		assertEqual(t, transitions[i], expected[i])
+	}
+}
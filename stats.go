@@ -0,0 +1,213 @@
+package eventedconnection
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of a Client's connection statistics. Counters are
+// cumulative over the lifetime of the Client, across reconnects.
+type Stats struct {
+	BytesRead           uint64 // bytes read from the connection, before the AfterReadHook
+	BytesWritten        uint64 // bytes written to the connection
+	MessagesDelivered   uint64 // messages sent through the Read channel or to subscribers
+	MessagesWritten     uint64 // successful writes to the connection, keepalives and probes included
+	WriteErrors         uint64 // failed calls to Write
+	Errors              uint64 // errors passed to the OnErrorHook
+	Reconnects          uint64 // successful calls to Reconnect
+	EventsDropped       uint64 // events not sent because the Events channel was full
+	SubscriberDrops     uint64 // messages missed by subscribers whose channel was full, see Client.Subscribe
+	ChecksumErrors      uint64 // frames read that failed validation, see Config.Checksum
+	IdleTimeouts        uint64 // expiries of Config.IdleTimeout
+	WatchdogTrips       uint64 // reconnects forced by the watchdog, see Config.WatchdogTimeout
+	HealthChecks        uint64 // health checks completed, see Config.HealthCheckInterval
+	HealthCheckFailures uint64 // health checks failed
+	KeepalivesSent      uint64 // keepalive messages written, see Config.KeepaliveInterval
+	CircuitOpens        uint64 // times the circuit breaker opened, see Config.CircuitBreakerThreshold
+	MessagesAcked       uint64 // writes acknowledged by the endpoint, see Config.AckExtractor
+	Retransmits         uint64 // unacknowledged writes written again after a reconnect
+	DeliveryFailures    uint64 // unacknowledged writes given up on
+
+	DeliveriesBlocked uint64        // messages the read loop had to wait to send on a full Read channel
+	BlockedTime       time.Duration // total time the read loop waited on a full Read channel
+	SlowDeliveries    uint64        // deliveries exceeding Config.SlowConsumerThreshold
+
+	ConnectedAt time.Time // when the current (or last) connection was established
+	LastReadAt  time.Time // when data was last read from the connection
+	LastWriteAt time.Time // when data was last written to the connection
+
+	Rates Rates // rolling rates as of the last sample, see Config.RateWindow
+}
+
+// stats accumulates the values reported by Client.GetStats
+type stats struct {
+	mutex sync.Mutex
+	Stats
+}
+
+func (s *stats) recordRead(n int, now time.Time) {
+	s.mutex.Lock()
+	s.BytesRead += uint64(n)
+	s.LastReadAt = now
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordWrite(n int, now time.Time) {
+	if n == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	s.BytesWritten += uint64(n)
+	s.LastWriteAt = now
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordMessageWritten() {
+	s.mutex.Lock()
+	s.MessagesWritten++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordWriteError() {
+	s.mutex.Lock()
+	s.WriteErrors++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordError() {
+	s.mutex.Lock()
+	s.Errors++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordDelivery() {
+	s.mutex.Lock()
+	s.MessagesDelivered++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordConnect(now time.Time) {
+	s.mutex.Lock()
+	s.ConnectedAt = now
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordReconnect() {
+	s.mutex.Lock()
+	s.Reconnects++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordDroppedEvent() {
+	s.mutex.Lock()
+	s.EventsDropped++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordChecksumError() {
+	s.mutex.Lock()
+	s.ChecksumErrors++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordIdle() {
+	s.mutex.Lock()
+	s.IdleTimeouts++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordWatchdogTrip() {
+	s.mutex.Lock()
+	s.WatchdogTrips++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordHealthCheck(failed bool) {
+	s.mutex.Lock()
+	s.HealthChecks++
+	if failed {
+		s.HealthCheckFailures++
+	}
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordKeepalive() {
+	s.mutex.Lock()
+	s.KeepalivesSent++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordSubscriberDrop() {
+	s.mutex.Lock()
+	s.SubscriberDrops++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordAck() {
+	s.mutex.Lock()
+	s.MessagesAcked++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordRetransmit() {
+	s.mutex.Lock()
+	s.Retransmits++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordDeliveryFailure() {
+	s.mutex.Lock()
+	s.DeliveryFailures++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordCircuitOpen() {
+	s.mutex.Lock()
+	s.CircuitOpens++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordBlockedDelivery(blocked time.Duration) {
+	s.mutex.Lock()
+	s.DeliveriesBlocked++
+	s.BlockedTime += blocked
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordSlowDelivery() {
+	s.mutex.Lock()
+	s.SlowDeliveries++
+	s.mutex.Unlock()
+}
+
+func (s *stats) recordRates(rates Rates) {
+	s.mutex.Lock()
+	s.Rates = rates
+	s.mutex.Unlock()
+}
+
+// rateSample returns the counters the rolling rates are computed from
+func (s *stats) rateSample(now time.Time) rateSample {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return rateSample{
+		at:              now,
+		bytesRead:       s.BytesRead,
+		bytesWritten:    s.BytesWritten,
+		messagesRead:    s.MessagesDelivered,
+		messagesWritten: s.MessagesWritten,
+		errors:          s.Errors,
+	}
+}
+
+func (s *stats) snapshot() Stats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.Stats
+}
+
+// GetStats returns a snapshot of the client's connection statistics
+func (conn *Client) GetStats() Stats {
+	return conn.stats.snapshot()
+}
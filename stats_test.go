@@ -0,0 +1,69 @@
+package eventedconnection_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_GetStats(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  1 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := con.GetStats()
+	assertEqual(t, stats.ConnectedAt.IsZero(), true)
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("Testing stats")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-con.Read:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+
+	stats = con.GetStats()
+	assertEqual(t, stats.BytesWritten, uint64(len(payload)))
+	assertEqual(t, stats.BytesRead, uint64(len(payload)))
+	assertEqual(t, stats.MessagesDelivered, uint64(1))
+	assertEqual(t, stats.WriteErrors, uint64(0))
+	assertEqual(t, stats.ConnectedAt.IsZero(), false)
+	assertEqual(t, stats.LastReadAt.IsZero(), false)
+	assertEqual(t, stats.LastWriteAt.IsZero(), false)
+
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+
+	assertNotNil(t, con.Write(&payload))
+
+	stats = con.GetStats()
+	assertEqual(t, stats.Reconnects, uint64(1))
+	assertEqual(t, stats.WriteErrors, uint64(1))
+	assertEqual(t, stats.BytesWritten, uint64(len(payload)))
+}
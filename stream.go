@@ -0,0 +1,195 @@
+package eventedconnection
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Stream is a net.Conn view of a Client, for use with code that expects standard
+// interfaces such as bufio, net/textproto, encoding/gob or third-party protocol
+// libraries, while the Client keeps handling hooks, statistics and reconnects
+// underneath. Create one with Client.Stream.
+type Stream struct {
+	conn *Client
+
+	mutex         sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// Stream returns a net.Conn (and so io.ReadWriteCloser) view of the client. Reading from the stream
+// consumes the Read channel, so it should not be mixed with other consumers of Read.
+// Bytes of a message that didn't fit into a Read are returned by the next Read.
+func (conn *Client) Stream() *Stream {
+	return &Stream{conn: conn}
+}
+
+// Scanner returns a bufio.Scanner over the inbound stream (see Stream) using split,
+// or bufio.ScanLines if split is nil. Tokens spanning several messages on the Read
+// channel are stitched together. Scanning stops when the connection is closed.
+func (conn *Client) Scanner(split bufio.SplitFunc) *bufio.Scanner {
+	scanner := bufio.NewScanner(conn.Stream())
+	if split != nil {
+		scanner.Split(split)
+	}
+	return scanner
+}
+
+// Read blocks until data arrives and copies it into p. It returns io.EOF once the
+// connection is closed and everything received has been read.
+func (s *Stream) Read(p []byte) (int, error) {
+	s.conn.readMutex.Lock()
+	defer s.conn.readMutex.Unlock()
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if len(s.conn.pending) == 0 {
+		ctx := context.Background()
+		if deadline := s.getDeadline(&s.readDeadline); !deadline.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+			defer cancel()
+		}
+
+		message, err := s.conn.nextMessage(ctx)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		if err != nil {
+			return 0, io.EOF
+		}
+		s.conn.pending = message
+	}
+
+	n := copy(p, s.conn.pending)
+	s.conn.pending = s.conn.pending[n:]
+	return n, nil
+}
+
+// Write writes p to the connection with Client.Write, or with WriteWithTimeout if a
+// write deadline is set.
+func (s *Stream) Write(p []byte) (int, error) {
+	var err error
+	if deadline := s.getDeadline(&s.writeDeadline); !deadline.IsZero() {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return 0, os.ErrDeadlineExceeded
+		}
+		err = s.conn.write(p, timeout)
+	} else {
+		err = s.conn.WriteBytes(p)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close closes the client's connection
+func (s *Stream) Close() error {
+	s.conn.Close()
+	return nil
+}
+
+// LocalAddr returns the local address of the current connection. It returns an
+// empty *net.TCPAddr while the client is disconnected.
+func (s *Stream) LocalAddr() net.Addr {
+	if connection := s.conn.rawConnection(); connection != nil {
+		return connection.LocalAddr()
+	}
+	return &net.TCPAddr{}
+}
+
+// RemoteAddr returns the remote address of the current connection. It returns an
+// empty *net.TCPAddr while the client is disconnected.
+func (s *Stream) RemoteAddr() net.Addr {
+	if connection := s.conn.rawConnection(); connection != nil {
+		return connection.RemoteAddr()
+	}
+	return &net.TCPAddr{}
+}
+
+// SetDeadline sets both the read and write deadlines of the stream
+func (s *Stream) SetDeadline(t time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.readDeadline = t
+	s.writeDeadline = t
+	return nil
+}
+
+// SetReadDeadline sets the time after which a Read waiting for data fails with
+// os.ErrDeadlineExceeded. It doesn't affect the client's own ReadTimeout.
+func (s *Stream) SetReadDeadline(t time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline sets the deadline used by Write instead of the client's WriteTimeout
+func (s *Stream) SetWriteDeadline(t time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.writeDeadline = t
+	return nil
+}
+
+func (s *Stream) getDeadline(deadline *time.Time) time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return *deadline
+}
+
+// nextMessage waits for the next message on the Read (or Data) channel. Messages that
+// arrived before the connection was closed are still returned; after that it returns
+// io.EOF. It returns ctx.Err() if ctx is done first.
+func (conn *Client) nextMessage(ctx context.Context) ([]byte, error) {
+	if data, ok := conn.poll(); ok {
+		return data, nil
+	}
+
+	// only one of Read and Data is used, receiving from the other (nil) one blocks
+	select {
+	case data := <-conn.Read:
+		return *data, nil
+	case data := <-conn.Data:
+		return data, nil
+	case <-conn.disconnected():
+		// the read loop may have delivered a final message just before closing
+		if data, ok := conn.poll(); ok {
+			return data, nil
+		}
+		return nil, io.EOF
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// poll returns a message waiting on the Read (or Data) channel without blocking
+func (conn *Client) poll() ([]byte, bool) {
+	select {
+	case data := <-conn.Read:
+		return *data, true
+	case data := <-conn.Data:
+		return data, true
+	default:
+		return nil, false
+	}
+}
+
+// disconnected returns the current Disconnected channel, which Reconnect replaces
+func (conn *Client) disconnected() chan struct{} {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+	return conn.Disconnected
+}
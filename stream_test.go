@@ -0,0 +1,162 @@
+package eventedconnection_test
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func connectEchoClient(t *testing.T) (*Client, func()) {
+	t.Helper()
+
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 1 * time.Second,
+		OnErrorHook:  func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	return con, func() {
+		con.Close()
+		close(done)
+	}
+}
+
+func TestClient_Stream(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	stream := con.Stream()
+	var _ io.ReadWriteCloser = stream
+
+	if _, err := io.WriteString(stream, "first line\nsecond line\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(stream)
+	for _, expected := range []string{"first line\n", "second line\n"} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, line, expected)
+	}
+
+	// small reads are stitched back together
+	if _, err := io.WriteString(stream, "0123456789"); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, 10)
+	if _, err := io.ReadFull(stream, buffer); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(buffer), "0123456789")
+
+	stream.Close()
+	_, err := stream.Read(buffer)
+	assertEqual(t, err, io.EOF)
+}
+
+func TestClient_StreamGob(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	type message struct {
+		ID   int
+		Body string
+	}
+
+	stream := con.Stream()
+	go gob.NewEncoder(stream).Encode(message{ID: 1, Body: "hello"})
+
+	var received message
+	if err := gob.NewDecoder(stream).Decode(&received); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, received, message{ID: 1, Body: "hello"})
+}
+
+func TestClient_StreamNetConn(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	var stream net.Conn = con.Stream()
+	assertNotNil(t, stream.LocalAddr())
+	assertEqual(t, stream.RemoteAddr().String(), con.Stream().RemoteAddr().String())
+	assertEqual(t, stream.RemoteAddr().(*net.TCPAddr).Port != 0, true)
+
+	// a read deadline interrupts a Read waiting for data
+	if err := stream.SetReadDeadline(time.Now().Add(20 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, 16)
+	_, err := stream.Read(buffer)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Expected os.ErrDeadlineExceeded, got %v", err)
+	}
+	var netErr net.Error
+	assertEqual(t, errors.As(err, &netErr) && netErr.Timeout(), true)
+
+	// the connection is still usable afterwards
+	if err = stream.SetDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := stream.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(buffer[:n]), "ping")
+
+	stream.SetWriteDeadline(time.Now().Add(-time.Second))
+	_, err = stream.Write([]byte("late"))
+	assertEqual(t, errors.Is(err, os.ErrDeadlineExceeded), true)
+}
+
+func TestClient_Scanner(t *testing.T) {
+	con, cleanup := connectEchoClient(t)
+	defer cleanup()
+
+	for _, chunk := range []string{"alpha be", "ta\ngam", "ma\ndelta", "\n"} {
+		payload := []byte(chunk)
+		if err := con.Write(&payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scanner := con.Scanner(bufio.ScanWords)
+	for _, expected := range []string{"alpha", "beta", "gamma", "delta"} {
+		if !scanner.Scan() {
+			t.Fatal(scanner.Err())
+		}
+		assertEqual(t, scanner.Text(), expected)
+	}
+
+	con.Close()
+	assertEqual(t, scanner.Scan(), false)
+	assertEqual(t, scanner.Err(), nil)
+}
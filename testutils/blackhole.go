@@ -0,0 +1,48 @@
+package testutils
+
+import (
+	"io"
+	"net"
+)
+
+// BlackHoleServer creates a TCP listener on a random port which accepts connections
+// but never reads from or writes to them, and doesn't close them until "done" is
+// closed. Reads from it time out, and writes to it block once the socket buffers
+// are full, which makes it useful for testing read and write deadlines and
+// watchdogs. Use the "done" channel to indicate when to stop listening.
+func BlackHoleServer(done chan bool) (net.Listener, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	serve(done, l, func(c net.Conn) {
+		defer c.Close()
+		<-done
+	})
+
+	return l, nil
+}
+
+// StallingServer creates a TCP listener on a random port which reads (and discards)
+// the first readBytes bytes of every connection and then stops reading without
+// closing it, like a peer that stops acknowledging data. Its receive buffer is made
+// as small as the operating system allows, so the client's writes stall soon after.
+// Use the "done" channel to indicate when to stop listening.
+func StallingServer(done chan bool, readBytes int64) (net.Listener, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	serve(done, l, func(c net.Conn) {
+		defer c.Close()
+		if tcpConn, ok := c.(*net.TCPConn); ok {
+			tcpConn.SetReadBuffer(1)
+		}
+		io.CopyN(io.Discard, c, readBytes)
+		<-done
+	})
+
+	return l, nil
+}
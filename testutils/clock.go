@@ -0,0 +1,125 @@
+package testutils
+
+import (
+	"sync"
+	"time"
+
+	eventedconnection "github.com/joedursun/EventedConnection"
+)
+
+// FakeClock is a Config.Clock whose time only moves when Advance is called, so tests
+// can run keepalives, backoff and other timers without sleeping. Its timers fire
+// during Advance, in deadline order.
+type FakeClock struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock set to now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the clock's current time
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// NewTimer returns a Timer that fires once the clock was advanced by d
+func (c *FakeClock) NewTimer(d time.Duration) eventedconnection.Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d, firing the timers that are due by then
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		next := c.nextTimer(end)
+		if next == nil {
+			break
+		}
+		c.now = next.deadline
+		c.fire(next)
+	}
+	c.now = end
+}
+
+// Timers returns the number of timers waiting to fire, e.g. to wait for the client
+// to start a timer before advancing the clock
+func (c *FakeClock) Timers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.timers)
+}
+
+// nextTimer returns the earliest timer due by end, or nil. c.mutex must be held.
+func (c *FakeClock) nextTimer(end time.Time) *fakeTimer {
+	var next *fakeTimer
+	for _, t := range c.timers {
+		if !t.deadline.After(end) && (next == nil || t.deadline.Before(next.deadline)) {
+			next = t
+		}
+	}
+	return next
+}
+
+// fire sends the current time on t's channel, unless it still holds an earlier one,
+// and stops t. c.mutex must be held.
+func (c *FakeClock) fire(t *fakeTimer) {
+	c.remove(t)
+	select {
+	case t.c <- c.now:
+	default:
+	}
+}
+
+// remove stops tracking t, reporting whether it was waiting to fire. c.mutex must be held.
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer is a Timer made by a FakeClock
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	c := t.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	active := c.remove(t)
+	t.deadline = c.now.Add(d)
+	if d <= 0 {
+		c.fire(t)
+	} else {
+		c.timers = append(c.timers, t)
+	}
+	return active
+}
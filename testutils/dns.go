@@ -0,0 +1,103 @@
+package testutils
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+)
+
+// SRVResolver returns a *net.Resolver, for Config.Resolver, that answers every SRV
+// lookup with records without touching the network. Other lookups find nothing.
+func SRVResolver(records []*net.SRV) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			go serveDNS(server, records)
+			return client, nil
+		},
+	}
+}
+
+// serveDNS answers the DNS queries sent over c, which uses the TCP framing (each
+// message prefixed with its length) since it isn't a net.PacketConn
+func serveDNS(c net.Conn, records []*net.SRV) {
+	defer c.Close()
+
+	for {
+		var size [2]byte
+		if _, err := io.ReadFull(c, size[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(c, query); err != nil {
+			return
+		}
+
+		response := dnsResponse(query, records)
+		if response == nil {
+			return
+		}
+		if _, err := c.Write(binary.BigEndian.AppendUint16(nil, uint16(len(response)))); err != nil {
+			return
+		}
+		if _, err := c.Write(response); err != nil {
+			return
+		}
+	}
+}
+
+// dnsResponse returns the authoritative answer to query, holding records if it asks
+// for SRV records, or nil if query is malformed
+func dnsResponse(query []byte, records []*net.SRV) []byte {
+	const headerSize, typeSRV = 12, 33
+	if len(query) < headerSize {
+		return nil
+	}
+
+	// the question is the name's labels up to the empty one, then the type and class
+	end := headerSize
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5
+	if end > len(query) {
+		return nil
+	}
+	question := query[headerSize:end]
+
+	var answers []*net.SRV
+	if binary.BigEndian.Uint16(question[len(question)-4:]) == typeSRV {
+		answers = records
+	}
+
+	response := append([]byte(nil), query[:2]...)              // ID
+	response = binary.BigEndian.AppendUint16(response, 0x8580) // response, authoritative, recursion desired and available
+	response = binary.BigEndian.AppendUint16(response, 1)      // questions
+	response = binary.BigEndian.AppendUint16(response, uint16(len(answers)))
+	response = binary.BigEndian.AppendUint16(response, 0) // authority records
+	response = binary.BigEndian.AppendUint16(response, 0) // additional records
+	response = append(response, question...)
+
+	for _, record := range answers {
+		var target []byte
+		for _, label := range strings.Split(strings.TrimSuffix(record.Target, "."), ".") {
+			target = append(target, byte(len(label)))
+			target = append(target, label...)
+		}
+		target = append(target, 0)
+
+		response = binary.BigEndian.AppendUint16(response, 0xc000|headerSize) // the question's name
+		response = binary.BigEndian.AppendUint16(response, typeSRV)
+		response = binary.BigEndian.AppendUint16(response, 1) // class IN
+		response = binary.BigEndian.AppendUint32(response, 60)
+		response = binary.BigEndian.AppendUint16(response, uint16(6+len(target)))
+		response = binary.BigEndian.AppendUint16(response, record.Priority)
+		response = binary.BigEndian.AppendUint16(response, record.Weight)
+		response = binary.BigEndian.AppendUint16(response, record.Port)
+		response = append(response, target...)
+	}
+	return response
+}
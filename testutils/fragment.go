@@ -0,0 +1,85 @@
+package testutils
+
+import (
+	"math/rand/v2"
+	"net"
+	"sync"
+	"time"
+)
+
+// FragmentOptions configure a FragmentingEchoServer
+type FragmentOptions struct {
+	MaxFragmentSize int           // fragments are 1 to this many bytes long; 1 if zero
+	Gap             time.Duration // pause between fragments, so they go out as separate TCP segments; 1ms if zero
+	CoalesceWindow  time.Duration // wait this long for more data before echoing, merging the messages that arrive meanwhile
+}
+
+// FragmentingEchoServer creates a TCP listener on a random port which echoes any data
+// sent through the connection, but split into fragments of random size written
+// opts.Gap apart, so the client reads every message in many small pieces. With a
+// CoalesceWindow, messages written in quick succession are merged before being
+// fragmented, so pieces can span message boundaries. Use the "done" channel to
+// indicate when to stop listening.
+func FragmentingEchoServer(done chan bool, opts FragmentOptions) (net.Listener, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	maxSize, gap := opts.MaxFragmentSize, opts.Gap
+	if maxSize <= 0 {
+		maxSize = 1
+	}
+	if gap <= 0 {
+		gap = time.Millisecond
+	}
+
+	serve(done, l, func(c net.Conn) {
+		defer c.Close()
+
+		var mutex sync.Mutex
+		var pending []byte
+		var closed bool
+		received := make(chan struct{}, 1)
+		go func() {
+			buffer := make([]byte, 4096)
+			for {
+				n, err := c.Read(buffer)
+				mutex.Lock()
+				pending = append(pending, buffer[:n]...)
+				closed = err != nil
+				mutex.Unlock()
+				select {
+				case received <- struct{}{}:
+				default:
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		for range received {
+			time.Sleep(opts.CoalesceWindow)
+
+			mutex.Lock()
+			data, last := pending, closed
+			pending = nil
+			mutex.Unlock()
+
+			for len(data) > 0 {
+				n := min(1+rand.IntN(maxSize), len(data))
+				if _, err := c.Write(data[:n]); err != nil {
+					return
+				}
+				data = data[n:]
+				time.Sleep(gap)
+			}
+			if last {
+				return
+			}
+		}
+	})
+
+	return l, nil
+}
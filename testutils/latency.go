@@ -0,0 +1,106 @@
+package testutils
+
+import (
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+)
+
+// LatencyOptions configure a LatencyEchoServer
+type LatencyOptions struct {
+	Latency        time.Duration // how long each message takes to be echoed
+	Jitter         time.Duration // up to this much is added to Latency, at random
+	BytesPerSecond int           // echo at most this many bytes per second; zero for unlimited
+}
+
+// LatencyEchoServer creates a TCP listener on a random port which echoes any data
+// sent through the connection like EchoServer, but as if over a slow link: every
+// message (whatever a single read returns) is sent back after opts.Latency plus up
+// to opts.Jitter, in order, and no faster than opts.BytesPerSecond. Use the "done"
+// channel to indicate when to stop listening.
+func LatencyEchoServer(done chan bool, opts LatencyOptions) (net.Listener, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	serve(done, l, func(c net.Conn) {
+		defer c.Close()
+
+		type message struct {
+			data []byte
+			due  time.Time
+		}
+		messages := make(chan message, 64)
+		go func() {
+			defer close(messages)
+			buffer := make([]byte, 4096)
+			for {
+				n, err := c.Read(buffer)
+				if n > 0 {
+					delay := opts.Latency
+					if opts.Jitter > 0 {
+						delay += rand.N(opts.Jitter + 1)
+					}
+					data := append([]byte(nil), buffer[:n]...)
+					messages <- message{data: data, due: time.Now().Add(delay)}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		for m := range messages {
+			time.Sleep(time.Until(m.due))
+			if err := writeThrottled(c, m.data, opts.BytesPerSecond); err != nil {
+				c.Close() // stops the reader, which closes messages
+				for range messages {
+				}
+				return
+			}
+		}
+	})
+
+	return l, nil
+}
+
+// writeThrottled writes data to w at no more than bytesPerSecond, in tenth-of-a-second
+// chunks. A bytesPerSecond of zero writes it all at once.
+func writeThrottled(w io.Writer, data []byte, bytesPerSecond int) error {
+	if bytesPerSecond <= 0 {
+		_, err := w.Write(data)
+		return err
+	}
+
+	chunk := max(bytesPerSecond/10, 1)
+	for len(data) > 0 {
+		n := min(chunk, len(data))
+		if _, err := w.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+		time.Sleep(time.Duration(n) * time.Second / time.Duration(bytesPerSecond))
+	}
+	return nil
+}
+
+// serve accepts connections on l and handles each one in its own goroutine until
+// done is closed, which closes the listener
+func serve(done chan bool, l net.Listener, handle func(c net.Conn)) {
+	go func() {
+		<-done
+		l.Close()
+	}()
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(c)
+		}
+	}()
+}
@@ -0,0 +1,388 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	eventedconnection "github.com/joedursun/EventedConnection"
+)
+
+// MockConnection is an in-memory eventedconnection.Connection for unit testing code
+// built on a Client without any sockets. Messages from the endpoint are supplied with
+// Deliver and everything written is recorded for Written. Set OnConnect and OnWrite
+// before use to make connecting or writing fail, or to answer writes.
+type MockConnection struct {
+	// OnConnect, if set, is called by Connect and Reconnect; returning an error fails
+	// the call and leaves the mock closed
+	OnConnect func() error
+	// OnWrite, if set, is called with every write; returning an error fails the write,
+	// which isn't recorded then. It may call Deliver to answer the write.
+	OnWrite func(data []byte) error
+
+	mutex        sync.Mutex
+	state        eventedconnection.State
+	err          error         // cause of the last disconnect, see Err
+	messages     chan []byte   // messages delivered but not yet read
+	disconnected chan struct{} // closed once the current connection is closed
+	done         chan struct{}
+	shutdown     sync.Once
+	written      [][]byte
+	subscribers  []chan []byte
+	events       []chan eventedconnection.Event
+	stats        eventedconnection.Stats
+}
+
+// NewMockConnection returns a MockConnection that hasn't been connected yet
+func NewMockConnection() *MockConnection {
+	m := &MockConnection{
+		messages:     make(chan []byte, eventedconnection.DefaultReadChannelSize),
+		disconnected: make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	close(m.disconnected)
+	return m
+}
+
+// Connect calls the OnConnect hook, if any, and marks the mock as connected. It does
+// nothing if the mock is connected already.
+func (m *MockConnection) Connect() error {
+	m.mutex.Lock()
+	if m.isShutdown() {
+		m.mutex.Unlock()
+		return eventedconnection.ErrShutdown
+	}
+	if m.state == eventedconnection.StateConnected {
+		m.mutex.Unlock()
+		return nil
+	}
+	m.state = eventedconnection.StateConnecting
+	m.mutex.Unlock()
+
+	if m.OnConnect != nil {
+		if err := m.OnConnect(); err != nil {
+			m.mutex.Lock()
+			m.state, m.err = eventedconnection.StateClosed, err
+			m.mutex.Unlock()
+			return err
+		}
+	}
+
+	m.mutex.Lock()
+	m.state, m.err = eventedconnection.StateConnected, nil
+	m.disconnected = make(chan struct{})
+	m.stats.ConnectedAt = time.Now()
+	m.emit(eventedconnection.ConnectedEvent{})
+	m.mutex.Unlock()
+	return nil
+}
+
+// Reconnect closes the mock, if connected, and connects it again
+func (m *MockConnection) Reconnect() error {
+	m.disconnect(nil)
+	if err := m.Connect(); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	m.stats.Reconnects++
+	m.mutex.Unlock()
+	return nil
+}
+
+// IsActive reports whether the mock is connected
+func (m *MockConnection) IsActive() bool {
+	return m.State() == eventedconnection.StateConnected
+}
+
+// State returns the mock's lifecycle state
+func (m *MockConnection) State() eventedconnection.State {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.state
+}
+
+// Err returns the error the mock was last disconnected with, see Client.Err
+func (m *MockConnection) Err() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.isShutdown() {
+		return eventedconnection.ErrShutdown
+	}
+	return m.err
+}
+
+// Done returns a channel that is closed once the mock is shut down
+func (m *MockConnection) Done() <-chan struct{} {
+	return m.done
+}
+
+func (m *MockConnection) isShutdown() bool {
+	select {
+	case <-m.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Write records a copy of *data, after passing it to the OnWrite hook if there is one
+func (m *MockConnection) Write(data *[]byte) error {
+	return m.WriteBytes(*data)
+}
+
+// WriteBytes is like Write but takes the slice itself
+func (m *MockConnection) WriteBytes(data []byte) error {
+	m.mutex.Lock()
+	state := m.state
+	m.mutex.Unlock()
+
+	switch state {
+	case eventedconnection.StateConnected:
+	case eventedconnection.StateClosing, eventedconnection.StateClosed:
+		return eventedconnection.ErrClosed
+	default:
+		return eventedconnection.ErrNotConnected
+	}
+
+	if m.OnWrite != nil {
+		if err := m.OnWrite(data); err != nil {
+			m.mutex.Lock()
+			m.stats.WriteErrors++
+			m.mutex.Unlock()
+			return err
+		}
+	}
+
+	m.mutex.Lock()
+	m.written = append(m.written, slices.Clone(data))
+	m.stats.BytesWritten += uint64(len(data))
+	m.stats.LastWriteAt = time.Now()
+	m.mutex.Unlock()
+	return nil
+}
+
+// WriteString is like Write but takes a string
+func (m *MockConnection) WriteString(s string) error {
+	return m.WriteBytes([]byte(s))
+}
+
+// WriteWithTimeout is like Write; the mock never times out
+func (m *MockConnection) WriteWithTimeout(data *[]byte, timeout time.Duration) error {
+	return m.Write(data)
+}
+
+// Written returns a copy of everything written so far, one element per write
+func (m *MockConnection) Written() [][]byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	written := make([][]byte, len(m.written))
+	for i, data := range m.written {
+		written[i] = slices.Clone(data)
+	}
+	return written
+}
+
+// Deliver makes a copy of data available to ReadContext, or to the subscribers if there
+// are any, as if it had been read from the endpoint. Like the client's read loop it waits
+// while too many messages are unread. It fails if the mock isn't connected.
+func (m *MockConnection) Deliver(data []byte) error {
+	m.mutex.Lock()
+	if m.state != eventedconnection.StateConnected {
+		m.mutex.Unlock()
+		return eventedconnection.ErrNotConnected
+	}
+	data = slices.Clone(data)
+	m.stats.BytesRead += uint64(len(data))
+	m.stats.LastReadAt = time.Now()
+	for _, ch := range m.subscribers {
+		select {
+		case ch <- data:
+		default:
+			m.stats.SubscriberDrops++
+		}
+	}
+	subscribed := len(m.subscribers) > 0
+	m.mutex.Unlock()
+
+	if !subscribed {
+		select {
+		case m.messages <- data:
+		case <-m.done:
+			return eventedconnection.ErrShutdown
+		}
+	}
+
+	m.mutex.Lock()
+	m.stats.MessagesDelivered++
+	m.mutex.Unlock()
+	return nil
+}
+
+// Drop closes the connection as if it was lost with err, e.g. io.EOF for the
+// endpoint hanging up
+func (m *MockConnection) Drop(err error) {
+	m.disconnect(err)
+}
+
+// ReadContext returns the next delivered message. It returns ErrClosed once
+// the mock is closed and every delivered message has been read, or ctx.Err() if ctx
+// is done first.
+func (m *MockConnection) ReadContext(ctx context.Context) ([]byte, error) {
+	select {
+	case message := <-m.messages:
+		return message, nil
+	default:
+	}
+
+	m.mutex.Lock()
+	disconnected := m.disconnected
+	m.mutex.Unlock()
+
+	select {
+	case message := <-m.messages:
+		return message, nil
+	case <-disconnected:
+		select {
+		case message := <-m.messages:
+			return message, nil
+		default:
+			return nil, eventedconnection.ErrClosed
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ReadWithTimeout is like ReadContext but gives up with ErrReadTimeout after d. A d
+// of zero or less waits until a message arrives or the mock is closed.
+func (m *MockConnection) ReadWithTimeout(d time.Duration) ([]byte, error) {
+	ctx := context.Background()
+	if d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	message, err := m.ReadContext(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, eventedconnection.ErrReadTimeout
+	}
+	return message, err
+}
+
+// Subscribe returns a new channel that receives every subsequently delivered message,
+// see Client.Subscribe
+func (m *MockConnection) Subscribe() <-chan []byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ch := make(chan []byte, eventedconnection.DefaultReadChannelSize)
+	m.subscribers = append(m.subscribers, ch)
+	return ch
+}
+
+// Unsubscribe cancels a subscription made with Subscribe and closes its channel
+func (m *MockConnection) Unsubscribe(ch <-chan []byte) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, subscriber := range m.subscribers {
+		if subscriber == ch {
+			m.subscribers = slices.Delete(m.subscribers, i, i+1)
+			close(subscriber)
+			return
+		}
+	}
+}
+
+// SubscribeEvents returns a new channel that receives the ConnectedEvent and
+// DisconnectedEvent of every subsequent connection, and a function that cancels the
+// subscription and closes the channel
+func (m *MockConnection) SubscribeEvents() (<-chan eventedconnection.Event, func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ch := make(chan eventedconnection.Event, eventedconnection.DefaultEventsBufferSize)
+	m.events = append(m.events, ch)
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			if i := slices.Index(m.events, ch); i >= 0 {
+				m.events = slices.Delete(m.events, i, i+1)
+			}
+			close(ch)
+		})
+	}
+}
+
+// emit sends event to every events subscriber without blocking. m.mutex must be held.
+func (m *MockConnection) emit(event eventedconnection.Event) {
+	for _, ch := range m.events {
+		select {
+		case ch <- event:
+		default:
+			m.stats.EventsDropped++
+		}
+	}
+}
+
+// Close closes the connection; it does nothing if the mock isn't connected
+func (m *MockConnection) Close() {
+	m.disconnect(nil)
+}
+
+// CloseWithError closes the connection with reason, which Err returns afterwards
+func (m *MockConnection) CloseWithError(reason error) {
+	m.disconnect(reason)
+}
+
+// CloseGracefully closes the connection; the mock has no writes in flight to wait for
+func (m *MockConnection) CloseGracefully(ctx context.Context) error {
+	m.Close()
+	return nil
+}
+
+// Shutdown closes the connection for good, see Client.Shutdown
+func (m *MockConnection) Shutdown() {
+	m.shutdown.Do(func() {
+		m.Close()
+		close(m.done)
+	})
+}
+
+// WaitClosed blocks until the current connection is closed
+func (m *MockConnection) WaitClosed() {
+	m.mutex.Lock()
+	disconnected := m.disconnected
+	m.mutex.Unlock()
+	<-disconnected
+}
+
+// GetStats returns the mock's counters, see Client.GetStats
+func (m *MockConnection) GetStats() eventedconnection.Stats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.stats
+}
+
+// disconnect closes the current connection with err
+func (m *MockConnection) disconnect(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.state != eventedconnection.StateConnected {
+		return
+	}
+	m.state, m.err = eventedconnection.StateClosed, err
+	close(m.disconnected)
+	m.emit(eventedconnection.DisconnectedEvent{Err: err})
+}
+
+var _ eventedconnection.Connection = (*MockConnection)(nil)
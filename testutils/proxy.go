@@ -0,0 +1,209 @@
+package testutils
+
+import (
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	eventedconnection "github.com/joedursun/EventedConnection"
+)
+
+// Proxy is a TCP proxy to sit between a client and a test server, which tests can
+// tell to misbehave while connections pass through it: add latency, stall, corrupt
+// data or cut connections short. Directions are given from the client's point of
+// view, so DirectionWrite is data from the client to the server and DirectionRead
+// data from the server to the client.
+type Proxy struct {
+	listener net.Listener
+	target   string
+
+	mutex   sync.Mutex
+	latency time.Duration
+	resume  chan struct{} // closed by Resume; nil unless stalled
+	corrupt [2]int        // chunks left to corrupt, by direction
+	cutAt   [2]int64      // bytes left to forward before cutting the connections, by direction; -1 if not cutting
+	pairs   []*proxyPair
+	done    chan bool
+}
+
+// proxyPair is a connection from the client and the one to the target it is forwarded to
+type proxyPair struct {
+	client, server net.Conn
+	closeOnce      sync.Once
+}
+
+func (pair *proxyPair) close() {
+	pair.closeOnce.Do(func() {
+		pair.client.Close()
+		pair.server.Close()
+	})
+}
+
+// NewProxy creates a TCP listener on a random port which forwards every connection it
+// accepts to target (host:port), as is until told otherwise. Use the "done" channel
+// to indicate when to stop listening, which also closes the connections.
+func NewProxy(done chan bool, target string) (*Proxy, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{listener: l, target: target, cutAt: [2]int64{-1, -1}, done: done}
+	go func() {
+		<-done
+		p.CloseConnections()
+	}()
+	serve(done, l, p.forward)
+
+	return p, nil
+}
+
+// Addr returns the address to connect the client to
+func (p *Proxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// SetLatency delays every chunk of data forwarded, in either direction, by d
+func (p *Proxy) SetLatency(d time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.latency = d
+}
+
+// Stall stops forwarding data, in either direction, until Resume is called. The
+// connections stay open.
+func (p *Proxy) Stall() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.resume == nil {
+		p.resume = make(chan struct{})
+	}
+}
+
+// Resume forwards data again after Stall, including what arrived meanwhile
+func (p *Proxy) Resume() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.resume != nil {
+		close(p.resume)
+		p.resume = nil
+	}
+}
+
+// Corrupt flips every bit of the first byte of the next n chunks of data forwarded
+// in direction
+func (p *Proxy) Corrupt(direction eventedconnection.Direction, n int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.corrupt[direction] += n
+}
+
+// CutAfter closes the connections once n more bytes have been forwarded in direction,
+// even if that is in the middle of a chunk, e.g. to have the client see a message cut
+// short
+func (p *Proxy) CutAfter(direction eventedconnection.Direction, n int64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.cutAt[direction] = n
+}
+
+// CloseConnections closes every connection currently passing through the proxy; it
+// keeps accepting new ones
+func (p *Proxy) CloseConnections() {
+	p.mutex.Lock()
+	pairs := p.pairs
+	p.pairs = nil
+	p.mutex.Unlock()
+
+	for _, pair := range pairs {
+		pair.close()
+	}
+}
+
+// forward connects client to the target and forwards data both ways until either
+// side hangs up
+func (p *Proxy) forward(client net.Conn) {
+	server, err := net.Dial("tcp", p.target)
+	if err != nil {
+		client.Close()
+		return
+	}
+
+	pair := &proxyPair{client: client, server: server}
+	p.mutex.Lock()
+	p.pairs = append(p.pairs, pair)
+	p.mutex.Unlock()
+
+	go p.pump(pair, eventedconnection.DirectionWrite, client, server)
+	p.pump(pair, eventedconnection.DirectionRead, server, client)
+}
+
+// pump copies data from src to dst, applying the faults the proxy was told to inject
+// in direction. Both connections are closed when it is done.
+func (p *Proxy) pump(pair *proxyPair, direction eventedconnection.Direction, src, dst net.Conn) {
+	defer p.remove(pair)
+
+	buffer := make([]byte, 4096)
+	for {
+		n, err := src.Read(buffer)
+		if n > 0 {
+			data, cut := p.inject(direction, buffer[:n])
+			if len(data) > 0 {
+				if _, err := dst.Write(data); err != nil {
+					return
+				}
+			}
+			if cut {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// inject waits out a stall and the latency, then returns data as it should be
+// forwarded and whether the connections must be cut after that
+func (p *Proxy) inject(direction eventedconnection.Direction, data []byte) ([]byte, bool) {
+	p.mutex.Lock()
+	resume, latency := p.resume, p.latency
+	p.mutex.Unlock()
+
+	if resume != nil {
+		select {
+		case <-resume:
+		case <-p.done:
+			return nil, true
+		}
+	}
+	time.Sleep(latency)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.corrupt[direction] > 0 {
+		p.corrupt[direction]--
+		data[0] ^= 0xff
+	}
+	if left := p.cutAt[direction]; left >= 0 {
+		if int64(len(data)) >= left {
+			p.cutAt[direction] = -1
+			return data[:left], true
+		}
+		p.cutAt[direction] -= int64(len(data))
+	}
+	return data, false
+}
+
+// remove closes pair and stops tracking it
+func (p *Proxy) remove(pair *proxyPair) {
+	pair.close()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if i := slices.Index(p.pairs, pair); i >= 0 {
+		p.pairs = slices.Delete(p.pairs, i, i+1)
+	}
+}
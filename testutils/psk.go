@@ -0,0 +1,40 @@
+package testutils
+
+import (
+	"errors"
+	"io"
+	"net"
+
+	eventedconnection "github.com/joedursun/EventedConnection"
+)
+
+// PSKEchoServer creates a TCP listener on a random port which runs the endpoint's side
+// of the pre-shared key handshake (see eventedconnection.PSKServer) with the keys of
+// the identities in keys, then echoes any data sent through the connection. Connections
+// failing the handshake are closed. Use the "done" channel to indicate when to stop
+// listening.
+func PSKEchoServer(done chan bool, keys map[string][]byte) (net.Listener, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	lookup := func(identity string) ([]byte, error) {
+		if key, ok := keys[identity]; ok {
+			return key, nil
+		}
+		return nil, errors.New("unknown identity " + identity)
+	}
+
+	serve(done, l, func(c net.Conn) {
+		defer c.Close()
+
+		conn, err := eventedconnection.PSKServer(c, lookup)
+		if err != nil {
+			return
+		}
+		io.Copy(conn, conn)
+	})
+
+	return l, nil
+}
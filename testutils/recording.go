@@ -0,0 +1,185 @@
+package testutils
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RecordingOptions configure a RecordingServer
+type RecordingOptions struct {
+	Greeting []byte // written to every connection as soon as it is accepted
+	Echo     bool   // echo everything received, like EchoServer
+}
+
+// ConnectionRecord is what a RecordingServer saw of a single connection
+type ConnectionRecord struct {
+	Remote      net.Addr
+	Received    []byte   // everything read from the connection
+	Payloads    [][]byte // the same data as returned by each read
+	Closed      bool
+	CloseReason error // io.EOF if the client closed the connection, otherwise the read error
+}
+
+// Recorder holds the connections accepted by a RecordingServer for test assertions
+type Recorder struct {
+	mutex       sync.Mutex
+	connections []*ConnectionRecord
+	changed     chan struct{} // closed and replaced on every change
+}
+
+// RecordingServer creates a TCP listener on a random port which records every
+// connection it accepts, the data it receives and why the connection ended, for the
+// returned Recorder to report. Use the "done" channel to indicate when to stop
+// listening.
+func RecordingServer(done chan bool, opts RecordingOptions) (net.Listener, *Recorder, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &Recorder{changed: make(chan struct{})}
+	serve(done, l, func(c net.Conn) {
+		defer c.Close()
+
+		record := r.add(c.RemoteAddr())
+		if len(opts.Greeting) > 0 {
+			if _, err := c.Write(opts.Greeting); err != nil {
+				r.update(func() { record.Closed, record.CloseReason = true, err })
+				return
+			}
+		}
+
+		buffer := make([]byte, 4096)
+		for {
+			n, err := c.Read(buffer)
+			if n > 0 {
+				data := slices.Clone(buffer[:n])
+				r.update(func() {
+					record.Received = append(record.Received, data...)
+					record.Payloads = append(record.Payloads, data)
+				})
+				if opts.Echo {
+					c.Write(data)
+				}
+			}
+			if err != nil {
+				r.update(func() { record.Closed, record.CloseReason = true, err })
+				return
+			}
+		}
+	})
+
+	return l, r, nil
+}
+
+func (r *Recorder) add(remote net.Addr) *ConnectionRecord {
+	record := &ConnectionRecord{Remote: remote}
+	r.update(func() { r.connections = append(r.connections, record) })
+	return record
+}
+
+// update applies change while holding the lock and wakes up the waiters
+func (r *Recorder) update(change func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	change()
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Connections returns a copy of the records of every connection accepted so far, in
+// the order they were accepted
+func (r *Recorder) Connections() []ConnectionRecord {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	records := make([]ConnectionRecord, len(r.connections))
+	for i, record := range r.connections {
+		records[i] = *record
+		records[i].Received = slices.Clone(record.Received)
+		records[i].Payloads = slices.Clone(record.Payloads)
+	}
+	return records
+}
+
+// ConnectionCount returns the number of connections accepted so far
+func (r *Recorder) ConnectionCount() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.connections)
+}
+
+// Received returns everything received so far, on all connections in the order
+// they were accepted
+func (r *Recorder) Received() []byte {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.received()
+}
+
+func (r *Recorder) received() []byte {
+	var received []byte
+	for _, record := range r.connections {
+		received = append(received, record.Received...)
+	}
+	return received
+}
+
+// WaitForReceived waits until at least n bytes were received and returns everything
+// received, see Received. It fails if that takes longer than timeout.
+func (r *Recorder) WaitForReceived(n int, timeout time.Duration) ([]byte, error) {
+	var received []byte
+	err := r.wait(timeout, func() bool {
+		received = r.received()
+		return len(received) >= n
+	})
+	if err != nil {
+		return received, fmt.Errorf("received %d of %d bytes: %w", len(received), n, err)
+	}
+	return received, nil
+}
+
+// WaitForClosed waits until n connections were closed and returns the records of
+// all connections, see Connections. It fails if that takes longer than timeout.
+func (r *Recorder) WaitForClosed(n int, timeout time.Duration) ([]ConnectionRecord, error) {
+	closed := 0
+	err := r.wait(timeout, func() bool {
+		closed = 0
+		for _, record := range r.connections {
+			if record.Closed {
+				closed++
+			}
+		}
+		return closed >= n
+	})
+	if err != nil {
+		return r.Connections(), fmt.Errorf("%d of %d connections closed: %w", closed, n, err)
+	}
+	return r.Connections(), nil
+}
+
+// wait calls done with the lock held until it returns true, every time the recorder
+// changes, for up to timeout
+func (r *Recorder) wait(timeout time.Duration, done func() bool) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		r.mutex.Lock()
+		finished, changed := done(), r.changed
+		r.mutex.Unlock()
+		if finished {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+	}
+}
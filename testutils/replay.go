@@ -0,0 +1,68 @@
+package testutils
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	eventedconnection "github.com/joedursun/EventedConnection"
+)
+
+// ReplayServer creates a TCP listener on a random port which plays the endpoint's
+// side of a capture made with Config.Capture to every connection it accepts. Data
+// the client originally read is sent with the original timing, and data the client
+// originally wrote is waited for (by length) before the replay continues, so the
+// client sees the same sequence of events as when the capture was recorded.
+func ReplayServer(done chan bool, records []eventedconnection.CaptureRecord) (net.Listener, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	go func(l net.Listener) {
+		defer l.Close()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				conn, err := l.Accept()
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+
+				go replay(conn, records)
+			}
+		}
+	}(l)
+
+	return l, nil
+}
+
+func replay(c net.Conn, records []eventedconnection.CaptureRecord) {
+	defer c.Close()
+
+	var previous time.Time
+	for _, record := range records {
+		if !previous.IsZero() {
+			time.Sleep(record.Time.Sub(previous))
+		}
+		previous = record.Time
+
+		switch record.Direction {
+		case eventedconnection.DirectionRead:
+			if _, err := c.Write(record.Data); err != nil {
+				return
+			}
+		case eventedconnection.DirectionWrite:
+			if _, err := io.ReadFull(c, make([]byte, len(record.Data))); err != nil {
+				return
+			}
+		}
+	}
+
+	// keep the connection open until the client is done with it
+	io.Copy(io.Discard, c)
+}
@@ -0,0 +1,136 @@
+package testutils
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// Action is a step of a ScriptedServer's script
+type Action struct {
+	accept bool // Accept rather than an action on the current connection
+	run    func(c net.Conn) error
+}
+
+// Accept waits for the next connection, which the following actions apply to. The
+// previous connection is left as it is.
+func Accept() Action {
+	return Action{accept: true}
+}
+
+var errNoConnection = errors.New("no connection accepted yet")
+
+// onConnection returns the Action running run on the current connection
+func onConnection(run func(c net.Conn) error) Action {
+	return Action{run: func(c net.Conn) error {
+		if c == nil {
+			return errNoConnection
+		}
+		return run(c)
+	}}
+}
+
+// Wait pauses the script for d
+func Wait(d time.Duration) Action {
+	return Action{run: func(net.Conn) error {
+		time.Sleep(d)
+		return nil
+	}}
+}
+
+// Send writes data to the current connection
+func Send(data []byte) Action {
+	return onConnection(func(c net.Conn) error {
+		_, err := c.Write(data)
+		return err
+	})
+}
+
+// Receive reads and discards n bytes from the current connection, e.g. to wait for
+// the client's request before answering
+func Receive(n int64) Action {
+	return onConnection(func(c net.Conn) error {
+		_, err := io.CopyN(io.Discard, c, n)
+		return err
+	})
+}
+
+// Echo echoes everything sent through the current connection until the client hangs
+// up, then closes it
+func Echo() Action {
+	return onConnection(func(c net.Conn) error {
+		io.Copy(c, c)
+		return c.Close()
+	})
+}
+
+// Close closes the current connection
+func Close() Action {
+	return onConnection(func(c net.Conn) error {
+		return c.Close()
+	})
+}
+
+// Reset closes the current connection abruptly, so the client sees "connection reset
+// by peer" rather than EOF. Data sent just before may be discarded by the client's
+// operating system, so Wait before resetting for the client to read it.
+func Reset() Action {
+	return onConnection(func(c net.Conn) error {
+		if tcpConn, ok := c.(*net.TCPConn); ok {
+			tcpConn.SetLinger(0)
+		}
+		return c.Close()
+	})
+}
+
+// ScriptedServer creates a TCP listener on a random port which runs script once, in
+// order, so that a specific sequence of events can be reproduced, e.g.
+//
+//	ScriptedServer(done, Accept(), Send(greeting), Close(), Accept(), Wait(time.Second), Echo())
+//
+// hangs up on the first connection right after greeting it and echoes on the second
+// one after a delay. If an action fails, e.g. because the client hung up, the script
+// skips to the next Accept. Connections made after the script is done aren't
+// accepted. Use the "done" channel to indicate when to stop listening, which also
+// closes the connections left open.
+func ScriptedServer(done chan bool, script ...Action) (net.Listener, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		<-done
+		l.Close()
+	}()
+
+	go func() {
+		var c net.Conn
+		var accepted []net.Conn
+		defer func() {
+			<-done
+			for _, c := range accepted {
+				c.Close()
+			}
+		}()
+
+		failed := false
+		for _, action := range script {
+			if action.accept {
+				var err error
+				if c, err = l.Accept(); err != nil {
+					return // stopped listening
+				}
+				accepted = append(accepted, c)
+				failed = false
+				continue
+			}
+			if !failed {
+				failed = action.run(c) != nil
+			}
+		}
+	}()
+
+	return l, nil
+}
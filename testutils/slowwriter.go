@@ -0,0 +1,42 @@
+package testutils
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// SlowWriterServer creates a TCP listener on a random port which dribbles response to
+// every connection it accepts, chunkSize bytes at a time every interval, like a
+// slowloris-style peer. Once the whole response is written it keeps the connection
+// open, discarding anything sent through it, until the client hangs up. Use the
+// "done" channel to indicate when to stop listening.
+func SlowWriterServer(done chan bool, response []byte, chunkSize int, interval time.Duration) (net.Listener, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize = max(chunkSize, 1)
+	serve(done, l, func(c net.Conn) {
+		defer c.Close()
+		go io.Copy(io.Discard, c)
+
+		for data := response; len(data) > 0; {
+			n := min(chunkSize, len(data))
+			if _, err := c.Write(data[:n]); err != nil {
+				return
+			}
+			data = data[n:]
+
+			select {
+			case <-time.After(interval):
+			case <-done:
+				return
+			}
+		}
+		<-done
+	})
+
+	return l, nil
+}
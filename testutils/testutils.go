@@ -1,11 +1,14 @@
 package testutils
 
 import (
+	"bufio"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"os"
 	"time"
 )
 
@@ -45,8 +48,13 @@ func EchoServer(done chan bool) (net.Listener, error) {
 	return l, nil
 }
 
-// FlakyServer creates a TCP listener on a random port and echoes
-// any data sent through the connection.
+// FlakyServer creates a TCP listener on a random port and echoes any data sent
+// through the connection, but slowly: it only starts serving a connection
+// connectDelay after accepting it, and then waits another readDelay before reading
+// from it. The TCP handshake is completed by the operating system regardless, so
+// connectDelay doesn't delay Connect, only the server's first read. Use
+// ScriptedServer to reproduce more specific failures. Use the "done" channel to
+// indicate when to stop listening.
 func FlakyServer(done chan bool, connectDelay, readDelay time.Duration) (net.Listener, error) {
 	// get random available port to listen on
 	l, err := net.Listen("tcp", ":0")
@@ -54,6 +62,58 @@ func FlakyServer(done chan bool, connectDelay, readDelay time.Duration) (net.Lis
 		return nil, err
 	}
 
+	serve(done, l, func(c net.Conn) {
+		defer c.Close()
+		time.Sleep(connectDelay)
+		time.Sleep(readDelay)
+		io.Copy(c, c)
+	})
+
+	return l, nil
+}
+
+// TLSEchoServer uses the test cert and key files.
+func TLSEchoServer(done chan bool, crtFilename, keyFilename string) (net.Listener, error) {
+	cer, err := tls.LoadX509KeyPair(crtFilename, keyFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	return TLSEchoServerWithConfig(done, &tls.Config{Certificates: []tls.Certificate{cer}})
+}
+
+// MutualTLSEchoServer behaves like TLSEchoServer but requires clients to present
+// a certificate signed by one of the certificates in caFilename.
+func MutualTLSEchoServer(done chan bool, crtFilename, keyFilename, caFilename string) (net.Listener, error) {
+	cer, err := tls.LoadX509KeyPair(crtFilename, keyFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	pem, err := os.ReadFile(caFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFilename)
+	}
+
+	return TLSEchoServerWithConfig(done, &tls.Config{
+		Certificates: []tls.Certificate{cer},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+}
+
+// TLSEchoServerWithConfig is a TLS echo server using the given server side config
+func TLSEchoServerWithConfig(done chan bool, config *tls.Config) (net.Listener, error) {
+	l, err := tls.Listen("tcp", ":0", config)
+	if err != nil {
+		return nil, err
+	}
+
 	go func(l net.Listener) {
 		defer l.Close()
 		for {
@@ -63,11 +123,11 @@ func FlakyServer(done chan bool, connectDelay, readDelay time.Duration) (net.Lis
 			default:
 				conn, err := l.Accept()
 				if err != nil {
-					log.Fatal(err)
+					fmt.Println(err)
+					return
 				}
 
 				go func(c net.Conn) {
-					<-time.After(readDelay)
 					io.Copy(c, c)
 					c.Close()
 				}(conn)
@@ -78,15 +138,17 @@ func FlakyServer(done chan bool, connectDelay, readDelay time.Duration) (net.Lis
 	return l, nil
 }
 
-// TLSEchoServer uses the test cert and key files.
-func TLSEchoServer(done chan bool, crtFilename, keyFilename string) (net.Listener, error) {
+// StartTLSEchoServer accepts plaintext connections and waits for the client to send
+// the line "STARTTLS\n". It replies with "OK\n", upgrades the connection to TLS using
+// the test cert and key files and then echoes any data sent through it.
+func StartTLSEchoServer(done chan bool, crtFilename, keyFilename string) (net.Listener, error) {
 	cer, err := tls.LoadX509KeyPair(crtFilename, keyFilename)
 	if err != nil {
 		return nil, err
 	}
 
 	config := &tls.Config{Certificates: []tls.Certificate{cer}}
-	l, err := tls.Listen("tcp", ":0", config)
+	l, err := net.Listen("tcp", ":0")
 	if err != nil {
 		return nil, err
 	}
@@ -105,8 +167,19 @@ func TLSEchoServer(done chan bool, crtFilename, keyFilename string) (net.Listene
 				}
 
 				go func(c net.Conn) {
-					io.Copy(c, c)
-					c.Close()
+					defer c.Close()
+
+					line, err := bufio.NewReader(c).ReadString('\n')
+					if err != nil || line != "STARTTLS\n" {
+						return
+					}
+
+					if _, err = c.Write([]byte("OK\n")); err != nil {
+						return
+					}
+
+					tlsConn := tls.Server(c, config)
+					io.Copy(tlsConn, tlsConn)
 				}(conn)
 			}
 		}
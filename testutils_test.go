@@ -0,0 +1,334 @@
+package eventedconnection_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestLatencyEchoServer(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.LatencyEchoServer(done, testutils.LatencyOptions{
+		Latency:        100 * time.Millisecond,
+		Jitter:         20 * time.Millisecond,
+		BytesPerSecond: 100,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String()}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err = con.WriteString("0123456789abcdefghij"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = con.ReadWithTimeout(50 * time.Millisecond)
+	assertEqual(t, err, ErrReadTimeout)
+
+	// 20 bytes at 100 bytes per second arrive in 10 byte chunks, 100ms apart
+	var echoed []byte
+	for len(echoed) < 20 {
+		data, err := con.ReadWithTimeout(2 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		echoed = append(echoed, data...)
+	}
+	assertEqual(t, string(echoed), "0123456789abcdefghij")
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Expected the echo to take at least 200ms, took %v", elapsed)
+	}
+}
+
+func TestFragmentingEchoServer(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.FragmentingEchoServer(done, testutils.FragmentOptions{
+		MaxFragmentSize: 3,
+		Gap:             10 * time.Millisecond,
+		CoalesceWindow:  50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String()}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, message := range []string{"hello", "world"} {
+		if err = con.WriteString(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// both messages are merged and arrive in pieces of at most 3 bytes
+	var echoed []byte
+	reads := 0
+	for len(echoed) < len("helloworld") {
+		data, err := con.ReadWithTimeout(2 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		echoed = append(echoed, data...)
+		reads++
+	}
+	assertEqual(t, string(echoed), "helloworld")
+	if reads < 4 {
+		t.Errorf("Expected at least 4 fragments, read %d", reads)
+	}
+}
+
+func TestRecordingServer(t *testing.T) {
+	done := make(chan bool)
+	l, recorder, err := testutils.RecordingServer(done, testutils.RecordingOptions{Echo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String()}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.WriteString("first"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "first")
+
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("second"); err != nil {
+		t.Fatal(err)
+	}
+	received, err := recorder.WaitForReceived(len("firstsecond"), 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(received), "firstsecond")
+	assertEqual(t, recorder.ConnectionCount(), 2)
+
+	con.Close()
+	connections, err := recorder.WaitForClosed(2, 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(connections[1].Received), "second")
+	assertEqual(t, len(connections[1].Payloads), 1)
+	assertEqual(t, connections[0].CloseReason, io.EOF)
+}
+
+func TestStallingServer(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.StallingServer(done, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:         l.Addr().String(),
+		WriteTimeout:     200 * time.Millisecond,
+		WriteErrorPolicy: WriteErrorKeepOnTimeout,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+	large := make([]byte, 64*1024*1024)
+	if err = con.WriteBytes(large); !errors.Is(err, ErrWriteTimeout) {
+		t.Fatalf("expected ErrWriteTimeout, got %v", err)
+	}
+	_, err = con.ReadWithTimeout(50 * time.Millisecond)
+	assertEqual(t, err, ErrReadTimeout)
+}
+
+func TestProxy(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+	proxy, err := testutils.NewProxy(done, l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{Endpoint: proxy.Addr()}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// stalled data arrives once resumed
+	proxy.Stall()
+	if err = con.WriteString("stalled"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = con.ReadWithTimeout(100 * time.Millisecond)
+	assertEqual(t, err, ErrReadTimeout)
+	proxy.Resume()
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "stalled")
+
+	proxy.Corrupt(DirectionRead, 1)
+	if err = con.WriteString("abc"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = con.ReadWithTimeout(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), string([]byte{'a' ^ 0xff, 'b', 'c'}))
+
+	// the echo is cut short and the connection closed
+	proxy.CutAfter(DirectionRead, 3)
+	if err = con.WriteString("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = con.ReadWithTimeout(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "hel")
+	select {
+	case <-con.Disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the connection to be cut")
+	}
+}
+
+func TestScriptedServer(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.ScriptedServer(done,
+		testutils.Accept(), testutils.Send([]byte("hello")), testutils.Wait(50*time.Millisecond), testutils.Reset(),
+		testutils.Accept(), testutils.Receive(4), testutils.Send([]byte("pong")), testutils.Close(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String()}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	// the first connection is reset after the greeting
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "hello")
+	select {
+	case <-con.Disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the connection to be reset")
+	}
+	if err = con.Err(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Expected the connection to be reset, got %v", err)
+	}
+
+	// the second one answers a ping and hangs up
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("ping"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = con.ReadWithTimeout(2 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "pong")
+	select {
+	case <-con.Disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the server to hang up")
+	}
+	assertEqual(t, con.Err(), io.EOF)
+}
+
+func TestSlowWriterServer(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.SlowWriterServer(done, []byte("0123456789"), 2, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String()}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// not all of it arrives in time
+	start := time.Now()
+	buffer := make([]byte, 10)
+	_, err = con.ReadFull(buffer, 30*time.Millisecond)
+	assertEqual(t, err, os.ErrDeadlineExceeded)
+
+	// but it does eventually
+	if _, err = con.ReadFull(buffer, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(buffer), "0123456789")
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected the response to take at least 80ms, took %v", elapsed)
+	}
+}
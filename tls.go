@@ -0,0 +1,522 @@
+package eventedconnection
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// setTLSSettings copies the TLS settings from conf. They are used when dialing with
+// TLS or STARTTLS and by UpgradeTLS. conn.settingsMutex must be held unless the client
+// is being created. The CertExpiryHook and CertExpiryWarning are set by NewClient only,
+// so the default warning and the hook's panic recovery survive ApplyConfig.
+func (conn *Client) setTLSSettings(conf *Config) {
+	conn.tlsConfig = conf.TLSConfig
+	conn.useTLS = conf.UseTLS
+	conn.caFile = conf.CAFile
+	conn.getClientCertificate = conf.GetClientCertificate
+	conn.pins = newCertificatePins(conf.PinnedPublicKeys, conf.PinnedCertificates)
+	conn.nextProtos = conf.NextProtos
+	conn.tlsMinVersion = conf.TLSMinVersion
+	conn.tlsMaxVersion = conf.TLSMaxVersion
+	conn.tlsCipherSuites = conf.TLSCipherSuites
+
+	conn.certReloader = nil
+	if len(conf.CertFile) > 0 || len(conf.KeyFile) > 0 {
+		conn.certReloader = &certReloader{certFile: conf.CertFile, keyFile: conf.KeyFile}
+	}
+}
+
+// loadTLSConfig returns the TLS config used for dialing. If certificate files were
+// configured they are loaded (and validated) on every call so that updated files
+// are picked up on the next Connect.
+func (conn *Client) loadTLSConfig() (*tls.Config, error) {
+	conn.settingsMutex.RLock()
+	defer conn.settingsMutex.RUnlock()
+
+	if !conn.useTLS && conn.startTLSHook == nil {
+		return nil, nil
+	}
+
+	return conn.applyTLSOverrides(conn.tlsConfig)
+}
+
+// applyTLSOverrides returns a copy of base with the TLS settings of the Config (client
+// certificates, CAFile, pins, versions and so on) applied on top, or base itself if
+// there are none. conn.settingsMutex must be held.
+func (conn *Client) applyTLSOverrides(base *tls.Config) (*tls.Config, error) {
+	if !conn.hasTLSOverrides() {
+		return base, nil
+	}
+
+	var config *tls.Config
+	if base == nil {
+		config = &tls.Config{}
+	} else {
+		config = base.Clone()
+	}
+
+	if conn.certReloader != nil {
+		// load eagerly so that bad files are reported by Connect rather than mid-handshake
+		if _, err := conn.certReloader.certificate(); err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = conn.certReloader.GetClientCertificate
+	}
+
+	if conn.getClientCertificate != nil {
+		config.GetClientCertificate = conn.getClientCertificate
+	}
+
+	if len(conn.caFile) > 0 {
+		pem, err := os.ReadFile(conn.caFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read caFile: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in caFile %s", conn.caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if len(conn.nextProtos) > 0 {
+		config.NextProtos = conn.nextProtos
+	}
+
+	if conn.tlsMinVersion != 0 {
+		config.MinVersion = conn.tlsMinVersion
+	}
+
+	if conn.tlsMaxVersion != 0 {
+		config.MaxVersion = conn.tlsMaxVersion
+	}
+
+	if len(conn.tlsCipherSuites) > 0 {
+		config.CipherSuites = conn.tlsCipherSuites
+	}
+
+	if conn.pins != nil {
+		config.VerifyConnection = conn.pins.verifyConnection(config.VerifyConnection)
+	}
+
+	return config, nil
+}
+
+// hasTLSOverrides reports whether any Config settings need to be applied on top of conn.tlsConfig
+func (conn *Client) hasTLSOverrides() bool {
+	return conn.certReloader != nil || conn.getClientCertificate != nil || len(conn.caFile) > 0 ||
+		conn.pins != nil || len(conn.nextProtos) > 0 || conn.tlsMinVersion != 0 ||
+		conn.tlsMaxVersion != 0 || len(conn.tlsCipherSuites) > 0
+}
+
+// ParseTLSVersion converts a TLS version name such as "1.2", "TLS1.3" or "TLSv1.2"
+// into the corresponding tls.VersionTLS* constant.
+func ParseTLSVersion(name string) (uint16, error) {
+	version := strings.ToUpper(strings.TrimSpace(name))
+	version = strings.TrimPrefix(version, "TLS")
+	version = strings.TrimPrefix(version, "V")
+	version = strings.TrimSpace(version)
+
+	switch version {
+	case "1.0", "10":
+		return tls.VersionTLS10, nil
+	case "1.1", "11":
+		return tls.VersionTLS11, nil
+	case "1.2", "12":
+		return tls.VersionTLS12, nil
+	case "1.3", "13":
+		return tls.VersionTLS13, nil
+	}
+
+	return 0, fmt.Errorf("unknown TLS version %q", name)
+}
+
+// ParseCipherSuites converts cipher suite names (as returned by tls.CipherSuiteName,
+// e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256") into their IDs.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// GetTLSConnectionState returns the state of the TLS connection (negotiated version,
+// cipher suite, ALPN protocol, peer certificates, etc). The second return value is false
+// if the client is not connected or the connection is not using TLS. It may be called
+// from the AfterConnectHook to branch on the negotiated parameters.
+func (conn *Client) GetTLSConnectionState() (tls.ConnectionState, bool) {
+	tlsConn, ok := conn.rawConnection().(*tls.Conn)
+	if !ok {
+		return tls.ConnectionState{}, false
+	}
+
+	return tlsConn.ConnectionState(), true
+}
+
+// GetNegotiatedProtocol returns the application protocol negotiated via ALPN,
+// or an empty string if none was negotiated.
+func (conn *Client) GetNegotiatedProtocol() string {
+	state, _ := conn.GetTLSConnectionState()
+	return state.NegotiatedProtocol
+}
+
+// certificatePins holds the base64 encoded SHA-256 hashes a server certificate
+// (or its public key) must match.
+type certificatePins struct {
+	spki map[string]bool // hashes of the SubjectPublicKeyInfo
+	cert map[string]bool // hashes of the DER encoded certificate
+}
+
+// newCertificatePins returns nil if no pins are configured. Pins may be prefixed
+// with "sha256/" as in HPKP headers.
+func newCertificatePins(spkiHashes, certHashes []string) *certificatePins {
+	if len(spkiHashes) == 0 && len(certHashes) == 0 {
+		return nil
+	}
+
+	pins := &certificatePins{spki: map[string]bool{}, cert: map[string]bool{}}
+	for _, hash := range spkiHashes {
+		pins.spki[strings.TrimPrefix(hash, "sha256/")] = true
+	}
+	for _, hash := range certHashes {
+		pins.cert[strings.TrimPrefix(hash, "sha256/")] = true
+	}
+
+	return pins
+}
+
+// verifyConnection returns a tls.Config.VerifyConnection func which fails the
+// handshake unless one of the peer's certificates matches a pin. next, if not nil,
+// is the previously configured VerifyConnection and is called first.
+func (pins *certificatePins) verifyConnection(next func(tls.ConnectionState) error) func(tls.ConnectionState) error {
+	return func(state tls.ConnectionState) error {
+		if next != nil {
+			if err := next(state); err != nil {
+				return err
+			}
+		}
+
+		presented := make([]string, 0, len(state.PeerCertificates))
+		for _, cert := range state.PeerCertificates {
+			spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			certHash := sha256.Sum256(cert.Raw)
+			spki := base64.StdEncoding.EncodeToString(spkiHash[:])
+
+			if pins.spki[spki] || pins.cert[base64.StdEncoding.EncodeToString(certHash[:])] {
+				return nil
+			}
+			presented = append(presented, spki)
+		}
+
+		return fmt.Errorf("%w: %s presented public keys [%s]", ErrCertificatePinMismatch,
+			state.ServerName, strings.Join(presented, ", "))
+	}
+}
+
+// certReloader provides the client certificate from a cert/key file pair and
+// reloads it whenever either file's modification time changes, so certificates
+// can be rotated on disk without recreating the Client.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mutex       sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// GetClientCertificate satisfies tls.Config.GetClientCertificate
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.certificate()
+}
+
+// certificate returns the cached certificate, reloading it if the files changed
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	if len(r.certFile) == 0 || len(r.keyFile) == 0 {
+		return nil, errors.New("both certFile and keyFile are required for a client certificate")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load client certificate: %w", err)
+	}
+
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load client certificate: %w", err)
+	}
+
+	if r.cert != nil && certInfo.ModTime().Equal(r.certModTime) && keyInfo.ModTime().Equal(r.keyModTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load client certificate: %w", err)
+	}
+
+	r.cert = &cert
+	r.certModTime = certInfo.ModTime()
+	r.keyModTime = keyInfo.ModTime()
+
+	return r.cert, nil
+}
+
+// readerPause is used to ask the read loop to stop reading from the connection
+// until resume is closed.
+type readerPause struct {
+	paused chan struct{} // closed by the read loop once it has stopped reading
+	resume chan struct{} // closed once the read loop may continue
+}
+
+// UpgradeTLS performs a TLS handshake over the already established plaintext
+// connection and swaps the connection for the resulting TLS connection. This is
+// used for protocols that negotiate STARTTLS after an initial exchange: send the
+// protocol's upgrade command, wait for the endpoint to accept it, then call UpgradeTLS.
+// The read loop is paused for the duration of the handshake; UpgradeTLS fails without
+// closing the connection if the loop can't be paused within the connection timeout,
+// e.g. because the Read channel is full. If the handshake fails the connection is closed.
+// The Config's client certificate and version settings (CertFile, KeyFile,
+// GetClientCertificate, TLSMinVersion and so on) are applied on top of tlsConfig, just
+// as they are on top of Config.TLSConfig. CAFile, pins and NextProtos require UseTLS or
+// StartTLSHook, so set their tls.Config equivalents in tlsConfig instead.
+func (conn *Client) UpgradeTLS(tlsConfig *tls.Config) error {
+	connection := conn.rawConnection()
+	if connection == nil {
+		err := fmt.Errorf("called UpgradeTLS without a connection: %w", conn.notConnected())
+		conn.handleError(err)
+		return err
+	}
+
+	if _, ok := connection.(*tls.Conn); ok {
+		err := errors.New("connection is already using TLS")
+		conn.handleError(err)
+		return err
+	}
+
+	conn.settingsMutex.RLock()
+	tlsConfig, err := conn.applyTLSOverrides(tlsConfig)
+	conn.settingsMutex.RUnlock()
+	if err != nil {
+		conn.handleError(err)
+		return err
+	}
+
+	resume, err := conn.pauseReader(connection)
+	if err != nil {
+		conn.handleError(err)
+		return err
+	}
+	defer resume()
+
+	conn.mutex.RLock()
+	endpoint := conn.remoteEndpoint
+	conn.mutex.RUnlock()
+
+	ctx := conn.connectionContext()
+	tlsConn, err := conn.handshake(ctx, connection, tlsConfig, endpoint)
+	if err != nil {
+		conn.handleError(err)
+		conn.Close()
+		return err
+	}
+
+	conn.setConnection(tlsConn)
+	conn.checkCertExpiry()
+	return nil
+}
+
+// checkCertExpiry calls the CertExpiryHook for every peer certificate of the
+// current TLS connection that expires within conn.certExpiryWarning.
+func (conn *Client) checkCertExpiry() {
+	if conn.certExpiryHook == nil {
+		return
+	}
+
+	state, ok := conn.GetTLSConnectionState()
+	if !ok {
+		return
+	}
+
+	now := conn.clock.Now()
+	for _, cert := range state.PeerCertificates {
+		expiresIn := cert.NotAfter.Sub(now)
+		if expiresIn > conn.certExpiryWarning {
+			continue
+		}
+
+		if err := conn.certExpiryHook(cert, expiresIn); err != nil {
+			conn.handleError(err)
+		}
+	}
+}
+
+// startTLS runs the StartTLSHook over a freshly dialed plaintext connection
+// and upgrades it using tlsConfig.
+func (conn *Client) startTLS(ctx context.Context, connection net.Conn, endpoint string, tlsConfig *tls.Config) (net.Conn, error) {
+	err := conn.startTLSHook(connection)
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	tlsConn, err := conn.handshake(ctx, connection, tlsConfig, endpoint)
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+// handshake performs the client side of a TLS handshake over an existing connection
+func (conn *Client) handshake(ctx context.Context, connection net.Conn, tlsConfig *tls.Config, endpoint string) (*tls.Conn, error) {
+	ctx, span := conn.startSpan(ctx, "eventedconnection.Handshake")
+	defer span.End()
+
+	tlsConn := tls.Client(connection, clientTLSConfig(tlsConfig, endpoint))
+
+	ctx, cancel := context.WithTimeout(ctx, conn.GetConnectionTimeout())
+	defer cancel()
+
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		err = opError("handshake", connection, err)
+		recordSpanError(span, err)
+		return nil, err
+	}
+
+	state := tlsConn.ConnectionState()
+	span.SetAttributes(
+		attribute.String("tls.protocol.version", tls.VersionName(state.Version)),
+		attribute.String("tls.cipher", tls.CipherSuiteName(state.CipherSuite)),
+		attribute.String("tls.alpn", state.NegotiatedProtocol),
+	)
+
+	return tlsConn, nil
+}
+
+// clientTLSConfig copies tlsConfig and fills in the ServerName from the endpoint
+// the same way tls.Dial does.
+func clientTLSConfig(tlsConfig *tls.Config, endpoint string) *tls.Config {
+	var config *tls.Config
+	if tlsConfig == nil {
+		config = &tls.Config{}
+	} else {
+		config = tlsConfig.Clone()
+	}
+
+	if len(config.ServerName) == 0 {
+		if host, _, err := net.SplitHostPort(endpoint); err == nil {
+			config.ServerName = host
+		}
+	}
+
+	return config
+}
+
+// pauseReader asks the read loop to stop reading from connection and waits until it has,
+// for up to the connection timeout since the loop may be blocked delivering a message.
+// The returned func must be called to let the read loop continue.
+func (conn *Client) pauseReader(connection net.Conn) (func(), error) {
+	p := &readerPause{paused: make(chan struct{}), resume: make(chan struct{})}
+
+	conn.mutex.Lock()
+	if conn.pause != nil {
+		conn.mutex.Unlock()
+		return nil, errors.New("read loop is already paused")
+	}
+	conn.pause = p
+	disconnected := conn.Disconnected
+	conn.mutex.Unlock()
+
+	resume := func() {
+		conn.mutex.Lock()
+		conn.pause = nil
+		conn.mutex.Unlock()
+		close(p.resume)
+	}
+
+	// unblock a pending Read so the loop notices the pause
+	if err := connection.SetReadDeadline(time.Now()); err != nil {
+		resume()
+		return nil, err
+	}
+
+	timer := conn.clock.NewTimer(conn.GetConnectionTimeout())
+	defer timer.Stop()
+
+	select {
+	case <-p.paused:
+		// clear the forced deadline so the paused connection can be used again
+		if err := connection.SetReadDeadline(time.Time{}); err != nil {
+			resume()
+			return nil, err
+		}
+		return resume, nil
+	case <-disconnected:
+		resume()
+		return nil, fmt.Errorf("read loop couldn't be paused: %w", ErrClosed)
+	case <-timer.C():
+		resume()
+		return nil, errors.New("timed out waiting for the read loop to pause")
+	}
+}
+
+// pausePending reports whether pauseReader is waiting for the read loop to stand still.
+// The read loop checks it after setting its read deadline, which may have replaced the
+// one pauseReader forced.
+func (conn *Client) pausePending() bool {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+
+	return conn.pause != nil
+}
+
+// waitIfPaused blocks the read loop while a pause is pending
+func (conn *Client) waitIfPaused() {
+	conn.mutex.RLock()
+	p := conn.pause
+	conn.mutex.RUnlock()
+
+	if p != nil {
+		close(p.paused)
+		<-p.resume
+	}
+}
+
+// interruptedByPause reports whether a read error was caused by pauseReader
+// forcing the read deadline.
+func (conn *Client) interruptedByPause(err error) bool {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+
+	return conn.pause != nil && errors.Is(err, os.ErrDeadlineExceeded)
+}
@@ -0,0 +1,674 @@
+package eventedconnection_test
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_StartTLSHook(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.StartTLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:    l.Addr().String(),
+		ReadTimeout: 1 * time.Second,
+		TLSConfig:   &tls.Config{InsecureSkipVerify: true},
+		StartTLSHook: func(rw io.ReadWriter) error {
+			if _, err := rw.Write([]byte("STARTTLS\n")); err != nil {
+				return err
+			}
+
+			line, err := bufio.NewReader(rw).ReadString('\n')
+			if err != nil {
+				return err
+			}
+
+			if line != "OK\n" {
+				return errors.New("server refused STARTTLS")
+			}
+			return nil
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal("Expected err to be nil")
+	}
+
+	err = con.Connect()
+	defer con.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("Testing STARTTLS payload")
+	if err = con.Write(&payload); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case received := <-con.Read:
+		assertEqual(t, string(*received), string(payload))
+	case <-time.After(2 * time.Second):
+		t.Error("Test timed out while waiting to read from connection")
+	}
+}
+
+func TestClient_UpgradeTLS(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.StartTLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:    l.Addr().String(),
+		ReadTimeout: 1 * time.Second,
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal("Expected err to be nil")
+	}
+
+	err = con.Connect()
+	defer con.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	command := []byte("STARTTLS\n")
+	if err = con.Write(&command); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case received := <-con.Read:
+		assertEqual(t, string(*received), "OK\n")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the STARTTLS reply")
+	}
+
+	if err = con.UpgradeTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.IsActive(), true)
+
+	payload := []byte("Testing upgraded payload")
+	if err = con.Write(&payload); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case received := <-con.Read:
+		assertEqual(t, string(*received), string(payload))
+	case <-time.After(2 * time.Second):
+		t.Error("Test timed out while waiting to read from connection")
+	}
+
+	// a second upgrade of the same connection is refused
+	assertNotNil(t, con.UpgradeTLS(&tls.Config{InsecureSkipVerify: true}))
+}
+
+func TestClient_UpgradeTLSOverrides(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.StartTLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:      l.Addr().String(),
+		TLSMaxVersion: tls.VersionTLS12,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("STARTTLS\n"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadWithTimeout(2 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "OK\n")
+
+	// the Config's TLS settings apply on top of the config passed to UpgradeTLS
+	if err = con.UpgradeTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	state, ok := con.GetTLSConnectionState()
+	assertEqual(t, ok, true)
+	assertEqual(t, state.Version, uint16(tls.VersionTLS12))
+}
+
+func TestClient_UpgradeTLSBlockedReader(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:          l.Addr().String(),
+		ConnectionTimeout: 100 * time.Millisecond,
+		ReadChannelSize:   1,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// fill the Read channel so the read loop blocks delivering the second message
+	for _, message := range []string{"first", "second"} {
+		if err = con.WriteString(message); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	start := time.Now()
+	assertNotNil(t, con.UpgradeTLS(&tls.Config{InsecureSkipVerify: true}))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected UpgradeTLS to give up after the connection timeout, took %v", elapsed)
+	}
+
+	// the read loop carries on once the consumer catches up
+	for _, message := range []string{"first", "second"} {
+		data, err := con.ReadWithTimeout(2 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertEqual(t, string(data), message)
+	}
+}
+
+func TestClient_MutualTLSFromFiles(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.MutualTLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key", "./testutils/testserver.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{}
+	err = conf.Unmarshal(strings.NewReader(`{
+		"endpoint": "` + l.Addr().String() + `",
+		"connectionTimeout": "1s",
+		"readTimeout": "1s",
+		"writeTimeout": "1s",
+		"useTLS": true,
+		"certFile": "./testutils/testserver.crt",
+		"keyFile": "./testutils/testserver.key"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, conf.UseTLS, true)
+	assertEqual(t, conf.CertFile, "./testutils/testserver.crt")
+
+	// the test cert has no SANs so hostname verification is skipped here
+	conf.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = con.Connect()
+	defer con.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("Testing mTLS payload")
+	if err = con.Write(&payload); err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case received := <-con.Read:
+		assertEqual(t, string(*received), string(payload))
+	case <-con.Disconnected:
+		t.Error("Connection was closed; client certificate was not presented")
+	case <-time.After(2 * time.Second):
+		t.Error("Test timed out while waiting to read from connection")
+	}
+}
+
+func TestClient_MutualTLSFromFiles_Invalid(t *testing.T) {
+	numErrors := 0
+	conf := Config{
+		Endpoint:    "localhost:5555",
+		UseTLS:      true,
+		TLSConfig:   &tls.Config{},
+		CertFile:    "./testutils/testserver.crt",
+		CAFile:      "./testutils/does-not-exist.crt",
+		OnErrorHook: func(err error) error { numErrors++; return err },
+	}
+
+	// missing key file
+	_, err := NewClient(&conf)
+	assertNotNil(t, err)
+
+	conf.KeyFile = "./testutils/testserver.key"
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// missing CA file
+	assertNotNil(t, con.Connect())
+	assertEqual(t, numErrors, 1)
+	con.Close()
+}
+
+func TestClient_GetClientCertificate(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.MutualTLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key", "./testutils/testserver.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	cert, err := tls.LoadX509KeyPair("./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	numCalls := 0
+	conf := Config{
+		Endpoint:    l.Addr().String(),
+		ReadTimeout: 1 * time.Second,
+		UseTLS:      true,
+		TLSConfig:   &tls.Config{InsecureSkipVerify: true},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			numCalls++
+			return &cert, nil
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = con.Reconnect(); err != nil {
+			t.Fatal(err)
+		}
+
+		payload := []byte("Testing rotated certificate")
+		if err = con.Write(&payload); err != nil {
+			t.Error(err)
+		}
+
+		select {
+		case received := <-con.Read:
+			assertEqual(t, string(*received), string(payload))
+		case <-con.Disconnected:
+			t.Error("Connection was closed; client certificate was not presented")
+		case <-time.After(2 * time.Second):
+			t.Error("Test timed out while waiting to read from connection")
+		}
+	}
+	con.Close()
+
+	// the certificate is requested on every handshake
+	assertEqual(t, numCalls, 2)
+}
+
+func TestClient_CertificateFileRotation(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+
+	conf := Config{
+		Endpoint:    "localhost:5555",
+		UseTLS:      true,
+		TLSConfig:   &tls.Config{},
+		CertFile:    certFile,
+		KeyFile:     keyFile,
+		OnErrorHook: func(err error) error { return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the files don't exist yet
+	assertNotNil(t, con.Connect())
+	con.Close()
+
+	done := make(chan bool)
+	l, err := testutils.MutualTLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key", "./testutils/testserver.crt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	copyFile(t, "./testutils/testserver.crt", certFile)
+	copyFile(t, "./testutils/testserver.key", keyFile)
+
+	conf.Endpoint = l.Addr().String()
+	conf.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same client picks up the rotated files on reconnect
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	assertNotNil(t, con.Reconnect())
+
+	copyFile(t, "./testutils/testserver.key", keyFile)
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.IsActive(), true)
+	con.Close()
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.WriteFile(dst, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_CertificatePinning(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.TLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	pair, err := tls.LoadX509KeyPair("./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spkiHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	certHash := sha256.Sum256(cert.Raw)
+
+	numErrors := 0
+	conf := Config{
+		Endpoint:         l.Addr().String(),
+		UseTLS:           true,
+		TLSConfig:        &tls.Config{InsecureSkipVerify: true},
+		PinnedPublicKeys: []string{"sha256/" + base64.StdEncoding.EncodeToString(spkiHash[:])},
+		OnErrorHook:      func(err error) error { numErrors++; return err },
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Error(err)
+	}
+	con.Close()
+
+	conf.PinnedPublicKeys = nil
+	conf.PinnedCertificates = []string{base64.StdEncoding.EncodeToString(certHash[:])}
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Error(err)
+	}
+	con.Close()
+	assertEqual(t, numErrors, 0)
+
+	conf.PinnedCertificates = nil
+	conf.PinnedPublicKeys = []string{base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))}
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = con.Connect()
+	defer con.Close()
+	if !errors.Is(err, ErrCertificatePinMismatch) {
+		t.Errorf("Expected ErrCertificatePinMismatch; got %v", err)
+	}
+	assertEqual(t, con.IsActive(), false)
+	assertEqual(t, numErrors, 1)
+}
+
+func TestClient_ALPN(t *testing.T) {
+	done := make(chan bool)
+	cert, err := tls.LoadX509KeyPair("./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := testutils.TLSEchoServerWithConfig(done, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"evented/2", "evented/1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	negotiated := ""
+	var con *Client
+	conf := Config{
+		Endpoint:   l.Addr().String(),
+		UseTLS:     true,
+		TLSConfig:  &tls.Config{InsecureSkipVerify: true},
+		NextProtos: []string{"evented/1"},
+		AfterConnectHook: func() error {
+			negotiated = con.GetNegotiatedProtocol()
+			return nil
+		},
+	}
+
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.GetNegotiatedProtocol(), "")
+
+	err = con.Connect()
+	defer con.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, negotiated, "evented/1")
+	state, ok := con.GetTLSConnectionState()
+	assertEqual(t, ok, true)
+	assertEqual(t, state.HandshakeComplete, true)
+	assertEqual(t, state.NegotiatedProtocol, "evented/1")
+}
+
+func TestConfig_UnmarshalTLSPolicy(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.TLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{}
+	err = conf.Unmarshal(strings.NewReader(`{
+		"endpoint": "` + l.Addr().String() + `",
+		"connectionTimeout": "1s",
+		"readTimeout": "1s",
+		"writeTimeout": "1s",
+		"useTLS": true,
+		"tlsMinVersion": "1.2",
+		"tlsMaxVersion": "TLSv1.2",
+		"cipherSuites": ["TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, conf.TLSMinVersion, uint16(tls.VersionTLS12))
+	assertEqual(t, conf.TLSMaxVersion, uint16(tls.VersionTLS12))
+
+	conf.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = con.Connect()
+	defer con.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state, _ := con.GetTLSConnectionState()
+	assertEqual(t, state.Version, uint16(tls.VersionTLS12))
+	assertEqual(t, state.CipherSuite, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384)
+
+	err = conf.Unmarshal(strings.NewReader(`{"connectionTimeout": "1s", "readTimeout": "1s", "writeTimeout": "1s", "tlsMinVersion": "1.4"}`))
+	assertNotNil(t, err)
+
+	err = conf.Unmarshal(strings.NewReader(`{"connectionTimeout": "1s", "readTimeout": "1s", "writeTimeout": "1s", "cipherSuites": ["TLS_NOT_A_CIPHER"]}`))
+	assertNotNil(t, err)
+}
+
+func TestClient_CertExpiryHook(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.TLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	var expiring []*x509.Certificate
+	conf := Config{
+		Endpoint:  l.Addr().String(),
+		UseTLS:    true,
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		CertExpiryHook: func(cert *x509.Certificate, expiresIn time.Duration) error {
+			expiring = append(expiring, cert)
+			return nil
+		},
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the test certificate is valid for much longer than the default window
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+	assertEqual(t, len(expiring), 0)
+
+	conf.CertExpiryWarning = 100 * 365 * 24 * time.Hour
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+	if assertEqual(t, len(expiring), 1); len(expiring) == 1 {
+		assertEqual(t, expiring[0].Subject.CommonName, "Test")
+	}
+}
+
+func TestClient_HandshakeTimeout(t *testing.T) {
+	// a server that accepts connections but never answers the TLS handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	conf := Config{
+		Endpoint:          l.Addr().String(),
+		UseTLS:            true,
+		TLSConfig:         &tls.Config{},
+		ConnectionTimeout: 100 * time.Millisecond,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = con.Connect()
+	if !errors.Is(err, ErrConnectTimeout) {
+		t.Fatalf("expected ErrConnectTimeout, got %v", err)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a net.Error timeout, got %v", err)
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("expected a *net.OpError, got %v", err)
+	}
+	assertEqual(t, opErr.Op, "handshake")
+	assertEqual(t, opErr.Addr.String(), l.Addr().String())
+}
@@ -0,0 +1,27 @@
+package eventedconnection
+
+import (
+	"io"
+
+	"github.com/BurntSushi/toml"
+)
+
+// DecodeTOML sets config fields from TOML data. It accepts the same keys as
+// Unmarshal does for JSON, with durations given as strings parsable by
+// time.ParseDuration:
+//
+//	endpoint = "example.com:443"
+//	connectionTimeout = "5s"
+//	readTimeout = "1m"
+//	writeTimeout = "5s"
+//	useTLS = true
+//	certFile = "/etc/client.crt"
+//	keyFile = "/etc/client.key"
+func (conf *Config) DecodeTOML(r io.Reader) error {
+	var fc fileConfig
+	if _, err := toml.NewDecoder(r).Decode(&fc); err != nil {
+		return err
+	}
+
+	return conf.apply(&fc)
+}
@@ -0,0 +1,49 @@
+package eventedconnection_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+)
+
+func TestConfig_DecodeTOML(t *testing.T) {
+	conf := NewConfig()
+	err := conf.DecodeTOML(strings.NewReader(`
+endpoint = "localhost:8080"
+connectionTimeout = "5s"
+readTimeout = "1m"
+writeTimeout = "2s"
+readBufferSize = 1024
+useTLS = true
+certFile = "./testutils/testserver.crt"
+keyFile = "./testutils/testserver.key"
+caFile = "./testutils/testserver.crt"
+tlsMinVersion = "1.2"
+
+[labels]
+region = "eu"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertEqual(t, conf.Endpoint, "localhost:8080")
+	assertEqual(t, conf.ConnectionTimeout, 5*time.Second)
+	assertEqual(t, conf.ReadTimeout, time.Minute)
+	assertEqual(t, conf.WriteTimeout, 2*time.Second)
+	assertEqual(t, conf.ReadBufferSize, 1024)
+	assertEqual(t, conf.UseTLS, true)
+	assertEqual(t, conf.CertFile, "./testutils/testserver.crt")
+	assertEqual(t, conf.KeyFile, "./testutils/testserver.key")
+	assertEqual(t, conf.CAFile, "./testutils/testserver.crt")
+	assertEqual(t, conf.Labels["region"], "eu")
+
+	conf = NewConfig()
+	err = conf.DecodeTOML(strings.NewReader(`
+endpoint = "localhost:8080"
+connectionTimeout = "five seconds"
+`))
+	assertNotNil(t, err)
+}
@@ -0,0 +1,73 @@
+package eventedconnection
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation scope name used for the client's tracer
+const tracerName = "github.com/joedursun/EventedConnection"
+
+// startSpan starts a span with the client's ID and labels as attributes
+func (conn *Client) startSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	opts = append(opts, trace.WithAttributes(conn.identityAttributes()...))
+	return conn.tracer.Start(ctx, name, opts...)
+}
+
+// startConnectionSpan starts the span covering the lifetime of a new connection.
+// It is a root span linked to the Connect span in ctx so that long lived
+// connections don't keep the trace of whatever established them open.
+func (conn *Client) startConnectionSpan(ctx context.Context, endpoint string) {
+	_, span := conn.startSpan(context.Background(), "eventedconnection.Connection",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(attribute.String("server.address", endpoint)),
+	)
+
+	conn.mutex.Lock()
+	conn.connectionSpan = span
+	conn.mutex.Unlock()
+}
+
+// endConnectionSpan ends the span of the current connection. conn.mutex must be held.
+func (conn *Client) endConnectionSpan() {
+	conn.connectionSpan.End()
+	conn.connectionSpan = noop.Span{}
+}
+
+// connectionContext returns a context carrying the span of the current connection
+func (conn *Client) connectionContext() context.Context {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+
+	return trace.ContextWithSpan(context.Background(), conn.connectionSpan)
+}
+
+// traceEvent adds an event to the span of the current connection
+func (conn *Client) traceEvent(name string, size int, err error) {
+	conn.mutex.RLock()
+	span := conn.connectionSpan
+	conn.mutex.RUnlock()
+
+	if !span.IsRecording() {
+		return
+	}
+
+	attrs := []attribute.KeyValue{attribute.Int("size", size)}
+	if err != nil {
+		attrs = append(attrs, attribute.String("error", err.Error()))
+	}
+	span.AddEvent(name, trace.WithAttributes(attrs...))
+}
+
+// recordSpanError marks span as failed if err is not nil. The span is not ended.
+func recordSpanError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
@@ -0,0 +1,84 @@
+package eventedconnection_test
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_Tracing(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.TLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	conf := Config{
+		Endpoint:       l.Addr().String(),
+		ReadTimeout:    1 * time.Second,
+		UseTLS:         true,
+		TLSConfig:      &tls.Config{InsecureSkipVerify: true},
+		TracerProvider: provider,
+	}
+
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte("Testing tracing")
+	if err = con.Write(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-con.Read:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	con.Close()
+
+	spans := map[string]int{}
+	var connection sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		spans[span.Name()]++
+		if span.Name() == "eventedconnection.Connection" && connection == nil {
+			connection = span
+		}
+	}
+
+	assertEqual(t, spans["eventedconnection.Connect"], 2)
+	assertEqual(t, spans["eventedconnection.Handshake"], 2)
+	assertEqual(t, spans["eventedconnection.Reconnect"], 1)
+	assertEqual(t, spans["eventedconnection.Connection"], 2)
+
+	if connection == nil {
+		t.Fatal("Expected a connection span")
+	}
+	assertEqual(t, len(connection.Links()), 1)
+
+	events := map[string]int{}
+	for _, event := range connection.Events() {
+		events[event.Name]++
+	}
+	assertEqual(t, events["write"], 1)
+	assertEqual(t, events["message.delivered"], 1)
+}
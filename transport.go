@@ -0,0 +1,82 @@
+package eventedconnection
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+)
+
+// Transport opens the connections used by the client, see Config.Transport. Dial
+// must honour the context's deadline and cancellation.
+type Transport interface {
+	Dial(ctx context.Context) (net.Conn, error)
+}
+
+// TransportFunc adapts an ordinary function to a Transport, e.g. one returning one
+// end of a net.Pipe
+type TransportFunc func(ctx context.Context) (net.Conn, error)
+
+// Dial calls f(ctx)
+func (f TransportFunc) Dial(ctx context.Context) (net.Conn, error) {
+	return f(ctx)
+}
+
+// TCPTransport dials Address (host:port) over TCP. It is what the client uses to
+// dial Config.Endpoint when no Transport is set.
+type TCPTransport struct {
+	Address string
+	Dialer  *net.Dialer // a zero net.Dialer if nil
+}
+
+// Dial opens a TCP connection to t.Address
+func (t TCPTransport) Dial(ctx context.Context) (net.Conn, error) {
+	dialer := t.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	return dialer.DialContext(ctx, "tcp", t.Address)
+}
+
+// TLSTransport dials Address (host:port) over TCP and completes a TLS handshake on
+// the connection before returning it. Unlike Config.UseTLS it doesn't support
+// certificate pinning or the other TLS settings of Config; use it to wrap a
+// TLS connection into another Transport.
+type TLSTransport struct {
+	Address string
+	Dialer  *net.Dialer // a zero net.Dialer if nil
+	Config  *tls.Config // the ServerName is taken from Address if it is unset
+}
+
+// Dial opens a TLS connection to t.Address
+func (t TLSTransport) Dial(ctx context.Context) (net.Conn, error) {
+	dialer := tls.Dialer{NetDialer: t.Dialer, Config: t.Config}
+	return dialer.DialContext(ctx, "tcp", t.Address)
+}
+
+// dialTransport opens a connection with conn.transport instead of dialing the
+// configured endpoints, and sets it up like a dialed one. The endpoint returned is
+// Config.Endpoint, or the connection's remote address if that is empty.
+func (conn *Client) dialTransport(ctx context.Context, tlsConfig *tls.Config) (net.Conn, string, error) {
+	endpoint := conn.GetEndpoint()
+
+	dialCtx, cancel := context.WithTimeout(ctx, conn.GetConnectionTimeout())
+	defer cancel()
+	connection, err := conn.dialWith(dialCtx, conn.transport)
+	if err != nil {
+		return nil, "", wrapTimeout(ErrConnectTimeout, err)
+	}
+
+	if connection, err = conn.setUpConnection(ctx, connection, endpoint, tlsConfig); err != nil {
+		return nil, "", wrapTimeout(ErrConnectTimeout, err)
+	}
+	if len(endpoint) == 0 {
+		endpoint = connection.RemoteAddr().String()
+	}
+	return connection, endpoint, nil
+}
+
+// dialWith dials transport, turning a panic in it into a HookPanicError
+func (conn *Client) dialWith(ctx context.Context, transport Transport) (connection net.Conn, err error) {
+	defer recoverHook("Transport", &err)
+	return transport.Dial(ctx)
+}
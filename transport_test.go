@@ -0,0 +1,86 @@
+package eventedconnection_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_Transport(t *testing.T) {
+	// every dial gets one end of an in-memory pipe echoed by the other end
+	dials := 0
+	transport := TransportFunc(func(ctx context.Context) (net.Conn, error) {
+		dials++
+		client, server := net.Pipe()
+		go func() {
+			io.Copy(server, server)
+			server.Close()
+		}()
+		return client, nil
+	})
+
+	conf := Config{Transport: transport}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, dials, 2)
+
+	if err = con.WriteString("piped"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), "piped")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+}
+
+func TestClient_TLSTransport(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.TLSEchoServer(done, "./testutils/testserver.crt", "./testutils/testserver.key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Transport: TLSTransport{
+			Address: l.Addr().String(),
+			Config:  &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("secret"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-con.Read:
+		assertEqual(t, string(*data), "secret")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting to read from connection")
+	}
+}
@@ -0,0 +1,170 @@
+package eventedconnection
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Validate checks the configuration for mistakes that would otherwise only show up
+// when connecting, or not at all: a malformed endpoint, negative durations or buffer
+// sizes, incomplete TLS settings and TLS settings that would be ignored because
+// neither UseTLS nor StartTLSHook is set. All problems found are returned
+// together (see errors.Join). NewClient calls Validate.
+func (conf *Config) Validate() error {
+	var errs []error
+
+	if conf.Transport != nil && len(conf.SRVName) > 0 {
+		errs = append(errs, errors.New("SRVName can't be combined with a Transport"))
+	}
+	if len(conf.Endpoint) == 0 && len(conf.SRVName) == 0 && conf.Transport == nil {
+		errs = append(errs, errors.New("invalid endpoint (empty string)"))
+	} else if len(conf.Endpoint) > 0 && conf.Transport == nil {
+		// with a Transport the Endpoint only names the connection
+		if _, port, err := net.SplitHostPort(conf.Endpoint); err != nil {
+			errs = append(errs, fmt.Errorf("invalid endpoint: %w", err))
+		} else if len(port) == 0 {
+			errs = append(errs, fmt.Errorf("invalid endpoint %q: missing port", conf.Endpoint))
+		}
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"ConnectionTimeout", conf.ConnectionTimeout},
+		{"ReadTimeout", conf.ReadTimeout},
+		{"WriteTimeout", conf.WriteTimeout},
+		{"CertExpiryWarning", conf.CertExpiryWarning},
+		{"SlowConsumerThreshold", conf.SlowConsumerThreshold},
+		{"IdleTimeout", conf.IdleTimeout},
+		{"WatchdogTimeout", conf.WatchdogTimeout},
+		{"HealthCheckInterval", conf.HealthCheckInterval},
+		{"HealthCheckTimeout", conf.HealthCheckTimeout},
+		{"KeepaliveInterval", conf.KeepaliveInterval},
+		{"MaxConnectionAge", conf.MaxConnectionAge},
+		{"CircuitBreakerCooldown", conf.CircuitBreakerCooldown},
+		{"WriteQueueTTL", conf.WriteQueueTTL},
+		{"DialRetryDelay", conf.DialRetryDelay},
+		{"DialRetryBudget", conf.DialRetryBudget},
+		{"ReconnectDelay", conf.ReconnectDelay},
+		{"MaxReconnectDelay", conf.MaxReconnectDelay},
+		{"MaxReconnectDuration", conf.MaxReconnectDuration},
+		{"RateWindow", conf.RateWindow},
+		{"RateSampleInterval", conf.RateSampleInterval},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", d.name))
+		}
+	}
+
+	if conf.ReconnectDelay > 0 && conf.MaxReconnectDelay > 0 && conf.ReconnectDelay > conf.MaxReconnectDelay {
+		errs = append(errs, errors.New("ReconnectDelay is greater than MaxReconnectDelay"))
+	}
+
+	if conf.RateWindow > 0 && conf.RateSampleInterval > conf.RateWindow {
+		errs = append(errs, errors.New("RateSampleInterval is greater than RateWindow"))
+	}
+
+	if conf.HealthCheckInterval > 0 && (len(conf.HealthCheckPayload) == 0 || conf.HealthCheckMatcher == nil) {
+		errs = append(errs, errors.New("HealthCheckInterval requires a HealthCheckPayload and a HealthCheckMatcher"))
+	}
+
+	if conf.IdleAction == IdlePing && len(conf.IdlePingPayload) == 0 {
+		errs = append(errs, errors.New("IdleAction ping requires an IdlePingPayload"))
+	}
+
+	if conf.KeepaliveInterval > 0 && len(conf.KeepalivePayload) == 0 && conf.KeepaliveHook == nil {
+		errs = append(errs, errors.New("KeepaliveInterval requires a KeepalivePayload or KeepaliveHook"))
+	}
+
+	if conf.WriteQueueSize < 0 {
+		errs = append(errs, errors.New("WriteQueueSize must not be negative"))
+	}
+	if conf.WriteQueueMaxBytes < 0 {
+		errs = append(errs, errors.New("WriteQueueMaxBytes must not be negative"))
+	}
+	if conf.WriteQueueFileMaxSize < 0 {
+		errs = append(errs, errors.New("WriteQueueFileMaxSize must not be negative"))
+	}
+	if len(conf.WriteQueueFile) > 0 && conf.WriteQueueSize == 0 {
+		errs = append(errs, errors.New("WriteQueueFile requires a WriteQueueSize"))
+	}
+	if conf.MaxDeliveryAttempts < 0 {
+		errs = append(errs, errors.New("MaxDeliveryAttempts must not be negative"))
+	}
+	if conf.MaxReconnectAttempts < 0 {
+		errs = append(errs, errors.New("MaxReconnectAttempts must not be negative"))
+	}
+	if conf.DialRetries < 0 {
+		errs = append(errs, errors.New("DialRetries must not be negative"))
+	}
+	if conf.CircuitBreakerThreshold < 0 {
+		errs = append(errs, errors.New("CircuitBreakerThreshold must not be negative"))
+	}
+
+	if conf.ReadBufferSize < 0 {
+		errs = append(errs, errors.New("ReadBufferSize must not be negative"))
+	}
+	switch len(conf.EncryptionKey) {
+	case 0, 16, 24, 32:
+	default:
+		errs = append(errs, fmt.Errorf("EncryptionKey must be 16, 24 or 32 bytes long, not %d", len(conf.EncryptionKey)))
+	}
+	if len(conf.PSK) > 0 && len(conf.PSK) < MinPSKSize {
+		errs = append(errs, fmt.Errorf("PSK must be at least %d bytes long, not %d", MinPSKSize, len(conf.PSK)))
+	}
+	if (len(conf.PSK) > 0) != (len(conf.PSKIdentity) > 0) {
+		errs = append(errs, errors.New("PSK and PSKIdentity must be set together"))
+	}
+	if len(conf.PSK) > 0 && (conf.UseTLS || conf.StartTLSHook != nil) {
+		errs = append(errs, errors.New("PSK can't be combined with UseTLS or StartTLSHook"))
+	}
+	if conf.MaxWritesPerSecond < 0 || conf.MaxBytesPerSecond < 0 {
+		errs = append(errs, errors.New("MaxWritesPerSecond and MaxBytesPerSecond must not be negative"))
+	}
+	if conf.OnMessageConcurrency < 0 {
+		errs = append(errs, errors.New("OnMessageConcurrency must not be negative"))
+	}
+	if conf.RingBufferSize < 0 {
+		errs = append(errs, errors.New("RingBufferSize must not be negative"))
+	}
+	if conf.ReplayBufferSize < 0 {
+		errs = append(errs, errors.New("ReplayBufferSize must not be negative"))
+	}
+	if conf.RingBufferSize > 0 && (conf.PooledReads || len(conf.EncryptionKey) > 0 || conf.Checksum) {
+		errs = append(errs, errors.New("RingBufferSize can't be combined with PooledReads, EncryptionKey or Checksum"))
+	}
+	if conf.RingBufferSize > 0 && conf.AckExtractor != nil {
+		// acknowledgements are extracted on the delivery path, which the ring bypasses
+		errs = append(errs, errors.New("RingBufferSize can't be combined with AckExtractor"))
+	}
+	if conf.RingBufferSize > 0 && conf.HealthCheckInterval > 0 {
+		// health check responses are matched on the delivery path, which the ring bypasses
+		errs = append(errs, errors.New("RingBufferSize can't be combined with HealthCheckInterval"))
+	}
+	if conf.ReadChannelSize < 0 {
+		errs = append(errs, errors.New("ReadChannelSize must not be negative"))
+	}
+	if conf.EventsBufferSize < 0 {
+		errs = append(errs, errors.New("EventsBufferSize must not be negative"))
+	}
+
+	if conf.UseTLS && conf.TLSConfig == nil {
+		errs = append(errs, errors.New("UseTLS requires a TLSConfig"))
+	}
+	if (len(conf.CertFile) > 0) != (len(conf.KeyFile) > 0) {
+		errs = append(errs, errors.New("CertFile and KeyFile must be set together"))
+	}
+	if !conf.UseTLS && conf.StartTLSHook == nil && (len(conf.CAFile) > 0 ||
+		len(conf.PinnedPublicKeys) > 0 || len(conf.PinnedCertificates) > 0 || len(conf.NextProtos) > 0) {
+		errs = append(errs, errors.New("CAFile, PinnedPublicKeys, PinnedCertificates and NextProtos require UseTLS or StartTLSHook"))
+	}
+	if conf.TLSMinVersion != 0 && conf.TLSMaxVersion != 0 && conf.TLSMinVersion > conf.TLSMaxVersion {
+		errs = append(errs, errors.New("TLSMinVersion is greater than TLSMaxVersion"))
+	}
+
+	return errors.Join(errs...)
+}
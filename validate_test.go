@@ -0,0 +1,81 @@
+package eventedconnection_test
+
+import (
+	"crypto/tls"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	valid := []Config{
+		{Endpoint: "localhost:5555"},
+		{Endpoint: "[::1]:5555", ReadTimeout: time.Second},
+		{SRVName: "evented-connection.test"},
+		{Endpoint: "localhost:5555", UseTLS: true, TLSConfig: &tls.Config{}},
+		{Endpoint: "localhost:5555", UseTLS: true, TLSConfig: &tls.Config{}, TLSMinVersion: tls.VersionTLS12, TLSMaxVersion: tls.VersionTLS13},
+		{Endpoint: "localhost:5555", UseTLS: true, TLSConfig: &tls.Config{}, CertFile: "client.crt", KeyFile: "client.key", CAFile: "ca.crt"},
+		{Endpoint: "localhost:5555", StartTLSHook: func(io.ReadWriter) error { return nil }, PinnedPublicKeys: []string{"pin"}, NextProtos: []string{"evented/1"}},
+		// the TLS settings of a plaintext client are used by UpgradeTLS
+		{Endpoint: "localhost:5555", TLSConfig: &tls.Config{}},
+		{Endpoint: "localhost:5555", CertFile: "client.crt", KeyFile: "client.key", TLSMinVersion: tls.VersionTLS12},
+		{Endpoint: "localhost:5555", EncryptionKey: make([]byte, 32)},
+		{Transport: TCPTransport{Address: "localhost:5555"}},
+		{Endpoint: "serial-bridge", Transport: TCPTransport{Address: "localhost:5555"}},
+		{Endpoint: "localhost:5555", PSKIdentity: "device-1", PSK: make([]byte, 32)},
+	}
+	for _, conf := range valid {
+		if err := conf.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid: %v", conf, err)
+		}
+	}
+
+	invalid := []Config{
+		{},
+		{Endpoint: "localhost"},
+		{Endpoint: "localhost:"},
+		{Endpoint: "localhost:5555", ReadTimeout: -time.Second},
+		{Endpoint: "localhost:5555", ReadBufferSize: -1},
+		{Endpoint: "localhost:5555", OnMessageConcurrency: -1},
+		{Endpoint: "localhost:5555", EncryptionKey: []byte("too short")},
+		{Endpoint: "localhost:5555", UseTLS: true, TLSConfig: &tls.Config{}, TLSMinVersion: tls.VersionTLS13, TLSMaxVersion: tls.VersionTLS12},
+		{Endpoint: "localhost:5555", UseTLS: true},
+		{Endpoint: "localhost:5555", UseTLS: true, TLSConfig: &tls.Config{}, CertFile: "client.crt"},
+		{Endpoint: "localhost:5555", UseTLS: true, TLSConfig: &tls.Config{}, KeyFile: "client.key"},
+		{Endpoint: "localhost:5555", CAFile: "ca.crt"},
+		{Endpoint: "localhost:5555", PinnedPublicKeys: []string{"pin"}},
+		{Endpoint: "localhost:5555", PinnedCertificates: []string{"pin"}},
+		{Endpoint: "localhost:5555", NextProtos: []string{"evented/1"}},
+		{SRVName: "evented-connection.test", Transport: TCPTransport{Address: "localhost:5555"}},
+		{Endpoint: "localhost:5555", RateWindow: time.Second, RateSampleInterval: time.Minute},
+		{Endpoint: "localhost:5555", HealthCheckInterval: time.Second},
+		{Endpoint: "localhost:5555", HealthCheckInterval: time.Second, HealthCheckPayload: []byte("PING")},
+		{Endpoint: "localhost:5555", RingBufferSize: 1024, AckExtractor: func([]byte) (uint64, bool) { return 0, false }},
+		{Endpoint: "localhost:5555", RingBufferSize: 1024, HealthCheckInterval: time.Second, HealthCheckPayload: []byte("PING"), HealthCheckMatcher: func([]byte) bool { return true }},
+		{Endpoint: "localhost:5555", PSKIdentity: "device-1", PSK: []byte("too short")},
+		{Endpoint: "localhost:5555", PSK: make([]byte, 32)},
+		{Endpoint: "localhost:5555", AutoReconnect: true, MaxReconnectAttempts: -1},
+		{Endpoint: "localhost:5555", AutoReconnect: true, MaxReconnectDuration: -time.Second},
+		{Endpoint: "localhost:5555", PSKIdentity: "device-1", PSK: make([]byte, 32), UseTLS: true},
+	}
+	for _, conf := range invalid {
+		if err := conf.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", conf)
+		}
+	}
+
+	// every problem is reported
+	conf := Config{Endpoint: "localhost", ConnectionTimeout: -1, WriteTimeout: -1}
+	err := conf.Validate()
+	assertNotNil(t, err)
+	assertEqual(t, len(strings.Split(err.Error(), "\n")), 3)
+
+	con, err := NewClient(&conf)
+	assertNotNil(t, err)
+	if con != nil {
+		t.Error("Expected con to be nil")
+	}
+}
@@ -0,0 +1,64 @@
+package eventedconnection
+
+import "sync"
+
+// goroutineGroup counts running goroutines like a sync.WaitGroup, but can be waited
+// on while goroutines are still being started
+type goroutineGroup struct {
+	mutex sync.Mutex
+	count int
+	idle  chan struct{} // closed while count is zero; nil until first used
+}
+
+// start runs f in a new goroutine counted by the group
+func (g *goroutineGroup) start(f func()) {
+	g.mutex.Lock()
+	if g.count == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.count++
+	g.mutex.Unlock()
+
+	go func() {
+		defer g.done()
+		f()
+	}()
+}
+
+func (g *goroutineGroup) done() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.count--
+	if g.count == 0 {
+		close(g.idle)
+	}
+}
+
+// wait returns a channel that is closed once no goroutine of the group is running
+func (g *goroutineGroup) wait() <-chan struct{} {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.idle == nil {
+		g.idle = make(chan struct{})
+		close(g.idle)
+	}
+	return g.idle
+}
+
+// WaitClosed blocks until the connection is closed and the goroutines serving it,
+// such as the read loop and keepalives, have exited, so tests and short-lived
+// programs can be sure nothing is left running. If the client is reconnected in the
+// meantime, e.g. by Config.AutoReconnect, it waits for that connection to close too.
+// Once the client is shut down it also waits for the goroutines that run until
+// Shutdown, like the OnMessageHook dispatcher. Note that the read loop doesn't exit
+// while it waits to deliver a message on the Read channel, unless the client is shut
+// down.
+func (conn *Client) WaitClosed() {
+	<-conn.disconnected()
+	<-conn.routines.wait()
+	if conn.isShutdown() {
+		<-conn.clientRoutines.wait()
+	}
+}
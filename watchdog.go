@@ -0,0 +1,48 @@
+package eventedconnection
+
+import (
+	"log/slog"
+	"time"
+)
+
+// watchdog replaces the connection once nothing was read from it for
+// conn.watchdogTimeout, until stop is closed or the client is shut down
+func (conn *Client) watchdog(stop <-chan struct{}) {
+	last := conn.clock.Now() // when something was last read, as far as the watchdog knows
+	timer := conn.clock.NewTimer(conn.watchdogTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C():
+		case <-stop:
+			return
+		case <-conn.done:
+			return
+		}
+
+		if read := conn.GetStats().LastReadAt; read.After(last) {
+			last = read
+		}
+		if wait := conn.watchdogTimeout - conn.since(last); wait > 0 {
+			timer.Reset(wait) // read from since, so the connection isn't wedged yet
+			continue
+		}
+
+		conn.tripWatchdog(conn.since(last))
+		return // Reconnect starts a new watchdog for the new connection
+	}
+}
+
+// tripWatchdog reports that nothing was read for silence and reconnects, retrying with
+// backoff like Config.AutoReconnect if the first attempt fails
+func (conn *Client) tripWatchdog(silence time.Duration) {
+	conn.logger.Warn("no data received, reconnecting", slog.Duration("silence", silence))
+	conn.stats.recordWatchdogTrip()
+	conn.emit(WatchdogEvent{Origin: conn.origin(), Silence: silence})
+	if conn.onWatchdogHook != nil {
+		conn.onWatchdogHook(silence)
+	}
+
+	conn.replaceConnection()
+}
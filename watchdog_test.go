@@ -0,0 +1,121 @@
+package eventedconnection_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_Watchdog(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.BlackHoleServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	trips := make(chan time.Duration, 10)
+	conf := Config{
+		Endpoint:        l.Addr().String(),
+		WatchdogTimeout: 50 * time.Millisecond,
+		OnWatchdogHook:  func(silence time.Duration) { trips <- silence },
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	events, cancel := con.SubscribeEvents()
+	defer cancel()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case silence := <-trips:
+		if silence < 50*time.Millisecond {
+			t.Fatalf("Expected the watchdog to trip after 50ms of silence, tripped after %v", silence)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for the watchdog to trip")
+	}
+
+	// the watchdog replaced the connection rather than just closing it
+	deadline := time.Now().Add(2 * time.Second)
+	for con.GetStats().Reconnects == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Test timed out while waiting for the client to reconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := con.GetStats(); stats.WatchdogTrips == 0 {
+		t.Fatal("Expected the watchdog trip to be counted")
+	}
+
+	for {
+		select {
+		case event := <-events:
+			if _, ok := event.(WatchdogEvent); ok {
+				return
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Test timed out while waiting for the WatchdogEvent")
+		}
+	}
+}
+
+func TestClient_WatchdogKeepsReconnecting(t *testing.T) {
+	// accept a single connection, never answer on it and then refuse to reconnect
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := l.Accept()
+		l.Close()
+		if err == nil {
+			accepted <- c
+		}
+	}()
+	defer func() {
+		select {
+		case c := <-accepted:
+			c.Close()
+		default:
+		}
+	}()
+
+	attempts := make(chan ReconnectAttempt, 10)
+	conf := Config{
+		Endpoint:        l.Addr().String(),
+		WatchdogTimeout: 50 * time.Millisecond,
+		ReconnectDelay:  10 * time.Millisecond,
+		OnReconnectAttemptHook: func(attempt ReconnectAttempt) error {
+			attempts <- attempt
+			return nil
+		},
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the reconnect made by the watchdog fails, so the client retries without AutoReconnect
+	select {
+	case attempt := <-attempts:
+		assertEqual(t, attempt.Attempt, 1)
+		assertNotNil(t, attempt.LastErr)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Test timed out while waiting for a reconnect attempt")
+	}
+}
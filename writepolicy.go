@@ -0,0 +1,46 @@
+package eventedconnection
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WriteErrorPolicy decides whether a failed write closes the connection
+type WriteErrorPolicy int
+
+const (
+	// WriteErrorClose closes the connection on any write error
+	WriteErrorClose WriteErrorPolicy = iota
+	// WriteErrorKeepOnTimeout keeps the connection open when a write times out, so a
+	// connection that is still receiving data fine survives a stalled peer. Part of the
+	// data may have been written, so it should only be used with protocols that can
+	// recover from that. Other write errors still close the connection.
+	WriteErrorKeepOnTimeout
+)
+
+func (p WriteErrorPolicy) String() string {
+	switch p {
+	case WriteErrorClose:
+		return "close"
+	case WriteErrorKeepOnTimeout:
+		return "keepOnTimeout"
+	}
+	return fmt.Sprintf("WriteErrorPolicy(%d)", int(p))
+}
+
+// ParseWriteErrorPolicy converts a policy name ("close" or "keepOnTimeout", as returned
+// by WriteErrorPolicy.String) into the corresponding WriteErrorPolicy.
+func ParseWriteErrorPolicy(name string) (WriteErrorPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "close":
+		return WriteErrorClose, nil
+	case "keepontimeout":
+		return WriteErrorKeepOnTimeout, nil
+	}
+	return 0, fmt.Errorf("unknown write error policy %q", name)
+}
+
+// closesOnWriteError reports whether the write error err closes the connection
+func (conn *Client) closesOnWriteError(err error) bool {
+	return conn.writeErrorPolicy == WriteErrorClose || !isTimeout(err)
+}
@@ -0,0 +1,51 @@
+package eventedconnection_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_WriteErrorPolicy(t *testing.T) {
+	// a server that never reads, so writes eventually time out
+	done := make(chan bool)
+	l, err := testutils.BlackHoleServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:         l.Addr().String(),
+		WriteTimeout:     100 * time.Millisecond,
+		WriteErrorPolicy: WriteErrorKeepOnTimeout,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	large := make([]byte, 64*1024*1024)
+	if err = con.Write(&large); !errors.Is(err, ErrWriteTimeout) {
+		t.Fatalf("expected ErrWriteTimeout, got %v", err)
+	}
+	assertEqual(t, con.IsActive(), true)
+	assertEqual(t, con.Err(), nil)
+}
+
+func TestParseWriteErrorPolicy(t *testing.T) {
+	for _, policy := range []WriteErrorPolicy{WriteErrorClose, WriteErrorKeepOnTimeout} {
+		parsed, err := ParseWriteErrorPolicy(policy.String())
+		assertEqual(t, err, nil)
+		assertEqual(t, parsed, policy)
+	}
+	_, err := ParseWriteErrorPolicy("retry")
+	assertNotNil(t, err)
+}
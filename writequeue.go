@@ -0,0 +1,209 @@
+package eventedconnection
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// OnWriteExpiredHook is called with every queued write that is dropped because it
+// waited in the write queue for longer than Config.WriteQueueTTL. data belongs to the
+// hook.
+type OnWriteExpiredHook func(data []byte)
+
+// queuedWrite is a write held in the writeQueue
+type queuedWrite struct {
+	data     []byte
+	queuedAt time.Time
+	seq      uint64 // see Config.AckExtractor; zero if not numbered
+}
+
+// writeQueue holds the writes made while disconnected, see Config.WriteQueueSize
+type writeQueue struct {
+	mutex    sync.Mutex
+	size     int
+	maxBytes int // zero for unlimited
+	bytes    int // queued bytes
+	ttl      time.Duration
+	messages []queuedWrite
+	outbox   *outbox // nil unless Config.WriteQueueFile is set
+}
+
+// newWriteQueue returns the write queue configured by conf, loaded with the writes
+// left in its outbox file, if any
+func newWriteQueue(conf *Config) (*writeQueue, error) {
+	q := &writeQueue{size: conf.WriteQueueSize, maxBytes: conf.WriteQueueMaxBytes, ttl: conf.WriteQueueTTL}
+	if len(conf.WriteQueueFile) > 0 {
+		var err error
+		if q.outbox, q.messages, err = openOutbox(conf.WriteQueueFile, conf.WriteQueueFileMaxSize); err != nil {
+			return nil, err
+		}
+		for _, message := range q.messages {
+			q.bytes += len(message.data)
+		}
+	}
+	return q, nil
+}
+
+// push adds a copy of data to the end of the queue, appending it to the outbox file
+// if there is one. It fails with ErrQueueFull if the queue has no room for data.
+func (q *writeQueue) push(data []byte, seq uint64, now time.Time) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.messages) >= q.size || (q.maxBytes > 0 && q.bytes+len(data) > q.maxBytes) {
+		return ErrQueueFull
+	}
+	message := queuedWrite{data: slices.Clone(data), queuedAt: now, seq: seq}
+	if q.outbox != nil {
+		ok, err := q.outbox.append(message)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrQueueFull
+		}
+	}
+	q.messages = append(q.messages, message)
+	q.bytes += len(data)
+	return nil
+}
+
+// peek returns the oldest queued write
+func (q *writeQueue) peek() (queuedWrite, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.messages) == 0 {
+		return queuedWrite{}, false
+	}
+	return q.messages[0], true
+}
+
+// drop removes the oldest queued write
+func (q *writeQueue) drop() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.bytes -= len(q.messages[0].data)
+	q.messages[0] = queuedWrite{}
+	q.messages = q.messages[1:]
+}
+
+// numbered returns the queued writes that are numbered for at-least-once delivery,
+// e.g. those loaded from the outbox file
+func (q *writeQueue) numbered() []queuedWrite {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	var writes []queuedWrite
+	for _, message := range q.messages {
+		if message.seq != 0 {
+			writes = append(writes, message)
+		}
+	}
+	return writes
+}
+
+// persist rewrites the outbox file, if any, to hold just the writes still queued
+func (q *writeQueue) persist() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.outbox == nil {
+		return nil
+	}
+	return q.outbox.rewrite(q.messages)
+}
+
+func (q *writeQueue) close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.outbox == nil {
+		return nil
+	}
+	return q.outbox.close()
+}
+
+func (q *writeQueue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.messages)
+}
+
+// expired reports whether message waited in the queue for longer than the TTL
+func (q *writeQueue) expired(message queuedWrite, now time.Time) bool {
+	return q.ttl > 0 && now.Sub(message.queuedAt) > q.ttl
+}
+
+// queueWrite queues data if there is no connection to write it to, or if earlier
+// writes are still queued so it must not overtake them. It reports whether data
+// was meant to be queued, and the error if it couldn't be. conn.writeMutex must be
+// held.
+func (conn *Client) queueWrite(data []byte, seq uint64) (bool, error) {
+	if conn.writeQueue == nil || conn.isShutdown() {
+		return false, nil
+	}
+	if conn.rawConnection() != nil && conn.writeQueue.len() == 0 {
+		return false, nil
+	}
+	return true, conn.writeQueue.push(data, seq, conn.clock.Now())
+}
+
+// flushWriteQueue writes the queued writes to the connection in order, dropping those
+// that expired. It stops at the first write that fails, which stays queued, and
+// removes the writes it is done with from the outbox file.
+func (conn *Client) flushWriteQueue() {
+	if conn.writeQueue == nil {
+		return
+	}
+
+	conn.writeMutex.Lock()
+	defer conn.unlockWrites()
+	conn.flushWriteQueueLocked()
+}
+
+// flushWriteQueueLocked does the work of flushWriteQueue. conn.writeMutex must be held.
+func (conn *Client) flushWriteQueueLocked() {
+	if conn.writeQueue == nil {
+		return
+	}
+
+	defer func() {
+		if err := conn.writeQueue.persist(); err != nil {
+			conn.handleError(err)
+		}
+	}()
+
+	for {
+		message, ok := conn.writeQueue.peek()
+		if !ok {
+			return
+		}
+
+		if conn.writeQueue.expired(message, conn.clock.Now()) {
+			conn.writeQueue.drop()
+			if message.seq != 0 {
+				conn.acks.remove(message.seq)
+			}
+			if conn.onWriteExpiredHook != nil {
+				conn.onWriteExpiredHook(message.data)
+			}
+			continue
+		}
+
+		timeout := conn.GetWriteTimeout()
+		if err := conn.limitRate(len(message.data), timeout, true); err != nil {
+			conn.handleError(err)
+			return
+		}
+		if err := conn.writeLocked(message.data, timeout); err != nil {
+			return // reported by writeLocked
+		}
+		conn.writeQueue.drop()
+		if message.seq != 0 {
+			conn.acks.sent(message.seq)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package eventedconnection_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/joedursun/EventedConnection"
+	"github.com/joedursun/EventedConnection/testutils"
+)
+
+func TestClient_WriteQueue(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{Endpoint: l.Addr().String(), WriteQueueSize: 2}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	// queued until connected
+	for _, message := range []string{"one", "two"} {
+		if err = con.WriteString(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(t, con.WriteString("three"), ErrQueueFull)
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.WriteString("four"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := con.ReadN(len("onetwofour"), 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "onetwofour")
+}
+
+func TestClient_WriteQueueMaxBytes(t *testing.T) {
+	conf := Config{Endpoint: "127.0.0.1:0", WriteQueueSize: 10, WriteQueueMaxBytes: 8}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	if err = con.WriteString("12345"); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, con.WriteString("6789"), ErrQueueFull)
+	assertEqual(t, con.WriteString("678"), nil)
+}
+
+func TestClient_WriteQueueTTL(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	expired := make(chan []byte, 1)
+	conf := Config{
+		Endpoint:           l.Addr().String(),
+		WriteQueueSize:     4,
+		WriteQueueTTL:      20 * time.Millisecond,
+		OnWriteExpiredHook: func(data []byte) { expired <- data },
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+
+	if err = con.WriteString("stale"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err = con.WriteString("fresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(<-expired), "stale")
+	data, err := con.ReadN(len("fresh"), 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "fresh")
+}
+
+func TestClient_WriteQueueFile(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:              l.Addr().String(),
+		WriteQueueSize:        8,
+		WriteQueueFile:        filepath.Join(t.TempDir(), "outbox"),
+		WriteQueueFileMaxSize: 2 * (20 + 3), // two records of three bytes
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"one", "two"} {
+		if err = con.WriteString(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertEqual(t, con.WriteString("six"), ErrQueueFull) // the file is full
+	con.Shutdown()
+
+	// a new client picks up where the last one left off
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := con.ReadN(len("onetwo"), 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data), "onetwo")
+
+	info, err := os.Stat(conf.WriteQueueFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, info.Size(), int64(0))
+
+	// the file was replaced without leaving the temporary file behind
+	entries, err := os.ReadDir(filepath.Dir(conf.WriteQueueFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(entries), 1)
+}
+
+func TestClient_WriteQueueFileCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox")
+	record := make([]byte, 20)
+	binary.BigEndian.PutUint32(record[16:], 1<<31) // a length no write can have
+	if err := os.WriteFile(path, record, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := Config{
+		Endpoint:       "localhost:5555",
+		WriteQueueSize: 8,
+		WriteQueueFile: path,
+	}
+	if _, err := NewClient(&conf); err == nil {
+		t.Fatal("Expected the corrupt outbox file to be reported")
+	}
+}
+
+func TestClient_WriteQueueFileAcks(t *testing.T) {
+	// the server never acknowledges
+	done := make(chan bool)
+	l, recorder, err := testutils.RecordingServer(done, testutils.RecordingOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	conf := Config{
+		Endpoint:       l.Addr().String(),
+		WriteQueueSize: 8,
+		WriteQueueFile: filepath.Join(t.TempDir(), "outbox"),
+		AckExtractor:   extractSeq,
+	}
+	con, err := NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range []string{"one", "two"} {
+		if err = con.WriteString(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	con.Shutdown()
+
+	// the restored writes keep their numbers and new writes don't reuse them
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Shutdown()
+	if err = con.WriteString("new"); err != nil {
+		t.Fatal(err)
+	}
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := recorder.WaitForReceived(3*(8+3), 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, message := range []string{"one", "two", "new"} {
+		record := data[i*(8+3) : (i+1)*(8+3)]
+		assertEqual(t, binary.BigEndian.Uint64(record), uint64(i+1))
+		assertEqual(t, string(record[8:]), message)
+	}
+	assertEqual(t, con.Unacked(), 3) // the restored writes are tracked too
+
+	// and retransmitted on the next connection since they weren't acknowledged
+	if err = con.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	data, err = recorder.WaitForReceived(6*(8+3), 2*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, string(data[3*(8+3):]), string(data[:3*(8+3)]))
+}
+
+func TestClient_SequenceHookUnacked(t *testing.T) {
+	done := make(chan bool)
+	l, err := testutils.EchoServer(done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer close(done)
+
+	var con *Client
+	unacked := make(chan int, 1)
+	conf := Config{
+		Endpoint:     l.Addr().String(),
+		AckExtractor: func([]byte) (uint64, bool) { return 0, false },
+		SequenceHook: func(seq uint64, data []byte) ([]byte, error) {
+			unacked <- con.Unacked()
+			return data, nil
+		},
+	}
+	con, err = NewClient(&conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer con.Close()
+	if err = con.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = con.WriteString("one"); err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, <-unacked, 0)
+	assertEqual(t, con.Unacked(), 1)
+}